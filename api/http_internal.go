@@ -106,6 +106,29 @@ func NewCatalystAPIRouterInternal(cli config.Cli, vodEngine *pipeline.Coordinato
 		),
 	)
 
+	// Clips cut out of existing HLS assets, tracked like the other VOD jobs
+	router.POST("/api/vod/clip",
+		withLogging(
+			withAuth(
+				cli.APIToken,
+				withCapacityChecking(
+					vodEngine,
+					catalystApiHandlers.ClipVOD(),
+				),
+			),
+		),
+	)
+
+	// Status and cancellation of the in-flight VOD jobs
+	router.GET("/api/vod", withLogging(withAuth(cli.APIToken, catalystApiHandlers.ListVODJobs())))
+	router.GET("/api/vod/:request_id", withLogging(withAuth(cli.APIToken, catalystApiHandlers.GetVODJob())))
+	router.DELETE("/api/vod/:request_id", withLogging(withAuth(cli.APIToken, catalystApiHandlers.CancelVODJob())))
+	router.GET("/api/vod/:request_id/events", withLogging(withAuth(cli.APIToken, catalystApiHandlers.VODJobEvents())))
+
+	// Terminal callbacks that the outbox couldn't deliver
+	router.GET("/api/callbacks/dead-letter", withLogging(withAuth(cli.APIToken, catalystApiHandlers.ListDeadLetterCallbacks())))
+	router.POST("/api/callbacks/dead-letter/:id/redeliver", withLogging(withAuth(cli.APIToken, catalystApiHandlers.RedeliverCallback())))
+
 	// Public GET handler to retrieve the public key for vod encryption
 	router.GET("/api/pubkey", withLogging(encryptionHandlers.PublicKeyHandler()))
 
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"mime"
@@ -51,11 +52,31 @@ type StreamAllocResponse struct {
 	ManifestId string `json:"id"`
 }
 
+// livepeerEncoders are the names of the codecs in the Livepeer profiles
+var livepeerEncoders = map[string]string{
+	video.CodecH264: "H.264",
+	video.CodecHEVC: "HEVC",
+}
+
+// livepeerProfiles sets the encoder of the profiles from their codec, unless
+// it was explicitly set, since Livepeer doesn't know about the codec field.
+func livepeerProfiles(profiles []video.EncodedProfile) []video.EncodedProfile {
+	lpProfiles := make([]video.EncodedProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		if profile.Encoder == "" && profile.Codec != "" {
+			profile.Encoder = livepeerEncoders[profile.VideoCodec()]
+		}
+		profile.Codec = ""
+		lpProfiles = append(lpProfiles, profile)
+	}
+	return lpProfiles
+}
+
 var client = newRetryableClient(&http.Client{Timeout: TRANSCODE_TIMEOUT})
 
 // TranscodeSegment sends media to Livepeer network and returns rendition segments
 // If manifestId == "" one will be created and deleted after use, pass real value to reuse across multiple calls
-func transcodeSegment(inputSegment io.Reader, sequenceNumber, mediaDurationMillis int64, broadcasterURL url.URL, manifestId string, profiles []video.EncodedProfile, transcodeConfigHeader string) (TranscodeResult, error) {
+func transcodeSegment(ctx context.Context, inputSegment io.Reader, sequenceNumber, mediaDurationMillis int64, broadcasterURL url.URL, manifestId string, profiles []video.EncodedProfile, transcodeConfigHeader string) (TranscodeResult, error) {
 	t := TranscodeResult{}
 
 	// Send segment to be transcoded
@@ -63,7 +84,7 @@ func transcodeSegment(inputSegment io.Reader, sequenceNumber, mediaDurationMilli
 	if err != nil {
 		return t, fmt.Errorf("appending stream to broadcaster url %s: %v", broadcasterURL.String(), err)
 	}
-	req, err := http.NewRequest(http.MethodPost, requestURL.String(), inputSegment)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL.String(), inputSegment)
 	if err != nil {
 		return t, fmt.Errorf("NewRequest POST for url %s: %v", requestURL.String(), err)
 	}
@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// FfmpegTranscoder transcodes segments with a local software ffmpeg rather
+// than sending them to a Livepeer Broadcaster. It's much slower, but doesn't
+// depend on the network or any external service.
+type FfmpegTranscoder struct {
+	// Transcodes into audio renditions only, since Livepeer needs a video track
+	AudioOnly bool
+}
+
+func (t FfmpegTranscoder) TranscodeSegment(ctx context.Context, segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (TranscodeResult, error) {
+	dir, err := os.MkdirTemp(os.TempDir(), "transcode-"+manifestID+"-*")
+	if err != nil {
+		return TranscodeResult{}, fmt.Errorf("failed to create temp dir for transcoding: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputFile := filepath.Join(dir, fmt.Sprintf("source-%d.ts", sequenceNumber))
+	if err := writeFile(inputFile, segment); err != nil {
+		return TranscodeResult{}, err
+	}
+
+	var outputFiles []string
+	for _, profile := range profiles {
+		outputFiles = append(outputFiles, filepath.Join(dir, fmt.Sprintf("%s-%d.ts", profile.Name, sequenceNumber)))
+	}
+	transcodeSegment := video.TranscodeSegment
+	if t.AudioOnly {
+		transcodeSegment = video.TranscodeAudioSegment
+	}
+	if err := transcodeSegment(ctx, inputFile, outputFiles, profiles); err != nil {
+		return TranscodeResult{}, err
+	}
+
+	var result TranscodeResult
+	for i, profile := range profiles {
+		data, err := os.ReadFile(outputFiles[i])
+		if err != nil {
+			return TranscodeResult{}, fmt.Errorf("failed to read transcoded rendition %q: %w", profile.Name, err)
+		}
+		result.Renditions = append(result.Renditions, &RenditionSegment{
+			Name:      profile.Name,
+			MediaData: data,
+		})
+	}
+	return result, nil
+}
+
+func writeFile(filename string, data io.Reader) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %w", filename, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, data); err != nil {
+		return fmt.Errorf("failed to write file %q: %w", filename, err)
+	}
+	return f.Close()
+}
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,7 +14,7 @@ import (
 // Currently only implemented by LocalBroadcasterClient
 // TODO: Try to come up with a unified interface across Local and Remote
 type BroadcasterClient interface {
-	TranscodeSegment(segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (TranscodeResult, error)
+	TranscodeSegment(ctx context.Context, segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (TranscodeResult, error)
 }
 
 type LocalBroadcasterClient struct {
@@ -30,15 +31,15 @@ func NewLocalBroadcasterClient(broadcasterURL string) (LocalBroadcasterClient, e
 	}, nil
 }
 
-func (c LocalBroadcasterClient) TranscodeSegment(segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (TranscodeResult, error) {
+func (c LocalBroadcasterClient) TranscodeSegment(ctx context.Context, segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (TranscodeResult, error) {
 	conf := LivepeerTranscodeConfiguration{
 		TimeoutMultiplier: 10,
 	}
-	conf.Profiles = append(conf.Profiles, profiles...)
+	conf.Profiles = livepeerProfiles(profiles)
 	transcodeConfig, err := json.Marshal(&conf)
 	if err != nil {
 		return TranscodeResult{}, fmt.Errorf("for local B, profiles json encode failed: %v", err)
 	}
 
-	return transcodeSegment(segment, sequenceNumber, durationMillis, c.broadcasterURL, manifestID, profiles, string(transcodeConfig))
+	return transcodeSegment(ctx, segment, sequenceNumber, durationMillis, c.broadcasterURL, manifestID, profiles, string(transcodeConfig))
 }
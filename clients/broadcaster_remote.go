@@ -2,6 +2,7 @@ package clients
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -39,7 +40,7 @@ func NewRemoteBroadcasterClient(credentials Credentials) (RemoteBroadcasterClien
 	}, nil
 }
 
-func (c *RemoteBroadcasterClient) TranscodeSegmentWithRemoteBroadcaster(segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, streamName string, durationMillis int64) (TranscodeResult, error) {
+func (c *RemoteBroadcasterClient) TranscodeSegmentWithRemoteBroadcaster(ctx context.Context, segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, streamName string, durationMillis int64) (TranscodeResult, error) {
 	// Get available broadcasters
 	bList, err := findBroadcaster(c.credentials)
 	if err != nil {
@@ -63,7 +64,7 @@ func (c *RemoteBroadcasterClient) TranscodeSegmentWithRemoteBroadcaster(segment
 		return TranscodeResult{}, fmt.Errorf("pickRandomBroadcaster failed %v", err)
 	}
 
-	return transcodeSegment(segment, sequenceNumber, durationMillis, broadcasterURL, manifestId, profiles, "")
+	return transcodeSegment(ctx, segment, sequenceNumber, durationMillis, broadcasterURL, manifestId, profiles, "")
 }
 
 // findBroadcaster contacts Livepeer API for a broadcaster to use if localBroadcaster is not defined
@@ -109,7 +110,7 @@ func CreateStream(c Credentials, streamName string, profiles []video.EncodedProf
 	}
 	// prepare payload
 	payload := createStreamPayload{Name: streamName}
-	payload.Profiles = append(payload.Profiles, profiles...)
+	payload.Profiles = livepeerProfiles(profiles)
 	payloadBytes, err := json.Marshal(&payload)
 	if err != nil {
 		return "", fmt.Errorf("POST url=%s json encode error %v struct=%v", requestURL, err, payload)
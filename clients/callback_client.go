@@ -42,6 +42,10 @@ type PeriodicCallbackClient struct {
 	httpClient               *http.Client
 	callbackInterval         time.Duration
 	headers                  map[string]string
+	// when set, the headers are only sent to these hosts
+	trustedHosts   []string
+	signingSecrets []string
+	outbox         *CallbackOutbox
 }
 
 func NewPeriodicCallbackClient(callbackInterval time.Duration, headers map[string]string) *PeriodicCallbackClient {
@@ -63,6 +67,32 @@ func NewPeriodicCallbackClient(callbackInterval time.Duration, headers map[strin
 	}
 }
 
+// WithTrustedHosts restricts the headers passed to the constructor, which hold
+// our API token, to the callbacks sent to the given hosts. Since the callback
+// URLs are chosen by the callers, they shouldn't all get the token. An empty
+// list keeps the legacy behaviour of sending the headers to every host.
+func (pcc *PeriodicCallbackClient) WithTrustedHosts(hosts []string) *PeriodicCallbackClient {
+	pcc.trustedHosts = nil
+	if len(hosts) > 0 {
+		pcc.trustedHosts = append([]string{}, hosts...)
+	}
+	return pcc
+}
+
+// WithSigningSecrets enables signing the callback bodies with each of the
+// secrets, see CallbackSignatureHeader.
+func (pcc *PeriodicCallbackClient) WithSigningSecrets(secrets []string) *PeriodicCallbackClient {
+	pcc.signingSecrets = secrets
+	return pcc
+}
+
+// WithOutbox makes the terminal callbacks go through the outbox, which keeps
+// retrying them until they are delivered.
+func (pcc *PeriodicCallbackClient) WithOutbox(outbox *CallbackOutbox) *PeriodicCallbackClient {
+	pcc.outbox = outbox
+	return pcc
+}
+
 // Start looping through all active jobs, sending a callback for the latest status of each
 // and then pausing for a set amount of time
 func (pcc *PeriodicCallbackClient) Start() *PeriodicCallbackClient {
@@ -74,6 +104,16 @@ func (pcc *PeriodicCallbackClient) Start() *PeriodicCallbackClient {
 			})
 		}
 	}()
+	if pcc.outbox != nil {
+		go func() {
+			for {
+				recoverer(func() {
+					time.Sleep(outboxInitialBackoff)
+					pcc.outbox.Deliver(pcc.sendCallback)
+				})
+			}
+		}()
+	}
 	return pcc
 }
 
@@ -97,6 +137,14 @@ func (pcc *PeriodicCallbackClient) SendTranscodeStatus(tsm TranscodeStatusMessag
 
 	// Terminal callbacks are sent here in a sync manner
 	// Non-terminal callbacks are sent periodically, in an async manner
+	if tsm.IsTerminal() && pcc.outbox != nil {
+		err := pcc.outbox.Send(tsm, pcc.sendCallback)
+		if err != nil {
+			log.LogError(tsm.RequestID, "failed to add callback to the outbox, sending it directly", err)
+			return pcc.sendCallback(tsm)
+		}
+		return nil
+	}
 	if tsm.IsTerminal() || tsm.SourcePlayback != nil {
 		return pcc.sendCallback(tsm)
 	}
@@ -141,7 +189,7 @@ func (pcc *PeriodicCallbackClient) SendRecordingEvent(event *RecordingEvent) {
 			return
 		}
 
-		err = pcc.doWithRetries(r)
+		err = pcc.doWithRetries(r, j)
 		if err != nil {
 			log.LogNoRequestID("failed to send recording event callback", "err", err)
 			return
@@ -192,7 +240,7 @@ func (pcc *PeriodicCallbackClient) sendCallback(tsm TranscodeStatusMessage) erro
 		return err
 	}
 
-	err = pcc.doWithRetries(r)
+	err = pcc.doWithRetries(r, j)
 	if err != nil {
 		log.LogError(tsm.RequestID, "failed to send callback", err)
 		return err
@@ -200,9 +248,14 @@ func (pcc *PeriodicCallbackClient) sendCallback(tsm TranscodeStatusMessage) erro
 	return nil
 }
 
-func (pcc *PeriodicCallbackClient) doWithRetries(r *http.Request) error {
-	for k, v := range pcc.headers {
-		r.Header.Set(k, v)
+func (pcc *PeriodicCallbackClient) doWithRetries(r *http.Request, body []byte) error {
+	if pcc.trustedHosts == nil || isTrustedHost(r.URL.Hostname(), pcc.trustedHosts) {
+		for k, v := range pcc.headers {
+			r.Header.Set(k, v)
+		}
+	}
+	if len(pcc.signingSecrets) > 0 {
+		r.Header.Set(CallbackSignatureHeader, SignCallback(body, config.Clock.GetTimestampUTC(), pcc.signingSecrets))
 	}
 
 	resp, err := metrics.MonitorRequest(metrics.Metrics.TranscodingStatusUpdate, pcc.httpClient, r)
@@ -3,6 +3,7 @@ package clients
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/livepeer/catalyst-api/config"
 	"github.com/livepeer/catalyst-api/video"
@@ -18,6 +19,7 @@ const (
 	TranscodeStatusTranscoding
 	TranscodeStatusCompleted
 	TranscodeStatusError
+	TranscodeStatusCancelled
 )
 
 func (ts TranscodeStatus) String() string {
@@ -32,6 +34,8 @@ func (ts TranscodeStatus) String() string {
 		return "success"
 	case TranscodeStatusError:
 		return "error"
+	case TranscodeStatusCancelled:
+		return "cancelled"
 	}
 	return "unknown"
 }
@@ -52,6 +56,8 @@ func (ts *TranscodeStatus) UnmarshalJSON(b []byte) error {
 		*ts = TranscodeStatusCompleted
 	case "\"error\"":
 		*ts = TranscodeStatusError
+	case "\"cancelled\"":
+		*ts = TranscodeStatusCancelled
 	default:
 		return fmt.Errorf("invalid transcode status %q", string(b))
 	}
@@ -89,6 +95,41 @@ type TranscodeStatusMessage struct {
 	Outputs    []video.OutputVideo `json:"outputs,omitempty"`
 
 	SourcePlayback *video.OutputVideo `json:"source_playback,omitempty"`
+
+	// Only set while the job is waiting in the queue, starting from 1
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// Number of the attempt at running the job, starting from 1
+	Attempt int `json:"attempt,omitempty"`
+
+	// Only used for the terminal status messages
+	Timeline []JobStage `json:"timeline,omitempty"`
+}
+
+// JobStage is an entry in the timeline of a job, e.g. the segmenting of the
+// source file or the upload of the manifests.
+type JobStage struct {
+	Name         string    `json:"name"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	DurationSecs float64   `json:"duration_secs"`
+	Bytes        int64     `json:"bytes,omitempty"`
+	Segments     int       `json:"segments,omitempty"`
+	// Number of times the stage was run again, by a job retry or a fallback pipeline
+	Retries int `json:"retries,omitempty"`
+}
+
+// NewJobStage creates the entry of a stage that started at the given time and just ended.
+func NewJobStage(name string, start time.Time, bytes int64, segments int) JobStage {
+	end := time.Now()
+	return JobStage{
+		Name:         name,
+		Start:        start,
+		End:          end,
+		DurationSecs: end.Sub(start).Seconds(),
+		Bytes:        bytes,
+		Segments:     segments,
+	}
 }
 
 // This method will accept the completion ratio of the current stage and will translate that into the overall ratio
@@ -107,6 +148,12 @@ func NewTranscodeStatusSourcePlayback(url, requestID string, status TranscodeSta
 	}
 }
 
+func NewTranscodeStatusQueued(url, requestID string, queuePosition int) TranscodeStatusMessage {
+	tsm := NewTranscodeStatusProgress(url, requestID, TranscodeStatusPreparing, 0)
+	tsm.QueuePosition = queuePosition
+	return tsm
+}
+
 func NewTranscodeStatusError(url, requestID, errorMsg string, unretriable bool) TranscodeStatusMessage {
 	return TranscodeStatusMessage{
 		URL:         url,
@@ -118,6 +165,15 @@ func NewTranscodeStatusError(url, requestID, errorMsg string, unretriable bool)
 	}
 }
 
+func NewTranscodeStatusCancelled(url, requestID string) TranscodeStatusMessage {
+	return TranscodeStatusMessage{
+		URL:       url,
+		RequestID: requestID,
+		Status:    TranscodeStatusCancelled,
+		Timestamp: config.Clock.GetTimestampUTC(),
+	}
+}
+
 // Separate method as this requires a much richer message than the other status callbacks
 func NewTranscodeStatusCompleted(url, requestID string, iv video.InputVideo, ov []video.OutputVideo) TranscodeStatusMessage {
 	return TranscodeStatusMessage{
@@ -136,7 +192,8 @@ func NewTranscodeStatusCompleted(url, requestID string, iv video.InputVideo, ov
 // meaning no other updates will be sent for this request.
 func (tsm TranscodeStatusMessage) IsTerminal() bool {
 	return tsm.Status == TranscodeStatusError ||
-		tsm.Status == TranscodeStatusCompleted
+		tsm.Status == TranscodeStatusCompleted ||
+		tsm.Status == TranscodeStatusCancelled
 }
 
 // Calculate the overall completion ratio based on the completion ratio of the current stage.
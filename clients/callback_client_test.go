@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/livepeer/catalyst-api/video"
 	"github.com/stretchr/testify/require"
 )
 
@@ -183,3 +184,57 @@ func TestItCalculatesTheOverallCompletionRatioCorrectly(t *testing.T) {
 		})
 	}
 }
+
+func TestItOnlySendsHeadersToTrustedHostsAndSignsCallbacks(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	bodies := make(chan []byte, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		headers <- r.Header
+		bodies <- body
+	}))
+	defer svr.Close()
+
+	for _, tt := range []struct {
+		trustedHosts []string
+		expectAuth   bool
+	}{
+		{trustedHosts: []string{"127.0.0.1"}, expectAuth: true},
+		{trustedHosts: []string{"studio.livepeer.com"}, expectAuth: false},
+		// no list keeps sending them to every host
+		{trustedHosts: []string{}, expectAuth: true},
+		{trustedHosts: nil, expectAuth: true},
+	} {
+		client := NewPeriodicCallbackClient(100*time.Hour, map[string]string{"Authorization": "Bearer secret-token"}).
+			WithTrustedHosts(tt.trustedHosts).
+			WithSigningSecrets([]string{"signing-secret"})
+		require.NoError(t, client.SendTranscodeStatus(NewTranscodeStatusCompleted(svr.URL, "example-request-id", video.InputVideo{}, nil)))
+
+		header, body := <-headers, <-bodies
+		if tt.expectAuth {
+			require.Equal(t, "Bearer secret-token", header.Get("Authorization"))
+		} else {
+			require.Empty(t, header.Get("Authorization"))
+		}
+		require.NoError(t, VerifyCallbackSignature(header.Get(CallbackSignatureHeader), body, "signing-secret", time.Now(), time.Minute))
+	}
+}
+
+func TestItKeepsFailedTerminalCallbacksInTheOutbox(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	outbox, err := NewCallbackOutbox(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+	client := NewPeriodicCallbackClient(100*time.Hour, map[string]string{}).WithOutbox(outbox)
+
+	// the failure is not reported to the caller, since the outbox will retry
+	require.NoError(t, client.SendTranscodeStatus(NewTranscodeStatusError(svr.URL, "example-request-id", "error", false)))
+	require.Len(t, outbox.pending, 1)
+	for _, entry := range outbox.pending {
+		require.Equal(t, 1, entry.Attempts)
+	}
+}
@@ -0,0 +1,272 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/metrics"
+)
+
+const (
+	outboxPendingDir    = "pending"
+	outboxDeadLetterDir = "dead-letter"
+	outboxFileExt       = ".json"
+
+	// Delay before the first retry of a failed callback, doubled for each further attempt
+	outboxInitialBackoff = 10 * time.Second
+	outboxMaxBackoff     = 15 * time.Minute
+)
+
+// CallbackOutbox persists the terminal status callbacks until they are
+// delivered, so that they survive a restart and a callback receiver outage
+// doesn't turn completed jobs into failed ones. Callbacks that still can't be
+// delivered after the max age are moved to the dead-letter list, from which
+// they can be re-delivered manually.
+//
+// Entries are stored as one JSON file each, in the "pending" and
+// "dead-letter" subdirectories.
+type CallbackOutbox struct {
+	dir    string
+	maxAge time.Duration
+
+	mu         sync.Mutex
+	pending    map[string]*OutboxEntry
+	deadLetter map[string]*OutboxEntry
+	// entries being delivered, to avoid sending the same one twice at once
+	inFlight map[string]bool
+}
+
+// OutboxEntry is a callback waiting in the outbox.
+type OutboxEntry struct {
+	ID            string                 `json:"id"`
+	URL           string                 `json:"url"`
+	Message       TranscodeStatusMessage `json:"message"`
+	Attempts      int                    `json:"attempts"`
+	LastError     string                 `json:"last_error,omitempty"`
+	CreatedAt     time.Time              `json:"created_at"`
+	NextAttemptAt time.Time              `json:"next_attempt_at"`
+}
+
+func NewCallbackOutbox(dir string, maxAge time.Duration) (*CallbackOutbox, error) {
+	o := &CallbackOutbox{
+		dir:        dir,
+		maxAge:     maxAge,
+		pending:    map[string]*OutboxEntry{},
+		deadLetter: map[string]*OutboxEntry{},
+		inFlight:   map[string]bool{},
+	}
+	for name, entries := range map[string]map[string]*OutboxEntry{outboxPendingDir: o.pending, outboxDeadLetterDir: o.deadLetter} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0700); err != nil {
+			return nil, fmt.Errorf("error creating callback outbox directory: %w", err)
+		}
+		if err := o.load(name, entries); err != nil {
+			return nil, err
+		}
+	}
+	o.updateMetrics()
+	return o, nil
+}
+
+func (o *CallbackOutbox) load(subdir string, entries map[string]*OutboxEntry) error {
+	files, err := os.ReadDir(filepath.Join(o.dir, subdir))
+	if err != nil {
+		return fmt.Errorf("error reading callback outbox directory: %w", err)
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), outboxFileExt) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(o.dir, subdir, f.Name()))
+		if err != nil {
+			return fmt.Errorf("error reading callback outbox entry %s: %w", f.Name(), err)
+		}
+		var entry OutboxEntry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			log.LogNoRequestID("skipping invalid callback outbox entry", "file", f.Name(), "err", err)
+			continue
+		}
+		entry.Message.URL = entry.URL
+		entries[entry.ID] = &entry
+	}
+	return nil
+}
+
+// Send persists a callback and makes the first delivery attempt. Failed
+// deliveries are retried by Deliver, so only an error to persist the callback
+// is returned.
+func (o *CallbackOutbox) Send(tsm TranscodeStatusMessage, send func(tsm TranscodeStatusMessage) error) error {
+	entry, err := o.add(tsm)
+	if err != nil {
+		return err
+	}
+	o.deliver(entry, send)
+	return nil
+}
+
+func (o *CallbackOutbox) add(tsm TranscodeStatusMessage) (*OutboxEntry, error) {
+	now := time.Now()
+	entry := &OutboxEntry{
+		ID:            fmt.Sprintf("%s-%d", tsm.RequestID, now.UnixNano()),
+		URL:           tsm.URL,
+		Message:       tsm,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.write(outboxPendingDir, entry); err != nil {
+		return nil, err
+	}
+	o.pending[entry.ID] = entry
+	o.inFlight[entry.ID] = true
+	o.updateMetrics()
+	return entry, nil
+}
+
+// Deliver sends the callbacks that are due, using the given function.
+func (o *CallbackOutbox) Deliver(send func(tsm TranscodeStatusMessage) error) {
+	now := time.Now()
+	var due []*OutboxEntry
+	o.mu.Lock()
+	for id, entry := range o.pending {
+		if !o.inFlight[id] && !entry.NextAttemptAt.After(now) {
+			o.inFlight[id] = true
+			due = append(due, entry)
+		}
+	}
+	o.mu.Unlock()
+
+	for _, entry := range due {
+		o.deliver(entry, send)
+	}
+}
+
+// deliver makes one attempt at sending the entry, which must have been marked
+// as in flight.
+func (o *CallbackOutbox) deliver(entry *OutboxEntry, send func(tsm TranscodeStatusMessage) error) {
+	err := send(entry.Message)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	defer o.updateMetrics()
+	delete(o.inFlight, entry.ID)
+
+	if err == nil {
+		delete(o.pending, entry.ID)
+		o.remove(outboxPendingDir, entry.ID)
+		return
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	if time.Since(entry.CreatedAt) >= o.maxAge {
+		log.Log(entry.Message.RequestID, "Giving up on callback, moving it to the dead-letter list", "attempts", entry.Attempts, "err", err)
+		if err := o.write(outboxDeadLetterDir, entry); err != nil {
+			log.LogError(entry.Message.RequestID, "error writing callback to the dead-letter list", err)
+			return
+		}
+		delete(o.pending, entry.ID)
+		o.remove(outboxPendingDir, entry.ID)
+		o.deadLetter[entry.ID] = entry
+		return
+	}
+
+	backoff := outboxInitialBackoff
+	for i := 1; i < entry.Attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	entry.NextAttemptAt = time.Now().Add(backoff)
+	if err := o.write(outboxPendingDir, entry); err != nil {
+		log.LogError(entry.Message.RequestID, "error updating callback outbox entry", err)
+	}
+}
+
+// DeadLetters returns the callbacks that couldn't be delivered, oldest first.
+func (o *CallbackOutbox) DeadLetters() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entries := make([]OutboxEntry, 0, len(o.deadLetter))
+	for _, entry := range o.deadLetter {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries
+}
+
+// Redeliver moves a dead-lettered callback back to the pending list, where it
+// gets retried for the max age again. Returns false if there's no dead-lettered
+// callback with the ID.
+func (o *CallbackOutbox) Redeliver(id string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry, ok := o.deadLetter[id]
+	if !ok {
+		return false, nil
+	}
+
+	now := time.Now()
+	entry.Attempts = 0
+	entry.CreatedAt = now
+	entry.NextAttemptAt = now
+	if err := o.write(outboxPendingDir, entry); err != nil {
+		return true, err
+	}
+	delete(o.deadLetter, id)
+	o.remove(outboxDeadLetterDir, id)
+	o.pending[id] = entry
+	o.updateMetrics()
+	return true, nil
+}
+
+func (o *CallbackOutbox) path(subdir, id string) string {
+	return filepath.Join(o.dir, subdir, url.PathEscape(id)+outboxFileExt)
+}
+
+// write saves the entry atomically. Must be called with the lock held.
+func (o *CallbackOutbox) write(subdir string, entry *OutboxEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshalling callback outbox entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Join(o.dir, subdir), "tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating callback outbox file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing callback outbox file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing callback outbox file: %w", err)
+	}
+	return os.Rename(tmp.Name(), o.path(subdir, entry.ID))
+}
+
+// must be called with the lock held
+func (o *CallbackOutbox) remove(subdir, id string) {
+	if err := os.Remove(o.path(subdir, id)); err != nil && !os.IsNotExist(err) {
+		log.LogNoRequestID("error deleting callback outbox entry", "id", id, "err", err)
+	}
+}
+
+// must be called with the lock held
+func (o *CallbackOutbox) updateMetrics() {
+	metrics.Metrics.CallbackOutboxSize.WithLabelValues(outboxPendingDir).Set(float64(len(o.pending)))
+	metrics.Metrics.CallbackOutboxSize.WithLabelValues(outboxDeadLetterDir).Set(float64(len(o.deadLetter)))
+}
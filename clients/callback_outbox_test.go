@@ -0,0 +1,69 @@
+package clients
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/video"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackOutboxRetriesAndDeadLetters(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	outbox, err := NewCallbackOutbox(dir, time.Hour)
+	require.NoError(err)
+
+	var sent []TranscodeStatusMessage
+	failing := func(tsm TranscodeStatusMessage) error { return fmt.Errorf("studio is down") }
+	working := func(tsm TranscodeStatusMessage) error {
+		sent = append(sent, tsm)
+		return nil
+	}
+
+	require.NoError(outbox.Send(NewTranscodeStatusCompleted("http://example.com/callback", "req-1", video.InputVideo{}, nil), failing))
+	require.Len(outbox.pending, 1)
+	var entry *OutboxEntry
+	for _, e := range outbox.pending {
+		entry = e
+	}
+	require.Equal(1, entry.Attempts)
+	require.True(entry.NextAttemptAt.After(time.Now()))
+
+	// the entry survives a restart and isn't retried before its backoff elapsed
+	outbox, err = NewCallbackOutbox(dir, 0)
+	require.NoError(err)
+	outbox.Deliver(working)
+	require.Empty(sent)
+
+	// past the max age, the entry is dead-lettered after the next failure
+	outbox.pending[entry.ID].NextAttemptAt = time.Now()
+	outbox.Deliver(failing)
+	deadLetters := outbox.DeadLetters()
+	require.Len(deadLetters, 1)
+	require.Equal("req-1", deadLetters[0].Message.RequestID)
+	require.Equal("studio is down", deadLetters[0].LastError)
+
+	outbox, err = NewCallbackOutbox(dir, time.Hour)
+	require.NoError(err)
+	require.Len(outbox.DeadLetters(), 1)
+
+	found, err := outbox.Redeliver("unknown")
+	require.False(found)
+	require.NoError(err)
+	found, err = outbox.Redeliver(entry.ID)
+	require.True(found)
+	require.NoError(err)
+	require.Empty(outbox.DeadLetters())
+
+	outbox.Deliver(working)
+	require.Len(sent, 1)
+	require.Equal("http://example.com/callback", sent[0].URL)
+	require.Equal(TranscodeStatusCompleted, sent[0].Status)
+
+	outbox, err = NewCallbackOutbox(dir, time.Hour)
+	require.NoError(err)
+	require.Empty(outbox.pending)
+	require.Empty(outbox.deadLetter)
+}
@@ -0,0 +1,85 @@
+package clients
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallbackSignatureHeader holds the HMAC-SHA256 signatures of the callback body,
+// in the format "t=<unix timestamp>,v1=<hex signature>[,v1=<hex signature>...]".
+// The signed payload is "<timestamp>.<body>", so that receivers can reject
+// callbacks replayed long after they were sent.
+//
+// There is one signature per configured secret, so that the secret can be
+// rotated without downtime: configure the new secret alongside the old one,
+// update the receivers to the new secret, then remove the old one.
+const CallbackSignatureHeader = "Livepeer-Signature"
+
+// SignCallback builds the signature header value for the given body.
+func SignCallback(body []byte, timestamp int64, secrets []string) string {
+	parts := []string{"t=" + strconv.FormatInt(timestamp, 10)}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+callbackSignature(body, timestamp, secret))
+	}
+	return strings.Join(parts, ",")
+}
+
+// VerifyCallbackSignature checks that one of the signatures in the header was
+// made with the given secret, and that the timestamp is within the tolerance.
+func VerifyCallbackSignature(header string, body []byte, secret string, now time.Time, tolerance time.Duration) error {
+	var timestamp int64
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid signature timestamp: %w", err)
+			}
+			timestamp = t
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == 0 || len(signatures) == 0 {
+		return fmt.Errorf("malformed signature header: %q", header)
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside of the tolerance: %s", age)
+	}
+
+	expected := callbackSignature(body, timestamp, secret)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no matching signature found")
+}
+
+func callbackSignature(body []byte, timestamp int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isTrustedHost returns whether the host matches the allowlist. Entries
+// starting with a dot match any subdomain, e.g. ".livepeer.com".
+func isTrustedHost(host string, trustedHosts []string) bool {
+	host = strings.ToLower(host)
+	for _, trusted := range trustedHosts {
+		trusted = strings.ToLower(trusted)
+		if host == trusted || (strings.HasPrefix(trusted, ".") && strings.HasSuffix(host, trusted)) {
+			return true
+		}
+	}
+	return false
+}
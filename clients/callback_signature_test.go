@@ -0,0 +1,34 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestItVerifiesCallbackSignatures(t *testing.T) {
+	body := []byte(`{"request_id":"123","status":"completed"}`)
+	now := time.Unix(1700000000, 0)
+	header := SignCallback(body, now.Unix(), []string{"new-secret", "old-secret"})
+	require.Regexp(t, `^t=1700000000,v1=[0-9a-f]{64},v1=[0-9a-f]{64}$`, header)
+
+	// both secrets are valid during a rotation
+	require.NoError(t, VerifyCallbackSignature(header, body, "new-secret", now, time.Minute))
+	require.NoError(t, VerifyCallbackSignature(header, body, "old-secret", now.Add(30*time.Second), time.Minute))
+
+	require.Error(t, VerifyCallbackSignature(header, body, "other-secret", now, time.Minute))
+	require.Error(t, VerifyCallbackSignature(header, []byte(`{"request_id":"123","status":"error"}`), "new-secret", now, time.Minute))
+	require.Error(t, VerifyCallbackSignature(header, body, "new-secret", now.Add(2*time.Minute), time.Minute))
+	require.Error(t, VerifyCallbackSignature("v1=abc", body, "new-secret", now, time.Minute))
+}
+
+func TestIsTrustedHost(t *testing.T) {
+	hosts := []string{"studio.livepeer.com", ".livepeer.monster"}
+	require.True(t, isTrustedHost("studio.livepeer.com", hosts))
+	require.True(t, isTrustedHost("Studio.Livepeer.com", hosts))
+	require.True(t, isTrustedHost("api.livepeer.monster", hosts))
+	require.False(t, isTrustedHost("livepeer.com", hosts))
+	require.False(t, isTrustedHost("evillivepeer.monster", hosts))
+	require.False(t, isTrustedHost("example.com", nil))
+}
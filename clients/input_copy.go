@@ -1,16 +1,19 @@
 package clients
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/grafov/m3u8"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/livepeer/catalyst-api/config"
 	"github.com/livepeer/catalyst-api/crypto"
@@ -25,7 +28,7 @@ const PresignDuration = 24 * time.Hour
 const LocalSourceFilePattern = "sourcevideo*"
 
 type InputCopier interface {
-	CopyInputToS3(requestID string, inputFile *url.URL, decryptor *crypto.DecryptionKeys) (video.InputVideo, string, *url.URL, error)
+	CopyInputToS3(ctx context.Context, requestID string, inputFile *url.URL, decryptor *crypto.DecryptionKeys) (video.InputVideo, string, *url.URL, error)
 }
 
 type InputCopy struct {
@@ -35,7 +38,7 @@ type InputCopy struct {
 }
 
 // CopyInputToS3 copies the input video to our S3 transfer bucket and probes the file.
-func (s *InputCopy) CopyInputToS3(requestID string, inputFile *url.URL, decryptor *crypto.DecryptionKeys) (inputVideoProbe video.InputVideo, signedURL string, osTransferURL *url.URL, err error) {
+func (s *InputCopy) CopyInputToS3(ctx context.Context, requestID string, inputFile *url.URL, decryptor *crypto.DecryptionKeys) (inputVideoProbe video.InputVideo, signedURL string, osTransferURL *url.URL, err error) {
 	if isDirectUpload(inputFile) && decryptor == nil {
 		log.Log(requestID, "Direct upload detected")
 		signedURL = inputFile.String()
@@ -52,7 +55,7 @@ func (s *InputCopy) CopyInputToS3(requestID string, inputFile *url.URL, decrypto
 		}
 		osTransferURL = sourceOutputUrl.JoinPath(requestID, "transfer", path.Base(inputFile.Path))
 
-		size, err = CopyAllInputFiles(requestID, inputFile, osTransferURL, decryptor)
+		size, err = CopyAllInputFiles(ctx, requestID, inputFile, osTransferURL, decryptor)
 		if err != nil {
 			err = fmt.Errorf("failed to copy file(s): %w", err)
 			return
@@ -73,21 +76,25 @@ func (s *InputCopy) CopyInputToS3(requestID string, inputFile *url.URL, decrypto
 		return
 	}
 	log.Log(requestID, "probe succeeded", "source", inputFile.String(), "dest", osTransferURL.String())
+	if inputVideoProbe.SizeBytes > config.MaxInputFileSizeBytes {
+		err = fmt.Errorf("input file %d bytes was greater than %d bytes", inputVideoProbe.SizeBytes, config.MaxInputFileSizeBytes)
+		return
+	}
+	audioTrack, _ := inputVideoProbe.GetTrack(video.TrackTypeAudio)
+	if inputVideoProbe.IsAudioOnly() {
+		log.Log(requestID, "probed audio-only input", "container", inputVideoProbe.Format, "codec", audioTrack.Codec, "bitrate", audioTrack.Bitrate, "duration", inputVideoProbe.Duration, "channels", audioTrack.Channels, "sample_rate", audioTrack.SampleRate)
+		return
+	}
 	videoTrack, err := inputVideoProbe.GetTrack(video.TrackTypeVideo)
 	if err != nil {
 		err = fmt.Errorf("no video track found in input video: %w", err)
 		return
 	}
-	audioTrack, _ := inputVideoProbe.GetTrack(video.TrackTypeAudio)
 	if videoTrack.FPS <= 0 {
 		// unsupported, includes things like motion jpegs
 		err = fmt.Errorf("invalid framerate: %f", videoTrack.FPS)
 		return
 	}
-	if inputVideoProbe.SizeBytes > config.MaxInputFileSizeBytes {
-		err = fmt.Errorf("input file %d bytes was greater than %d bytes", inputVideoProbe.SizeBytes, config.MaxInputFileSizeBytes)
-		return
-	}
 	log.Log(requestID, "probed video track:", "container", inputVideoProbe.Format, "codec", videoTrack.Codec, "bitrate", videoTrack.Bitrate, "duration", videoTrack.DurationSec, "w", videoTrack.Width, "h", videoTrack.Height, "pix-format", videoTrack.PixelFormat, "FPS", videoTrack.FPS)
 	log.Log(requestID, "probed audio track", "codec", audioTrack.Codec, "bitrate", audioTrack.Bitrate, "duration", audioTrack.DurationSec, "channels", audioTrack.Channels)
 	return
@@ -154,53 +161,193 @@ func getSegmentTransferLocation(srcManifestUrl, dstTransferUrl *url.URL, srcSegm
 	return newURL.String(), nil
 }
 
-// CopyAllInputFiles will copy the m3u8 manifest and all ts segments for HLS input whereas
+// CopyAllInputFiles will copy the m3u8 manifest and all its segments for HLS input whereas
 // it will copy just the single video file for MP4/MOV input
-func CopyAllInputFiles(requestID string, srcInputUrl, dstOutputUrl *url.URL, decryptor *crypto.DecryptionKeys) (size int64, err error) {
-	fileList := make(map[string]string)
+func CopyAllInputFiles(ctx context.Context, requestID string, srcInputUrl, dstOutputUrl *url.URL, decryptor *crypto.DecryptionKeys) (size int64, err error) {
 	if isHLSInput(srcInputUrl) {
-		// Download the m3u8 manifest using the input url
-		playlist, err := DownloadRenditionManifest(requestID, srcInputUrl.String())
+		return copyHLSInputFiles(ctx, requestID, srcInputUrl, dstOutputUrl, decryptor)
+	}
+
+	log.Log(requestID, "Copying input file to S3", "source", srcInputUrl.String(), "dest", dstOutputUrl.String())
+	size, err = CopyFileWithDecryption(ctx, srcInputUrl.String(), dstOutputUrl.String(), "", requestID, decryptor)
+	if err != nil {
+		return size, fmt.Errorf("error copying input file to S3: %w", err)
+	}
+	if size <= 0 {
+		return size, fmt.Errorf("zero bytes found for source: %s", srcInputUrl.String())
+	}
+	return size, nil
+}
+
+// transferPart is a byte range of a source file copied to its own file in the transfer location
+type transferPart struct {
+	Range ByteRange
+	Dest  string
+}
+
+// copyHLSInputFiles copies an HLS input to the transfer location as a Media
+// playlist that only references whole files next to it. Master playlists are
+// resolved to their highest variant, byte-range segments are split into their
+// own files and fMP4 init sections are copied once and kept as EXT-X-MAP.
+// Returns the number of bytes copied.
+func copyHLSInputFiles(ctx context.Context, requestID string, srcInputUrl, dstOutputUrl *url.URL, decryptor *crypto.DecryptionKeys) (int64, error) {
+	playlist, srcManifestUrl, err := DownloadMediaManifest(requestID, srcInputUrl.String())
+	if err != nil {
+		return 0, fmt.Errorf("error downloading HLS input manifest: %s", err)
+	}
+	if srcManifestUrl != srcInputUrl.String() {
+		log.Log(requestID, "Picked the highest variant of the HLS input master manifest", "variant", srcManifestUrl)
+	}
+	srcManifestParsedUrl, err := url.Parse(srcManifestUrl)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing HLS input manifest url: %s", err)
+	}
+	sourceSegments, err := GetSourceSegmentURLs(srcManifestUrl, playlist)
+	if err != nil {
+		return 0, fmt.Errorf("error generating source segment URLs for HLS input manifest: %s", err)
+	}
+
+	// Map every segment and init section to the file it will be copied to, so
+	// that files shared by several of them are only copied once
+	fileParts := make(map[string][]transferPart)
+	transferLocation := func(srcUrl *url.URL, r ByteRange, suffix string) (string, error) {
+		for _, part := range fileParts[srcUrl.String()] {
+			if part.Range == r {
+				return part.Dest, nil
+			}
+		}
+		dest, err := getSegmentTransferLocation(srcManifestParsedUrl, dstOutputUrl, srcUrl.String())
 		if err != nil {
-			return 0, fmt.Errorf("error downloading HLS input manifest: %s", err)
+			return "", fmt.Errorf("error generating an OS compatible transfer location for each segment: %s", err)
+		}
+		if r.Length > 0 {
+			ext := path.Ext(dest)
+			dest = fmt.Sprintf("%s_%s%s", strings.TrimSuffix(dest, ext), suffix, ext)
 		}
-		// Save the mapping between the input m3u8 manifest file to its corresponding OS-transfer destination url
-		fileList[srcInputUrl.String()] = dstOutputUrl.String()
-		// Now get a list of the OS-compatible segment URLs from the input manifest file
-		sourceSegmentUrls, err := GetSourceSegmentURLs(srcInputUrl.String(), playlist)
+		fileParts[srcUrl.String()] = append(fileParts[srcUrl.String()], transferPart{Range: r, Dest: dest})
+		return dest, nil
+	}
+
+	// Rewrite the manifest to reference the transferred files
+	var lastInitDest string
+	playlist.Map = nil
+	for i, sourceSegment := range sourceSegments {
+		segment := playlist.Segments[i]
+		dest, err := transferLocation(sourceSegment.URL, sourceSegment.Range, fmt.Sprintf("%d", i))
 		if err != nil {
-			return 0, fmt.Errorf("error generating source segment URLs for HLS input manifest: %s", err)
+			return 0, err
 		}
-		// Then save the mapping between the OS-compatible segment URLs to its OS-transfer destination url
-		for _, srcSegmentUrl := range sourceSegmentUrls {
-			u, err := getSegmentTransferLocation(srcInputUrl, dstOutputUrl, srcSegmentUrl.URL.String())
+		segment.URI = transferRelativePath(dstOutputUrl, dest)
+		segment.Limit, segment.Offset = 0, 0
+
+		segment.Map = nil
+		if sourceSegment.InitURL != nil {
+			initDest, err := transferLocation(sourceSegment.InitURL, sourceSegment.InitRange, "init")
 			if err != nil {
-				return 0, fmt.Errorf("error generating an OS compatible transfer location for each segment: %s", err)
+				return 0, err
+			}
+			if initDest != lastInitDest {
+				segment.Map = &m3u8.Map{URI: transferRelativePath(dstOutputUrl, initDest)}
+				lastInitDest = initDest
 			}
-			fileList[srcSegmentUrl.URL.String()] = u
 		}
-
-	} else {
-		fileList[srcInputUrl.String()] = dstOutputUrl.String()
 	}
 
 	var byteCount int64
-	for inFile, outFile := range fileList {
-		log.Log(requestID, "Copying input file to S3", "source", inFile, "dest", outFile)
+	for srcFile, parts := range fileParts {
+		size, err := copyFileParts(ctx, requestID, srcFile, parts, decryptor)
+		if err != nil {
+			return byteCount, err
+		}
+		byteCount += size
+	}
+
+	manifest := playlist.Encode().Bytes()
+	err = backoff.Retry(func() error {
+		return UploadToOSURL(dstOutputUrl.String(), "", bytes.NewReader(manifest), MANIFEST_UPLOAD_TIMEOUT)
+	}, UploadRetryBackoff())
+	if err != nil {
+		return byteCount, fmt.Errorf("error uploading HLS input manifest: %w", err)
+	}
+	return byteCount + int64(len(manifest)), nil
+}
 
-		size, err = CopyFileWithDecryption(context.Background(), inFile, outFile, "", requestID, decryptor)
+// transferRelativePath returns the path of a transferred file relative to the transferred manifest
+func transferRelativePath(dstManifestUrl *url.URL, dest string) string {
+	destUrl, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	return strings.TrimPrefix(destUrl.Path, path.Dir(dstManifestUrl.Path)+"/")
+}
 
+// copyFileParts copies a source file to the transfer location, either whole or
+// as one file per byte range, downloading it only once.
+func copyFileParts(ctx context.Context, requestID, srcFile string, parts []transferPart, decryptor *crypto.DecryptionKeys) (int64, error) {
+	if len(parts) == 1 && parts[0].Range.Length <= 0 {
+		log.Log(requestID, "Copying input file to S3", "source", srcFile, "dest", parts[0].Dest)
+		size, err := CopyFileWithDecryption(ctx, srcFile, parts[0].Dest, "", requestID, decryptor)
 		if err != nil {
-			err = fmt.Errorf("error copying input file to S3: %w", err)
-			return size, err
+			return size, fmt.Errorf("error copying input file to S3: %w", err)
 		}
 		if size <= 0 {
-			err = fmt.Errorf("zero bytes found for source: %s", inFile)
-			return size, err
+			return size, fmt.Errorf("zero bytes found for source: %s", srcFile)
 		}
-		byteCount = size + byteCount
+		return size, nil
 	}
-	return size, nil
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Range.Offset < parts[j].Range.Offset
+	})
+	for i, part := range parts {
+		if part.Range.Length <= 0 {
+			return 0, xerrors.Unretriable(fmt.Errorf("source used both whole and as byte ranges: %s", srcFile))
+		}
+		if i > 0 && part.Range.Offset < parts[i-1].Range.Offset+parts[i-1].Range.Length {
+			return 0, xerrors.Unretriable(fmt.Errorf("overlapping byte ranges found for source: %s", srcFile))
+		}
+	}
+
+	log.Log(requestID, "Copying input file byte ranges to S3", "source", srcFile, "ranges", len(parts))
+	var byteCount int64
+	err := backoff.Retry(func() error {
+		ctx, cancel := context.WithTimeout(ctx, MaxCopyFileDuration)
+		defer cancel()
+		byteCount = 0
+
+		rc, err := GetFile(ctx, requestID, srcFile, NewDStorageDownload())
+		if err != nil {
+			return fmt.Errorf("download error: %w", err)
+		}
+		defer rc.Close()
+		var content io.Reader = rc
+		if decryptor != nil {
+			content, err = crypto.DecryptAESCBC(rc, decryptor.DecryptKey, decryptor.EncryptedKey)
+			if err != nil {
+				return fmt.Errorf("error decrypting file: %w", err)
+			}
+		}
+
+		var position int64
+		for _, part := range parts {
+			if _, err := io.CopyN(io.Discard, content, part.Range.Offset-position); err != nil {
+				return fmt.Errorf("error seeking to byte range offset %d: %w", part.Range.Offset, err)
+			}
+			byteAccWriter := ByteAccumulatorWriter{count: 0}
+			err := UploadToOSURL(part.Dest, "", io.TeeReader(io.LimitReader(content, part.Range.Length), &byteAccWriter), MaxCopyFileDuration)
+			if err != nil {
+				log.Log(requestID, "Copy attempt failed", "source", srcFile, "dest", part.Dest, "err", err)
+				return err
+			}
+			if byteAccWriter.count != part.Range.Length {
+				return backoff.Permanent(fmt.Errorf("byte range %d@%d is past the end of the source file", part.Range.Length, part.Range.Offset))
+			}
+			byteCount += byteAccWriter.count
+			position = part.Range.Offset + part.Range.Length
+		}
+		return nil
+	}, backoff.WithContext(UploadRetryBackoff(), ctx))
+	return byteCount, err
 }
 
 func isDirectUpload(inputFile *url.URL) bool {
@@ -243,7 +390,7 @@ func CopyFileWithDecryption(ctx context.Context, sourceURL, destOSBaseURL, filen
 			log.Log(requestID, "Copy attempt failed", "source", sourceURL, "dest", path.Join(destOSBaseURL, filename), "err", err)
 		}
 		return err
-	}, UploadRetryBackoff())
+	}, backoff.WithContext(UploadRetryBackoff(), ctx))
 	return
 }
 
@@ -279,13 +426,24 @@ func newRetryableHttpClient() *http.Client {
 }
 
 func getFileHTTP(ctx context.Context, url string) (io.ReadCloser, error) {
+	rc, _, err := getFileHTTPRange(ctx, url, "")
+	return rc, err
+}
+
+// getFileHTTPRange sends byteRange, when set, as the Range header of the
+// request. Also returns whether the server answered with just that range,
+// since it is free to ignore the header and send the whole file.
+func getFileHTTPRange(ctx context.Context, url, byteRange string) (io.ReadCloser, bool, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, xerrors.Unretriable(fmt.Errorf("error creating http request: %w", err))
+		return nil, false, xerrors.Unretriable(fmt.Errorf("error creating http request: %w", err))
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
 	}
 	resp, err := retryableHttpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error on import request: %w", err)
+		return nil, false, fmt.Errorf("error on import request: %w", err)
 	}
 	if resp.StatusCode >= 300 {
 		resp.Body.Close()
@@ -293,13 +451,13 @@ func getFileHTTP(ctx context.Context, url string) (io.ReadCloser, error) {
 		if resp.StatusCode < 500 {
 			err = xerrors.Unretriable(err)
 		}
-		return nil, err
+		return nil, false, err
 	}
-	return resp.Body, nil
+	return resp.Body, byteRange != "" && resp.StatusCode == http.StatusPartialContent, nil
 }
 
 type StubInputCopy struct{}
 
-func (s *StubInputCopy) CopyInputToS3(requestID string, inputFile *url.URL, decryptor *crypto.DecryptionKeys) (video.InputVideo, string, *url.URL, error) {
+func (s *StubInputCopy) CopyInputToS3(ctx context.Context, requestID string, inputFile *url.URL, decryptor *crypto.DecryptionKeys) (video.InputVideo, string, *url.URL, error) {
 	return video.InputVideo{}, "", &url.URL{}, nil
 }
@@ -1,7 +1,10 @@
 package clients
 
 import (
+	"context"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -130,3 +133,55 @@ func Test_getSegmentTransferLocation(t *testing.T) {
 		})
 	}
 }
+
+func TestItCopiesMasterPlaylistAndByteRangeFMP4Inputs(t *testing.T) {
+	srcDir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720
+high/index.m3u8
+`
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "master.m3u8"), []byte(master), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "high"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "high", "index.m3u8"), []byte(byteRangeFMP4Manifest), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "high", "video.mp4"), []byte("INITaaaaabbbcccccc"), 0644))
+
+	dstDir := t.TempDir()
+	src, err := url.Parse(filepath.Join(srcDir, "master.m3u8"))
+	require.NoError(t, err)
+	dst, err := url.Parse("file://" + filepath.Join(dstDir, "master.m3u8"))
+	require.NoError(t, err)
+
+	_, err = CopyAllInputFiles(context.Background(), "blah", src, dst, nil)
+	require.NoError(t, err)
+
+	// every byte range is copied to its own file
+	for file, expected := range map[string]string{
+		"video_init.mp4": "INIT",
+		"video_0.mp4":    "aaaaa",
+		"video_1.mp4":    "bbb",
+		"video_2.mp4":    "cccccc",
+	} {
+		data, err := os.ReadFile(filepath.Join(dstDir, file))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(data), file)
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dstDir, "master.m3u8"))
+	require.NoError(t, err)
+	require.Equal(t, `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-TARGETDURATION:4
+#EXT-X-MAP:URI="video_init.mp4"
+#EXTINF:4.000,
+video_0.mp4
+#EXTINF:4.000,
+video_1.mp4
+#EXTINF:2.000,
+video_2.mp4
+#EXT-X-ENDLIST
+`, string(manifest))
+}
@@ -2,7 +2,9 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"path"
 	"sort"
@@ -12,6 +14,7 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/grafov/m3u8"
 	"github.com/livepeer/catalyst-api/video"
+	"github.com/livepeer/go-tools/drivers"
 )
 
 const (
@@ -23,22 +26,25 @@ func DownloadRetryBackoff() backoff.BackOff {
 	return backoff.WithMaxRetries(backoff.NewConstantBackOff(5*time.Second), 10)
 }
 
-func DownloadRenditionManifest(requestID, sourceManifestOSURL string) (m3u8.MediaPlaylist, error) {
-	var playlist m3u8.Playlist
-	var playlistType m3u8.ListType
-
+func downloadManifest(requestID, manifestOSURL string) (playlist m3u8.Playlist, playlistType m3u8.ListType, err error) {
 	dStorage := NewDStorageDownload()
-	err := backoff.Retry(func() error {
-		rc, err := GetFile(context.Background(), requestID, sourceManifestOSURL, dStorage)
+	err = backoff.Retry(func() error {
+		rc, err := GetFile(context.Background(), requestID, manifestOSURL, dStorage)
 		if err != nil {
 			return fmt.Errorf("error downloading manifest: %s", err)
 		}
+		defer rc.Close()
 		playlist, playlistType, err = m3u8.DecodeFrom(rc, true)
 		if err != nil {
 			return fmt.Errorf("error decoding manifest: %s", err)
 		}
 		return nil
 	}, DownloadRetryBackoff())
+	return
+}
+
+func DownloadRenditionManifest(requestID, sourceManifestOSURL string) (m3u8.MediaPlaylist, error) {
+	playlist, playlistType, err := downloadManifest(requestID, sourceManifestOSURL)
 	if err != nil {
 		return m3u8.MediaPlaylist{}, err
 	}
@@ -57,14 +63,75 @@ func DownloadRenditionManifest(requestID, sourceManifestOSURL string) (m3u8.Medi
 	return *mediaPlaylist, nil
 }
 
+// DownloadMediaManifest downloads an HLS input manifest, which can also be a
+// Master manifest, in which case the variant with the highest bandwidth is
+// picked. Returns the Media playlist along with its URL.
+func DownloadMediaManifest(requestID, manifestOSURL string) (m3u8.MediaPlaylist, string, error) {
+	playlist, playlistType, err := downloadManifest(requestID, manifestOSURL)
+	if err != nil {
+		return m3u8.MediaPlaylist{}, "", err
+	}
+	if playlistType == m3u8.MEDIA {
+		mediaPlaylist, ok := playlist.(*m3u8.MediaPlaylist)
+		if !ok || mediaPlaylist == nil {
+			return m3u8.MediaPlaylist{}, "", fmt.Errorf("failed to parse playlist as MediaPlaylist")
+		}
+		return *mediaPlaylist, manifestOSURL, nil
+	}
+
+	masterPlaylist, ok := playlist.(*m3u8.MasterPlaylist)
+	if !ok || masterPlaylist == nil {
+		return m3u8.MediaPlaylist{}, "", fmt.Errorf("failed to parse playlist as MasterPlaylist")
+	}
+	variant := highestVariant(masterPlaylist)
+	if variant == nil {
+		return m3u8.MediaPlaylist{}, "", fmt.Errorf("no variants found in master manifest")
+	}
+	variantURL, err := ManifestURLToSegmentURL(manifestOSURL, variant.URI)
+	if err != nil {
+		return m3u8.MediaPlaylist{}, "", err
+	}
+	mediaPlaylist, err := DownloadRenditionManifest(requestID, variantURL.String())
+	if err != nil {
+		return m3u8.MediaPlaylist{}, "", fmt.Errorf("error downloading variant manifest: %w", err)
+	}
+	return mediaPlaylist, variantURL.String(), nil
+}
+
+// highestVariant returns the variant with the highest bandwidth, ignoring the I-frame only ones
+func highestVariant(masterPlaylist *m3u8.MasterPlaylist) *m3u8.Variant {
+	var highest *m3u8.Variant
+	for _, variant := range masterPlaylist.Variants {
+		if variant == nil || variant.Iframe {
+			continue
+		}
+		if highest == nil || variant.Bandwidth > highest.Bandwidth {
+			highest = variant
+		}
+	}
+	return highest
+}
+
+// ByteRange is the part of a file holding a segment or an init section, from
+// EXT-X-BYTERANGE. A zero Length means the whole file.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
 type SourceSegment struct {
 	URL            *url.URL
 	DurationMillis int64
+	Range          ByteRange
+	// The fMP4 init section of the segment, from EXT-X-MAP. Nil for MPEG-TS segments.
+	InitURL   *url.URL
+	InitRange ByteRange
 }
 
 // Loop over each segment in a given manifest and convert it from a relative path to a full ObjectStore-compatible URL
 func GetSourceSegmentURLs(sourceManifestURL string, manifest m3u8.MediaPlaylist) ([]SourceSegment, error) {
 	var urls []SourceSegment
+	initSection := manifest.Map
 	for _, segment := range manifest.Segments {
 		// The segments list is a ring buffer - see https://github.com/grafov/m3u8/issues/140
 		// and so we only know we've hit the end of the list when we find a nil element
@@ -76,23 +143,160 @@ func GetSourceSegmentURLs(sourceManifestURL string, manifest m3u8.MediaPlaylist)
 		if err != nil {
 			return nil, err
 		}
-		urls = append(
-			urls,
-			SourceSegment{
-				URL:            u,
-				DurationMillis: int64(segment.Duration * 1000),
-			},
-		)
+		sourceSegment := SourceSegment{
+			URL:            u,
+			DurationMillis: int64(segment.Duration * 1000),
+			Range:          ByteRange{Offset: segment.Offset, Length: segment.Limit},
+		}
+		// A byte range without an offset starts where the previous one of the same file ended
+		if segment.Limit > 0 && segment.Offset == 0 && len(urls) > 0 {
+			previous := urls[len(urls)-1]
+			if previous.Range.Length > 0 && previous.URL.String() == u.String() {
+				sourceSegment.Range.Offset = previous.Range.Offset + previous.Range.Length
+			}
+		}
+
+		// An EXT-X-MAP applies to all the following segments, until the next one
+		if segment.Map != nil {
+			initSection = segment.Map
+		}
+		if initSection != nil {
+			sourceSegment.InitURL, err = ManifestURLToSegmentURL(sourceManifestURL, initSection.URI)
+			if err != nil {
+				return nil, err
+			}
+			sourceSegment.InitRange = ByteRange{Offset: initSection.Offset, Length: initSection.Limit}
+		}
+		urls = append(urls, sourceSegment)
 	}
 	return urls, nil
 }
 
+// GetSourceSegment downloads a source segment, prefixed with its init section
+// for fMP4 segments so that it can be decoded on its own.
+func GetSourceSegment(ctx context.Context, requestID string, segment SourceSegment) (io.ReadCloser, error) {
+	rc, err := getByteRange(ctx, requestID, segment.URL.String(), segment.Range)
+	if err != nil {
+		return nil, err
+	}
+	if segment.InitURL == nil {
+		return rc, nil
+	}
+	initRC, err := getByteRange(ctx, requestID, segment.InitURL.String(), segment.InitRange)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("failed to download init section: %w", err)
+	}
+	return &multiReadCloser{
+		Reader:  io.MultiReader(initRC, rc),
+		closers: []io.Closer{initRC, rc},
+	}, nil
+}
+
+// httpHeader returns the range in the format of the HTTP Range header, which
+// the storage drivers use too
+func (r ByteRange) httpHeader() string {
+	return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+}
+
+// getByteRange requests just the byte range from the storage when it
+// supports ranged reads, so that reading all the ranges of a file doesn't
+// download it once per range.
+func getByteRange(ctx context.Context, requestID, fileURL string, r ByteRange) (io.ReadCloser, error) {
+	if r.Length <= 0 {
+		return GetFile(ctx, requestID, fileURL, nil)
+	}
+
+	var rc io.ReadCloser
+	ranged := false
+	if _, err := drivers.ParseOSURL(fileURL, true); err == nil {
+		fileInfoReader, err := GetOSURL(fileURL, r.httpHeader())
+		if err == nil {
+			rc, ranged = fileInfoReader.Body, true
+		} else if errors.Is(err, drivers.ErrNotSupported) {
+			if rc, err = DownloadOSURL(fileURL); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		if rc, ranged, err = getFileHTTPRange(ctx, fileURL, r.httpHeader()); err != nil {
+			return nil, err
+		}
+	}
+
+	if !ranged {
+		if err := skipTo(rc, r.Offset); err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("failed to seek to byte range offset %d: %w", r.Offset, err)
+		}
+	}
+	return &multiReadCloser{
+		Reader:  io.LimitReader(rc, r.Length),
+		closers: []io.Closer{rc},
+	}, nil
+}
+
+// skipTo moves a whole file reader to offset, seeking when it is a local file
+func skipTo(rc io.ReadCloser, offset int64) error {
+	if seeker, ok := rc.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, rc, offset)
+	return err
+}
+
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if cerr := c.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Names of the groups of the alternate renditions in the master manifest
+const (
+	AUDIO_GROUP_ID     = "audio"
+	SUBTITLES_GROUP_ID = "subtitles"
+)
+
 // Generate a Master manifest, plus one Rendition manifest for each Profile we're transcoding, then write them to storage
+// The audio and subtitles renditions, if any, are added as alternate groups referenced by all the variants. Their
+// segments must have been written already, as <rendition>/<index>.ts and <rendition>/<index>.vtt respectively.
 // Returns the master manifest URL on success
-func GenerateAndUploadManifests(sourceManifest m3u8.MediaPlaylist, targetOSURL string, transcodedStats []*video.RenditionStats) (string, error) {
+func GenerateAndUploadManifests(sourceManifest m3u8.MediaPlaylist, targetOSURL string, transcodedStats, audioStats, subtitleStats []*video.RenditionStats) (string, error) {
 	// Generate the master + rendition output manifests
 	masterPlaylist := m3u8.NewMasterPlaylist()
 
+	var alternatives []*m3u8.Alternative
+	audioNames := map[string]bool{}
+	for i, audio := range audioStats {
+		alternative, err := uploadAlternative(sourceManifest, targetOSURL, audio, "AUDIO", AUDIO_GROUP_ID, "ts", audioNames)
+		if err != nil {
+			return "", err
+		}
+		alternative.Default = i == 0
+		alternatives = append(alternatives, alternative)
+	}
+	subtitlesNames := map[string]bool{}
+	for _, subtitles := range subtitleStats {
+		// subtitles are off until the viewer picks them
+		alternative, err := uploadAlternative(sourceManifest, targetOSURL, subtitles, "SUBTITLES", SUBTITLES_GROUP_ID, "vtt", subtitlesNames)
+		if err != nil {
+			return "", err
+		}
+		alternatives = append(alternatives, alternative)
+	}
+
 	sort.Slice(transcodedStats, func(a, b int) bool {
 		if transcodedStats[a].BitsPerSecond > transcodedStats[b].BitsPerSecond {
 			return true
@@ -108,55 +312,42 @@ func GenerateAndUploadManifests(sourceManifest m3u8.MediaPlaylist, targetOSURL s
 
 	for i, profile := range transcodedStats {
 		// For each profile, add a new entry to the master manifest
+		params := m3u8.VariantParams{
+			Name:      fmt.Sprintf("%d-%s", i, profile.Name),
+			Bandwidth: profile.BitsPerSecond,
+			FrameRate: profile.FPS,
+			Codecs:    profile.Codecs,
+		}
+		// BANDWIDTH is the peak bitrate of the segments when it's known
+		if profile.PeakBitsPerSecond > profile.BitsPerSecond {
+			params.Bandwidth = profile.PeakBitsPerSecond
+			params.AverageBandwidth = profile.BitsPerSecond
+		}
+		// audio renditions have no resolution
+		if profile.Width > 0 || profile.Height > 0 {
+			params.Resolution = fmt.Sprintf("%dx%d", profile.Width, profile.Height)
+		}
+		if len(audioStats) > 0 {
+			params.Audio = AUDIO_GROUP_ID
+		}
+		if len(subtitleStats) > 0 {
+			params.Subtitles = SUBTITLES_GROUP_ID
+		}
+		params.Alternatives = alternatives
 		masterPlaylist.Append(
 			path.Join(profile.Name, "index.m3u8"),
 			&m3u8.MediaPlaylist{
 				TargetDuration: sourceManifest.TargetDuration,
 			},
-			m3u8.VariantParams{
-				Name:       fmt.Sprintf("%d-%s", i, profile.Name),
-				Bandwidth:  profile.BitsPerSecond,
-				FrameRate:  float64(profile.FPS),
-				Resolution: fmt.Sprintf("%dx%d", profile.Width, profile.Height),
-			},
+			params,
 		)
 
 		// For each profile, create and upload a new rendition manifest
-		renditionPlaylist, err := m3u8.NewMediaPlaylist(sourceManifest.WinSize(), sourceManifest.Count())
-		if err != nil {
-			return "", fmt.Errorf("failed to create rendition manifest for profile %q: %s", profile.Name, err)
-		}
-
-		// Add segments to the manifest
-		for i, sourceSegment := range sourceManifest.Segments {
-			// The segments list is a ring buffer - see https://github.com/grafov/m3u8/issues/140
-			// and so we only know we've hit the end of the list when we find a nil element
-			if sourceSegment == nil {
-				break
-			}
-			err := renditionPlaylist.Append(fmt.Sprintf("%d.ts", i), sourceSegment.Duration, "")
-			if err != nil {
-				return "", fmt.Errorf("failed to append to rendition playlist number %d: %s", i, err)
-			}
-		}
-
-		// Write #EXT-X-ENDLIST
-		renditionPlaylist.Close()
-
-		manifestFilename := "index.m3u8"
-		renditionManifestBaseURL := fmt.Sprintf("%s/%s", targetOSURL, profile.Name)
-		err = backoff.Retry(func() error {
-			return UploadToOSURL(renditionManifestBaseURL, manifestFilename, strings.NewReader(renditionPlaylist.String()), MANIFEST_UPLOAD_TIMEOUT)
-		}, UploadRetryBackoff())
+		manifestLocation, err := uploadRenditionManifest(sourceManifest, targetOSURL, profile.Name, "ts")
 		if err != nil {
-			return "", fmt.Errorf("failed to upload rendition playlist: %s", err)
-		}
-		// update manifest location
-		transcodedStats[i].ManifestLocation, err = url.JoinPath(renditionManifestBaseURL, manifestFilename)
-		if err != nil {
-			// should not block the ingestion flow or make it fail on error.
-			transcodedStats[i].ManifestLocation = ""
+			return "", err
 		}
+		transcodedStats[i].ManifestLocation = manifestLocation
 	}
 
 	err := backoff.Retry(func() error {
@@ -174,6 +365,83 @@ func GenerateAndUploadManifests(sourceManifest m3u8.MediaPlaylist, targetOSURL s
 	return res, nil
 }
 
+// uploadAlternative writes the manifest of an alternate rendition and returns
+// its entry in the master manifest. The NAME must be unique within the group,
+// which usedNames keeps track of, so the rendition name is used when the title
+// or language is already taken.
+func uploadAlternative(sourceManifest m3u8.MediaPlaylist, targetOSURL string, stats *video.RenditionStats, mediaType, groupID, segmentExt string, usedNames map[string]bool) (*m3u8.Alternative, error) {
+	manifestLocation, err := uploadRenditionManifest(sourceManifest, targetOSURL, stats.Name, segmentExt)
+	if err != nil {
+		return nil, err
+	}
+	stats.ManifestLocation = manifestLocation
+
+	name := stats.Title
+	if name == "" {
+		name = stats.Language
+	}
+	if name == "" || usedNames[name] {
+		name = stats.Name
+	}
+	for i := 2; usedNames[name]; i++ {
+		name = fmt.Sprintf("%s-%d", stats.Name, i)
+	}
+	usedNames[name] = true
+	return &m3u8.Alternative{
+		Type:       mediaType,
+		GroupId:    groupID,
+		Name:       name,
+		Language:   stats.Language,
+		Autoselect: "YES",
+		URI:        path.Join(stats.Name, "index.m3u8"),
+	}, nil
+}
+
+// uploadRenditionManifest writes the manifest of a rendition, with the same
+// segments as the source, and returns its location.
+func uploadRenditionManifest(sourceManifest m3u8.MediaPlaylist, targetOSURL, rendition, segmentExt string) (string, error) {
+	renditionPlaylist, err := m3u8.NewMediaPlaylist(sourceManifest.WinSize(), sourceManifest.Count())
+	if err != nil {
+		return "", fmt.Errorf("failed to create rendition manifest for profile %q: %s", rendition, err)
+	}
+
+	// Add segments to the manifest
+	for i, sourceSegment := range sourceManifest.Segments {
+		// The segments list is a ring buffer - see https://github.com/grafov/m3u8/issues/140
+		// and so we only know we've hit the end of the list when we find a nil element
+		if sourceSegment == nil {
+			break
+		}
+		err := renditionPlaylist.Append(fmt.Sprintf("%d.%s", i, segmentExt), sourceSegment.Duration, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to append to rendition playlist number %d: %s", i, err)
+		}
+		if sourceSegment.Discontinuity {
+			if err := renditionPlaylist.SetDiscontinuity(); err != nil {
+				return "", fmt.Errorf("failed to set discontinuity in rendition playlist number %d: %s", i, err)
+			}
+		}
+	}
+
+	// Write #EXT-X-ENDLIST
+	renditionPlaylist.Close()
+
+	manifestFilename := "index.m3u8"
+	renditionManifestBaseURL := fmt.Sprintf("%s/%s", targetOSURL, rendition)
+	err = backoff.Retry(func() error {
+		return UploadToOSURL(renditionManifestBaseURL, manifestFilename, strings.NewReader(renditionPlaylist.String()), MANIFEST_UPLOAD_TIMEOUT)
+	}, UploadRetryBackoff())
+	if err != nil {
+		return "", fmt.Errorf("failed to upload rendition playlist: %s", err)
+	}
+	manifestLocation, err := url.JoinPath(renditionManifestBaseURL, manifestFilename)
+	if err != nil {
+		// should not block the ingestion flow or make it fail on error.
+		return "", nil
+	}
+	return manifestLocation, nil
+}
+
 func ManifestURLToSegmentURL(manifestURL, segmentFilename string) (*url.URL, error) {
 	base, err := url.Parse(manifestURL)
 	if err != nil {
@@ -1,10 +1,16 @@
 package clients
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafov/m3u8"
 	"github.com/livepeer/catalyst-api/video"
@@ -122,6 +128,8 @@ func TestItCanGenerateAndWriteManifests(t *testing.T) {
 				BitsPerSecond: 1,
 			},
 		},
+		nil,
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -147,6 +155,183 @@ lowlowlow/index.m3u8
 	require.NoFileExists(t, filepath.Join(outputDir, "small-high-def/index.m3u8"))
 }
 
+func TestItCanGenerateAudioOnlyManifests(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(validMediaManifest), true)
+	require.NoError(t, err)
+	sourceMediaPlaylist, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+
+	outputDir, err := os.MkdirTemp(os.TempDir(), "TestItCanGenerateAudioOnlyManifests-*")
+	require.NoError(t, err)
+
+	_, err = GenerateAndUploadManifests(
+		*sourceMediaPlaylist,
+		outputDir,
+		[]*video.RenditionStats{
+			{Name: "audio-64k", BitsPerSecond: 64000, Codecs: video.AACCodecs},
+			{Name: "audio-128k", BitsPerSecond: 128000, Codecs: video.AACCodecs},
+		},
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+
+	masterManifestContents, err := os.ReadFile(filepath.Join(outputDir, "index.m3u8"))
+	require.NoError(t, err)
+	const expectedMasterManifest = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=128000,CODECS="mp4a.40.2",NAME="0-audio-128k"
+audio-128k/index.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=64000,CODECS="mp4a.40.2",NAME="1-audio-64k"
+audio-64k/index.m3u8
+`
+	require.Equal(t, expectedMasterManifest, string(masterManifestContents))
+}
+
+func TestItCanGenerateAlternateAudioManifests(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(validMediaManifest), true)
+	require.NoError(t, err)
+	sourceMediaPlaylist, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+
+	outputDir, err := os.MkdirTemp(os.TempDir(), "TestItCanGenerateAlternateAudioManifests-*")
+	require.NoError(t, err)
+
+	audioStats := []*video.RenditionStats{
+		{Name: "audio-0-eng", Language: "eng", Title: "English", BitsPerSecond: 128000, Codecs: video.AACCodecs},
+		{Name: "audio-1-fra", Language: "fra", BitsPerSecond: 128000, Codecs: video.AACCodecs},
+	}
+	_, err = GenerateAndUploadManifests(
+		*sourceMediaPlaylist,
+		outputDir,
+		[]*video.RenditionStats{
+			{Name: "360p0", FPS: 30, Width: 640, Height: 360, BitsPerSecond: 1000000},
+		},
+		audioStats,
+		nil,
+	)
+	require.NoError(t, err)
+
+	masterManifestContents, err := os.ReadFile(filepath.Join(outputDir, "index.m3u8"))
+	require.NoError(t, err)
+	const expectedMasterManifest = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="English",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="eng",URI="audio-0-eng/index.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio",NAME="fra",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="fra",URI="audio-1-fra/index.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,RESOLUTION=640x360,AUDIO="audio",NAME="0-360p0",FRAME-RATE=30.000
+360p0/index.m3u8
+`
+	require.Equal(t, expectedMasterManifest, string(masterManifestContents))
+	require.FileExists(t, filepath.Join(outputDir, "audio-0-eng/index.m3u8"))
+	require.Equal(t, filepath.Join(outputDir, "audio-1-fra/index.m3u8"), audioStats[1].ManifestLocation)
+}
+
+func TestItWritesThePeakAndAverageBandwidth(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(validMediaManifest), true)
+	require.NoError(t, err)
+	sourceMediaPlaylist, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+
+	outputDir, err := os.MkdirTemp(os.TempDir(), "TestItWritesThePeakAndAverageBandwidth-*")
+	require.NoError(t, err)
+
+	_, err = GenerateAndUploadManifests(
+		*sourceMediaPlaylist,
+		outputDir,
+		[]*video.RenditionStats{
+			{Name: "720p0", FPS: 29.97, Width: 1280, Height: 718, BitsPerSecond: 2000000, PeakBitsPerSecond: 2600000, Codecs: "avc1.64001F,mp4a.40.2"},
+			// constant bitrate segments only get the BANDWIDTH
+			{Name: "360p0", FPS: 29.97, Width: 640, Height: 360, BitsPerSecond: 800000, PeakBitsPerSecond: 800000, Codecs: "avc1.64001E,mp4a.40.2"},
+		},
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+
+	masterManifestContents, err := os.ReadFile(filepath.Join(outputDir, "index.m3u8"))
+	require.NoError(t, err)
+	const expectedMasterManifest = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=2600000,AVERAGE-BANDWIDTH=2000000,CODECS="avc1.64001F,mp4a.40.2",RESOLUTION=1280x718,NAME="0-720p0",FRAME-RATE=29.970
+720p0/index.m3u8
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=800000,CODECS="avc1.64001E,mp4a.40.2",RESOLUTION=640x360,NAME="1-360p0",FRAME-RATE=29.970
+360p0/index.m3u8
+`
+	require.Equal(t, expectedMasterManifest, string(masterManifestContents))
+}
+
+func TestItCanGenerateSubtitleManifests(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(validMediaManifest), true)
+	require.NoError(t, err)
+	sourceMediaPlaylist, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+
+	outputDir, err := os.MkdirTemp(os.TempDir(), "TestItCanGenerateSubtitleManifests-*")
+	require.NoError(t, err)
+
+	_, err = GenerateAndUploadManifests(
+		*sourceMediaPlaylist,
+		outputDir,
+		[]*video.RenditionStats{
+			{Name: "360p0", FPS: 30, Width: 640, Height: 360, BitsPerSecond: 1000000},
+		},
+		nil,
+		[]*video.RenditionStats{
+			{Name: "captions-0-en", Language: "en", Title: "English"},
+		},
+	)
+	require.NoError(t, err)
+
+	masterManifestContents, err := os.ReadFile(filepath.Join(outputDir, "index.m3u8"))
+	require.NoError(t, err)
+	const expectedMasterManifest = `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subtitles",NAME="English",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="en",URI="captions-0-en/index.m3u8"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=1000000,RESOLUTION=640x360,SUBTITLES="subtitles",NAME="0-360p0",FRAME-RATE=30.000
+360p0/index.m3u8
+`
+	require.Equal(t, expectedMasterManifest, string(masterManifestContents))
+
+	subtitlesManifestContents, err := os.ReadFile(filepath.Join(outputDir, "captions-0-en/index.m3u8"))
+	require.NoError(t, err)
+	require.Contains(t, string(subtitlesManifestContents), "0.vtt\n")
+	require.NotContains(t, string(subtitlesManifestContents), ".ts")
+}
+
+func TestItKeepsTheDiscontinuitiesOfTheSource(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(`#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXTINF:4.000,
+0.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:10.000,
+1.ts
+#EXT-X-ENDLIST
+`), true)
+	require.NoError(t, err)
+	sourceMediaPlaylist, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+
+	outputDir, err := os.MkdirTemp(os.TempDir(), "TestItKeepsTheDiscontinuitiesOfTheSource-*")
+	require.NoError(t, err)
+
+	_, err = GenerateAndUploadManifests(
+		*sourceMediaPlaylist,
+		outputDir,
+		[]*video.RenditionStats{
+			{Name: "360p0", FPS: 30, Width: 640, Height: 360, BitsPerSecond: 1000000},
+		},
+		nil,
+		nil,
+	)
+	require.NoError(t, err)
+
+	renditionManifestContents, err := os.ReadFile(filepath.Join(outputDir, "360p0/index.m3u8"))
+	require.NoError(t, err)
+	require.Contains(t, string(renditionManifestContents), "0.ts\n#EXT-X-DISCONTINUITY\n#EXTINF:10.000,\n1.ts\n")
+}
+
 func TestCompliantMasterManifestOrdering(t *testing.T) {
 	// Set up the parameters we pass in
 	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(validMediaManifest), true)
@@ -184,6 +369,8 @@ func TestCompliantMasterManifestOrdering(t *testing.T) {
 				BitsPerSecond: 2000000,
 			},
 		},
+		nil,
+		nil,
 	)
 	require.NoError(t, err)
 
@@ -202,3 +389,142 @@ lowlowlow/index.m3u8
 `
 	require.Equal(t, expectedMasterManifest, string(masterManifestContents))
 }
+
+func TestItDownloadsTheHighestVariantOfAMasterManifest(t *testing.T) {
+	dir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720
+high/index.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=5000000,URI="iframes.m3u8"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "master.m3u8"), []byte(master), 0644))
+	for _, variant := range []string{"low", "high"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, variant), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, variant, "index.m3u8"), []byte(validMediaManifest), 0644))
+	}
+
+	playlist, mediaURL, err := DownloadMediaManifest("blah", filepath.Join(dir, "master.m3u8"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "high", "index.m3u8"), mediaURL)
+	require.Len(t, playlist.GetAllSegments(), 2)
+
+	// Media playlists are returned as they are
+	_, mediaURL, err = DownloadMediaManifest("blah", filepath.Join(dir, "low", "index.m3u8"))
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "low", "index.m3u8"), mediaURL)
+}
+
+const byteRangeFMP4Manifest = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-MAP:URI="video.mp4",BYTERANGE="4@0"
+#EXTINF:4.000,
+#EXT-X-BYTERANGE:5@4
+video.mp4
+#EXTINF:4.000,
+#EXT-X-BYTERANGE:3
+video.mp4
+#EXTINF:2.000,
+#EXT-X-BYTERANGE:6@12
+video.mp4
+#EXT-X-ENDLIST
+`
+
+func TestItParsesByteRangesAndInitSections(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(byteRangeFMP4Manifest), true)
+	require.NoError(t, err)
+	sourceMediaManifest, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+
+	us, err := GetSourceSegmentURLs("/tmp/something/output.m3u8", *sourceMediaManifest)
+	require.NoError(t, err)
+	require.Len(t, us, 3)
+	for _, u := range us {
+		require.Equal(t, "/tmp/something/video.mp4", u.URL.String())
+		require.Equal(t, "/tmp/something/video.mp4", u.InitURL.String())
+		require.Equal(t, ByteRange{Offset: 0, Length: 4}, u.InitRange)
+	}
+	require.Equal(t, ByteRange{Offset: 4, Length: 5}, us[0].Range)
+	// the offset defaults to the end of the previous range
+	require.Equal(t, ByteRange{Offset: 9, Length: 3}, us[1].Range)
+	require.Equal(t, ByteRange{Offset: 12, Length: 6}, us[2].Range)
+}
+
+func TestItDownloadsSourceSegmentsWithTheirInitSection(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "video.mp4")
+	require.NoError(t, os.WriteFile(source, []byte("INITaaaaabbbcccccc"), 0644))
+	u, err := url.Parse(source)
+	require.NoError(t, err)
+
+	rc, err := GetSourceSegment(context.Background(), "blah", SourceSegment{
+		URL:       u,
+		Range:     ByteRange{Offset: 9, Length: 3},
+		InitURL:   u,
+		InitRange: ByteRange{Offset: 0, Length: 4},
+	})
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "INITbbb", string(data))
+}
+
+func TestItRequestsSourceSegmentByteRanges(t *testing.T) {
+	for _, honorRange := range []bool{true, false} {
+		var ranges []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ranges = append(ranges, r.Header.Get("Range"))
+			if !honorRange {
+				r.Header.Del("Range")
+			}
+			http.ServeContent(w, r, "video.mp4", time.Time{}, strings.NewReader("INITaaaaabbbcccccc"))
+		}))
+		u, err := url.Parse(server.URL + "/video.mp4")
+		require.NoError(t, err)
+
+		rc, err := GetSourceSegment(context.Background(), "blah", SourceSegment{
+			URL:   u,
+			Range: ByteRange{Offset: 9, Length: 3},
+		})
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		server.Close()
+
+		require.Equal(t, "bbb", string(data))
+		require.Equal(t, []string{"bytes=9-11"}, ranges)
+	}
+}
+
+func TestItGivesUniqueNamesToAlternateRenditions(t *testing.T) {
+	sourceManifest, _, err := m3u8.DecodeFrom(strings.NewReader(validMediaManifest), true)
+	require.NoError(t, err)
+	sourceMediaPlaylist, ok := sourceManifest.(*m3u8.MediaPlaylist)
+	require.True(t, ok)
+	outputDir := t.TempDir()
+
+	_, err = GenerateAndUploadManifests(
+		*sourceMediaPlaylist,
+		outputDir,
+		[]*video.RenditionStats{
+			{Name: "360p0", FPS: 30, Width: 640, Height: 360, BitsPerSecond: 1000000},
+		},
+		[]*video.RenditionStats{
+			{Name: "audio-0-eng", Language: "eng", BitsPerSecond: 128000},
+			{Name: "audio-1-eng", Language: "eng", BitsPerSecond: 128000},
+		},
+		nil,
+	)
+	require.NoError(t, err)
+
+	masterManifestContents, err := os.ReadFile(filepath.Join(outputDir, "index.m3u8"))
+	require.NoError(t, err)
+	require.Contains(t, string(masterManifestContents), `NAME="eng",DEFAULT=YES,AUTOSELECT=YES,LANGUAGE="eng",URI="audio-0-eng/index.m3u8"`)
+	require.Contains(t, string(masterManifestContents), `NAME="audio-1-eng",DEFAULT=NO,AUTOSELECT=YES,LANGUAGE="eng",URI="audio-1-eng/index.m3u8"`)
+}
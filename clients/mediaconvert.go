@@ -61,6 +61,7 @@ type MediaConvertOptions struct {
 type AWSMediaConvertClient interface {
 	CreateJob(*mediaconvert.CreateJobInput) (*mediaconvert.CreateJobOutput, error)
 	GetJob(*mediaconvert.GetJobInput) (*mediaconvert.GetJobOutput, error)
+	CancelJob(*mediaconvert.CancelJobInput) (*mediaconvert.CancelJobOutput, error)
 }
 
 type MediaConvert struct {
@@ -253,7 +254,13 @@ func (mc *MediaConvert) coreAwsTranscode(ctx context.Context, args TranscodeJobA
 	for {
 		select {
 		case <-ctx.Done():
-			// don't cancel the job let it finish on AWS
+			// only cancel the AWS job if the whole job was explicitly cancelled, on timeouts let it finish on AWS
+			if errors.Is(ctx.Err(), context.Canceled) {
+				log.Log(args.RequestID, "Cancelling MediaConvert job")
+				if _, err := mc.client.CancelJob(&mediaconvert.CancelJobInput{Id: jobID}); err != nil {
+					log.LogError(args.RequestID, "error cancelling mediaconvert job", err)
+				}
+			}
 			return ctx.Err()
 		case <-ticker.C:
 			// continue below
@@ -371,25 +378,16 @@ func outputGroups(hlsOutputFile, mp4OutputFile string, profiles []video.EncodedP
 func outputs(container string, profiles []video.EncodedProfile) []*mediaconvert.Output {
 	outs := make([]*mediaconvert.Output, 0, len(profiles))
 	for _, profile := range profiles {
-		outs = append(outs, output(container, profile.Name, profile.Height, profile.Bitrate))
+		outs = append(outs, output(container, profile.Name, profile.VideoCodec(), profile.Height, profile.Bitrate))
 	}
 	return outs
 }
 
-func output(container, name string, height, maxBitrate int64) *mediaconvert.Output {
+func output(container, name, codec string, height, maxBitrate int64) *mediaconvert.Output {
 	return &mediaconvert.Output{
 		VideoDescription: &mediaconvert.VideoDescription{
-			Height: aws.Int64(height),
-			CodecSettings: &mediaconvert.VideoCodecSettings{
-				Codec: aws.String("H_264"),
-				H264Settings: &mediaconvert.H264Settings{
-					GopSizeUnits:       aws.String(mediaconvert.H264GopSizeUnitsAuto),
-					MaxBitrate:         aws.Int64(maxBitrate),
-					RateControlMode:    aws.String("QVBR"),
-					SceneChangeDetect:  aws.String("TRANSITION_DETECTION"),
-					QualityTuningLevel: aws.String("MULTI_PASS_HQ"),
-					FramerateControl:   aws.String("INITIALIZE_FROM_SOURCE"),
-				}}},
+			Height:        aws.Int64(height),
+			CodecSettings: videoCodecSettings(codec, maxBitrate)},
 		AudioDescriptions: []*mediaconvert.AudioDescription{
 			{
 				CodecSettings: &mediaconvert.AudioCodecSettings{
@@ -409,6 +407,33 @@ func output(container, name string, height, maxBitrate int64) *mediaconvert.Outp
 	}
 }
 
+func videoCodecSettings(codec string, maxBitrate int64) *mediaconvert.VideoCodecSettings {
+	if codec == video.CodecHEVC {
+		return &mediaconvert.VideoCodecSettings{
+			Codec: aws.String(mediaconvert.VideoCodecH265),
+			H265Settings: &mediaconvert.H265Settings{
+				GopSizeUnits:       aws.String(mediaconvert.H265GopSizeUnitsAuto),
+				MaxBitrate:         aws.Int64(maxBitrate),
+				RateControlMode:    aws.String(mediaconvert.H265RateControlModeQvbr),
+				SceneChangeDetect:  aws.String(mediaconvert.H265SceneChangeDetectTransitionDetection),
+				QualityTuningLevel: aws.String(mediaconvert.H265QualityTuningLevelMultiPassHq),
+				FramerateControl:   aws.String(mediaconvert.H265FramerateControlInitializeFromSource),
+				// hvc1 is required by Apple players in MP4 outputs
+				WriteMp4PackagingType: aws.String(mediaconvert.H265WriteMp4PackagingTypeHvc1),
+			}}
+	}
+	return &mediaconvert.VideoCodecSettings{
+		Codec: aws.String("H_264"),
+		H264Settings: &mediaconvert.H264Settings{
+			GopSizeUnits:       aws.String(mediaconvert.H264GopSizeUnitsAuto),
+			MaxBitrate:         aws.Int64(maxBitrate),
+			RateControlMode:    aws.String("QVBR"),
+			SceneChangeDetect:  aws.String("TRANSITION_DETECTION"),
+			QualityTuningLevel: aws.String("MULTI_PASS_HQ"),
+			FramerateControl:   aws.String("INITIALIZE_FROM_SOURCE"),
+		}}
+}
+
 func copyDir(source, dest *url.URL, args TranscodeJobArgs) error {
 	ctx, cancel := context.WithTimeout(context.Background(), MAX_COPY_DIR_DURATION)
 	defer cancel()
@@ -130,6 +130,41 @@ func TestRetriesOnAccelerationError(t *testing.T) {
 	require.Equal(2, createdJobs)
 }
 
+func TestCancelsMediaConvertJobOnCancellation(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var cancelledJobs []string
+	awsStub := &stubMediaConvertClient{
+		createJob: func(input *mediaconvert.CreateJobInput) (*mediaconvert.CreateJobOutput, error) {
+			return &mediaconvert.CreateJobOutput{Job: &mediaconvert.Job{Id: aws.String("10")}}, nil
+		},
+		getJob: func(input *mediaconvert.GetJobInput) (*mediaconvert.GetJobOutput, error) {
+			// the job gets cancelled while it's running on AWS
+			cancel()
+			return &mediaconvert.GetJobOutput{Job: &mediaconvert.Job{
+				Status:             aws.String(mediaconvert.JobStatusProgressing),
+				JobPercentComplete: aws.Int64(50),
+			}}, nil
+		},
+		cancelJob: func(input *mediaconvert.CancelJobInput) (*mediaconvert.CancelJobOutput, error) {
+			cancelledJobs = append(cancelledJobs, *input.Id)
+			return &mediaconvert.CancelJobOutput{}, nil
+		},
+	}
+	mc, f, _, cleanup := setupTestMediaConvert(t, awsStub)
+	defer cleanup()
+
+	_, err := mc.Transcode(ctx, TranscodeJobArgs{
+		InputFile:         mustParseURL(t, "file://"+f.Name()),
+		HLSOutputLocation: mustParseURL(t, "s3+https://endpoint.com/bucket/1234"),
+		ReportProgress:    func(progress float64) {},
+		InputFileInfo:     inputVideo,
+	})
+	require.ErrorIs(err, context.Canceled)
+	require.Equal([]string{"10"}, cancelledJobs)
+}
+
 func TestCopiesMediaConvertOutputToFinalLocation(t *testing.T) {
 	require := require.New(t)
 
@@ -232,6 +267,17 @@ func Test_createJobPayload(t *testing.T) {
 			},
 			want: "fixtures/mediaconvert_payloads/no-mp4.txt",
 		},
+		{
+			name: "HEVC",
+			args: args{
+				mp4OutputFile: "mp4out",
+				accelerated:   false,
+				profiles: []video.EncodedProfile{
+					{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 4_000_000, Codec: video.CodecHEVC},
+				},
+			},
+			want: "fixtures/mediaconvert_payloads/hevc.txt",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -352,6 +398,7 @@ func mustParseURL(t *testing.T, str string) *url.URL {
 type stubMediaConvertClient struct {
 	createJob func(*mediaconvert.CreateJobInput) (*mediaconvert.CreateJobOutput, error)
 	getJob    func(*mediaconvert.GetJobInput) (*mediaconvert.GetJobOutput, error)
+	cancelJob func(*mediaconvert.CancelJobInput) (*mediaconvert.CancelJobOutput, error)
 }
 
 func (s *stubMediaConvertClient) CreateJob(input *mediaconvert.CreateJobInput) (*mediaconvert.CreateJobOutput, error) {
@@ -368,6 +415,13 @@ func (s *stubMediaConvertClient) GetJob(input *mediaconvert.GetJobInput) (*media
 	return s.getJob(input)
 }
 
+func (s *stubMediaConvertClient) CancelJob(input *mediaconvert.CancelJobInput) (*mediaconvert.CancelJobOutput, error) {
+	if s.cancelJob == nil {
+		return nil, errors.New("not implemented")
+	}
+	return s.cancelJob(input)
+}
+
 type stubS3Client struct {
 	transferDir string
 }
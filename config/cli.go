@@ -39,6 +39,19 @@ type Cli struct {
 	PrivateBucketURL          *url.URL
 	ExternalTranscoder        string
 	VodPipelineStrategy       string
+	VodJobStore               string
+	VodResumeJobs             bool
+	VodRoutingRules           string
+	VodMaxJobsInFlight        int
+	VodMaxQueuedJobs          int
+	VodDedupWindow            time.Duration
+	VodMaxAttempts            int
+	VodRetryBackoff           time.Duration
+	MP4OnlyShortMaxDuration   time.Duration
+	CallbackSigningSecrets    []string
+	CallbackAuthHosts         []string
+	CallbackOutbox            string
+	CallbackOutboxMaxAge      time.Duration
 	RecordingCallback         string
 	MetricsDBConnectionString string
 	ImportIPFSGatewayURLs     []*url.URL
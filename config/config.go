@@ -28,8 +28,27 @@ const MaxSegmentSizeSecs = 20
 
 // Somewhat arbitrary and conservative number of maximum Catalyst VOD jobs in the system
 // at one time. We can look at more sophisticated strategies for calculating capacity in
-// the future.
-const MAX_JOBS_IN_FLIGHT = 8
+// the future. Jobs above this limit wait in a queue.
+var MaxJobsInFlight = 8
+
+// Maximum number of VOD jobs waiting for a free slot, above which new jobs are rejected
+var MaxQueuedJobs = 100
+
+// How long a completed VOD job is remembered, so that a retried request with the same
+// external_id or Idempotency-Key returns the existing job rather than starting a new one.
+// Zero disables the deduplication.
+var VODDedupWindow = 1 * time.Hour
+
+// Number of times a VOD job is attempted before the error callback is sent. Errors
+// marked as unretriable are never retried. Retries are off by default, so that
+// failures keep being reported as soon as they happen unless opted in.
+var VODMaxAttempts = 1
+
+// How long to wait before retrying a failed VOD job. Doubled for each further attempt.
+var VODRetryBackoff = 30 * time.Second
+
+// Longest input for which MP4 outputs are generated when they are requested with "only_short"
+var MP4OnlyShortMaxDuration = 2 * time.Minute
 
 // How long to try writing a single segment to storage for before giving up
 const SEGMENT_WRITE_TIMEOUT = 5 * time.Minute
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/errors"
+)
+
+// ListDeadLetterCallbacks returns the terminal callbacks that couldn't be
+// delivered before the outbox gave up on them
+func (d *CatalystAPIHandlersCollection) ListDeadLetterCallbacks() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		outbox := d.VODEngine.CallbackOutbox
+		if outbox == nil {
+			errors.WriteHTTPNotFound(w, "Callback outbox is not enabled", nil)
+			return
+		}
+		writeJSON(w, outbox.DeadLetters())
+	}
+}
+
+// RedeliverCallback moves a dead-lettered callback back to the outbox, where
+// its delivery is retried
+func (d *CatalystAPIHandlersCollection) RedeliverCallback() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		outbox := d.VODEngine.CallbackOutbox
+		if outbox == nil {
+			errors.WriteHTTPNotFound(w, "Callback outbox is not enabled", nil)
+			return
+		}
+		id := params.ByName("id")
+		found, err := outbox.Redeliver(id)
+		if !found {
+			errors.WriteHTTPNotFound(w, "Callback not found", fmt.Errorf("no dead-lettered callback with ID %q", id))
+			return
+		}
+		if err != nil {
+			errors.WriteHTTPInternalServerError(w, "Failed to redeliver callback", err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
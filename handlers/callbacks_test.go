@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterCallbacks(t *testing.T) {
+	require := require.New(t)
+	outbox, err := clients.NewCallbackOutbox(t.TempDir(), 0)
+	require.NoError(err)
+
+	coord := pipeline.NewStubCoordinator()
+	coord.CallbackOutbox = outbox
+	catalystApiHandlers := CatalystAPIHandlersCollection{VODEngine: coord}
+	router := httprouter.New()
+	router.GET("/api/callbacks/dead-letter", catalystApiHandlers.ListDeadLetterCallbacks())
+	router.POST("/api/callbacks/dead-letter/:id/redeliver", catalystApiHandlers.RedeliverCallback())
+
+	list := func() []clients.OutboxEntry {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/callbacks/dead-letter", nil)
+		router.ServeHTTP(rr, req)
+		require.Equal(http.StatusOK, rr.Code)
+		var entries []clients.OutboxEntry
+		require.NoError(json.Unmarshal(rr.Body.Bytes(), &entries))
+		return entries
+	}
+	require.Empty(list())
+
+	// the max age is 0, so the callback is dead-lettered after the first failure
+	tsm := clients.NewTranscodeStatusCancelled("http://example.com/callback", "req-1")
+	require.NoError(outbox.Send(tsm, func(tsm clients.TranscodeStatusMessage) error { return http.ErrServerClosed }))
+	entries := list()
+	require.Len(entries, 1)
+	require.Equal("req-1", entries[0].Message.RequestID)
+	require.Equal("http://example.com/callback", entries[0].URL)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/callbacks/dead-letter/"+entries[0].ID+"/redeliver", nil)
+	router.ServeHTTP(rr, req)
+	require.Equal(http.StatusAccepted, rr.Code)
+	require.Empty(list())
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/callbacks/dead-letter/unknown/redeliver", nil)
+	router.ServeHTTP(rr, req)
+	require.Equal(http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	errors2 "errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/errors"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/pipeline"
+	"github.com/livepeer/catalyst-api/video"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ClipVODRequest cuts a clip out of a rendition of an existing HLS asset. The
+// output locations are the same as the ones of UploadVODRequest, apart from
+// CMAF which isn't supported.
+type ClipVODRequest struct {
+	ExternalID      string                           `json:"external_id,omitempty"`
+	Url             string                           `json:"url"`
+	CallbackUrl     string                           `json:"callback_url"`
+	StartSecs       float64                          `json:"start_secs"`
+	EndSecs         float64                          `json:"end_secs"`
+	Priority        int                              `json:"priority"`
+	OutputLocations []UploadVODRequestOutputLocation `json:"output_locations"`
+}
+
+func (d *CatalystAPIHandlersCollection) ClipVOD() httprouter.Handle {
+	schema := inputSchemasCompiled["ClipVOD"]
+
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		d.handleClipVOD(w, req, schema)
+	}
+}
+
+func (d *CatalystAPIHandlersCollection) handleClipVOD(w http.ResponseWriter, req *http.Request, schema *gojsonschema.Schema) (bool, errors.APIError) {
+	var clipVODRequest ClipVODRequest
+
+	if !HasContentType(req, "application/json") {
+		return false, errors.WriteHTTPUnsupportedMediaType(w, "Requires application/json content type", nil)
+	} else if payload, err := io.ReadAll(req.Body); err != nil {
+		return false, errors.WriteHTTPInternalServerError(w, "Cannot read payload", err)
+	} else if result, err := schema.Validate(gojsonschema.NewBytesLoader(payload)); err != nil {
+		return false, errors.WriteHTTPInternalServerError(w, "Cannot validate payload", err)
+	} else if !result.Valid() {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", fmt.Errorf("%s", result.Errors()))
+	} else if err := json.Unmarshal(payload, &clipVODRequest); err != nil {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", err)
+	}
+
+	var requestID = config.RandomTrailer(8)
+	log.AddContext(requestID, "source", clipVODRequest.Url, "external_id", clipVODRequest.ExternalID)
+
+	if err := CheckSourceURLValid(clipVODRequest.Url); err != nil {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", err)
+	}
+	clipRange := video.ClipRange{StartSecs: clipVODRequest.StartSecs, EndSecs: clipVODRequest.EndSecs}
+	if clipRange.Duration() <= 0 {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", errors2.New("end_secs must be after start_secs"))
+	}
+
+	// the output locations are parsed the same way as the upload ones
+	outputs := UploadVODRequest{ExternalID: clipVODRequest.ExternalID, OutputLocations: clipVODRequest.OutputLocations}
+	hlsTargetURL, err := toTargetURL(outputs.getTargetHlsOutput(), requestID)
+	if err != nil {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", err)
+	}
+	if hlsTargetURL == nil {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", errors2.New("clips require the hls output"))
+	}
+	mp4TargetOutput, mp4OnlyShort := outputs.getTargetMp4Output()
+	mp4TargetURL, err := toTargetURL(mp4TargetOutput, requestID)
+	if err != nil {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", err)
+	}
+
+	log.Log(requestID, "Received VOD Clip request", "start_secs", clipRange.StartSecs, "end_secs", clipRange.EndSecs)
+
+	idempotencyKey := outputs.idempotencyKey(req)
+	if existingRequestID, duplicate := d.VODEngine.DeduplicateUploadJob(idempotencyKey, requestID); duplicate {
+		return writeUploadVODResponse(w, existingRequestID)
+	}
+
+	d.VODEngine.StartUploadJob(pipeline.UploadJobPayload{
+		SourceFile:     clipVODRequest.Url,
+		CallbackURL:    clipVODRequest.CallbackUrl,
+		HlsTargetURL:   hlsTargetURL,
+		Mp4TargetURL:   mp4TargetURL,
+		Mp4OnlyShort:   mp4OnlyShort,
+		RequestID:      requestID,
+		ExternalID:     clipVODRequest.ExternalID,
+		Priority:       clipVODRequest.Priority,
+		IdempotencyKey: idempotencyKey,
+		Clip:           &clipRange,
+	})
+
+	return writeUploadVODResponse(w, requestID)
+}
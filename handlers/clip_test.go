@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuccessfulVODClipHandler(t *testing.T) {
+	require := require.New(t)
+
+	catalystApiHandlers := CatalystAPIHandlersCollection{VODEngine: pipeline.NewStubCoordinator()}
+	var jsonData = []byte(`{
+		"url": "http://localhost/asset/720p0/index.m3u8",
+		"callback_url": "http://localhost/callback",
+		"start_secs": 12.5,
+		"end_secs": 30,
+		"output_locations": [ { "type": "object_store", "url": "memory://localhost/clip", "outputs": { "hls": "enabled", "mp4": "enabled" } } ]
+	}`)
+
+	router := httprouter.New()
+	router.POST("/api/vod/clip", catalystApiHandlers.ClipVOD())
+	req, _ := http.NewRequest("POST", "/api/vod/clip", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(http.StatusOK, rr.Result().StatusCode)
+
+	var uvr UploadVODResponse
+	require.NoError(json.Unmarshal(rr.Body.Bytes(), &uvr))
+	require.Greater(len(uvr.RequestID), 1)
+}
+
+func TestInvalidPayloadVODClipHandler(t *testing.T) {
+	require := require.New(t)
+
+	catalystApiHandlers := CatalystAPIHandlersCollection{VODEngine: pipeline.NewStubCoordinator()}
+	badRequests := [][]byte{
+		// missing end_secs
+		[]byte(`{
+			"url": "http://localhost/index.m3u8",
+			"callback_url": "http://localhost/callback",
+			"start_secs": 10,
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/clip", "outputs": { "hls": "enabled" } } ]
+		}`),
+		// end before start
+		[]byte(`{
+			"url": "http://localhost/index.m3u8",
+			"callback_url": "http://localhost/callback",
+			"start_secs": 10,
+			"end_secs": 5,
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/clip", "outputs": { "hls": "enabled" } } ]
+		}`),
+		// no hls output
+		[]byte(`{
+			"url": "http://localhost/index.m3u8",
+			"callback_url": "http://localhost/callback",
+			"start_secs": 10,
+			"end_secs": 20,
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/clip", "outputs": { "mp4": "enabled" } } ]
+		}`),
+		// cmaf isn't supported
+		[]byte(`{
+			"url": "http://localhost/index.m3u8",
+			"callback_url": "http://localhost/callback",
+			"start_secs": 10,
+			"end_secs": 20,
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/clip", "outputs": { "hls": "enabled", "cmaf": "enabled" } } ]
+		}`),
+	}
+
+	router := httprouter.New()
+	router.POST("/api/vod/clip", catalystApiHandlers.ClipVOD())
+	for _, payload := range badRequests {
+		req, _ := http.NewRequest("POST", "/api/vod/clip", bytes.NewBuffer(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(http.StatusBadRequest, rr.Result().StatusCode, string(payload))
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"testing"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/clients"
 	"github.com/livepeer/catalyst-api/pipeline"
+	"github.com/livepeer/catalyst-api/video"
 	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 func TestOKHandler(t *testing.T) {
@@ -70,6 +73,50 @@ func TestSuccessfulVODUploadHandler(t *testing.T) {
 	require.Greater(len(uvr.RequestID), 1) // Check that we got some value for Request ID
 }
 
+func TestDuplicateVODUploadReturnsExistingJob(t *testing.T) {
+	require := require.New(t)
+
+	// keep the jobs in flight for the whole test
+	pipeFfmpeg, release := pipeline.NewBlockingStubHandler()
+	defer release()
+	coordinator := pipeline.NewStubCoordinatorOpts(pipeline.StrategyCatalystFfmpegDominance, nil, pipeFfmpeg, nil, "")
+	coordinator.InputCopy = &clients.StubInputCopy{}
+
+	catalystApiHandlers := CatalystAPIHandlersCollection{VODEngine: coordinator}
+	router := httprouter.New()
+	router.POST("/api/vod", catalystApiHandlers.UploadVOD())
+
+	upload := func(externalID, idempotencyKey string) string {
+		jsonData := `{
+			"external_id": "` + externalID + `",
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output.m3u8", "outputs": { "hls": "enabled" } } ]
+		}`
+		req, _ := http.NewRequest("POST", "/api/vod", bytes.NewBuffer([]byte(jsonData)))
+		req.Header.Set("Content-Type", "application/json")
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(http.StatusOK, rr.Result().StatusCode)
+
+		var uvr UploadVODResponse
+		require.NoError(json.Unmarshal(rr.Body.Bytes(), &uvr))
+		return uvr.RequestID
+	}
+
+	first := upload("asset-1", "")
+	require.Equal(first, upload("asset-1", ""))
+	require.NotEqual(first, upload("asset-2", ""))
+
+	// the header takes precedence over the external ID
+	keyed := upload("asset-1", "retry-key")
+	require.NotEqual(first, keyed)
+	require.Equal(keyed, upload("asset-3", "retry-key"))
+}
+
 func TestInvalidPayloadVODUploadHandler(t *testing.T) {
 	require := require.New(t)
 
@@ -126,6 +173,35 @@ func TestInvalidPayloadVODUploadHandler(t *testing.T) {
 			"callback_url": "http://localhost/callback",
 			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output.m3u8", "outputs": {} } ]
 		}`),
+		// unknown codec
+		[]byte(`{
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output", "outputs": { "hls": "enabled" } } ],
+			"profiles": [ { "name": "720p0", "width": 1280, "height": 720, "bitrate": 3000000, "codec": "h266" } ]
+		}`),
+		// codecs that can't be carried by the MPEG-TS renditions aren't accepted yet
+		[]byte(`{
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output", "outputs": { "hls": "enabled" } } ],
+			"profiles": [ { "name": "720p0", "width": 1280, "height": 720, "bitrate": 3000000, "codec": "av1" } ]
+		}`),
+		// captions language that isn't a BCP-47 tag
+		[]byte(`{
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output", "outputs": { "hls": "enabled" } } ],
+			"captions": [ { "url": "http://localhost/captions.vtt", "language": "../../en" } ]
+		}`),
+		// per-title ladder with explicit profiles
+		[]byte(`{
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output", "outputs": { "hls": "enabled" } } ],
+			"profiles": [ { "name": "720p0", "width": 1280, "height": 720, "bitrate": 3000000 } ],
+			"per_title": { "max_bitrate": 5000000 }
+		}`),
 	}
 
 	router := httprouter.New()
@@ -141,6 +217,45 @@ func TestInvalidPayloadVODUploadHandler(t *testing.T) {
 	}
 }
 
+func TestUploadVODSchemaAcceptsRegisteredStrategies(t *testing.T) {
+	schema := inputSchemasCompiled["UploadVOD"]
+	validate := func(strategy string) bool {
+		payload := `{
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output" } ],
+			"pipeline_strategy": "` + strategy + `"
+		}`
+		result, err := schema.Validate(gojsonschema.NewStringLoader(payload))
+		require.NoError(t, err)
+		return result.Valid()
+	}
+
+	for _, strategy := range pipeline.Strategies() {
+		require.True(t, validate(strategy), strategy)
+		require.True(t, pipeline.Strategy(strategy).IsValid(), strategy)
+	}
+	require.Contains(t, pipeline.Strategies(), "catalyst")
+	require.Contains(t, pipeline.Strategies(), "background_mist")
+	require.False(t, validate("mist"))
+}
+
+func TestUploadVODSchemaOnlyAcceptsSupportedCodecs(t *testing.T) {
+	schema := inputSchemasCompiled["UploadVOD"]
+	for _, codec := range []string{video.CodecH264, video.CodecHEVC, "vp9", "av1"} {
+		payload := `{
+			"url": "http://localhost/input",
+			"callback_url": "http://localhost/callback",
+			"output_locations": [ { "type": "object_store", "url": "memory://localhost/output" } ],
+			"profiles": [ { "name": "720p0", "width": 1280, "height": 720, "bitrate": 3000000, "codec": "` + codec + `" } ]
+		}`
+		result, err := schema.Validate(gojsonschema.NewStringLoader(payload))
+		require.NoError(t, err)
+		// the schema must not accept codecs that the handler rejects
+		require.Equal(t, video.IsSupportedCodec(codec), result.Valid(), codec)
+	}
+}
+
 func TestWrongContentTypeVODUploadHandler(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/errors"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/pipeline"
+)
+
+// GetVODJob returns the status of a single in-flight VOD job
+func (d *CatalystAPIHandlersCollection) GetVODJob() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		requestID := params.ByName("request_id")
+		status, ok := d.VODEngine.GetJobStatus(requestID)
+		if !ok {
+			errors.WriteHTTPNotFound(w, "Job not found", fmt.Errorf("no in-flight job with request ID %q", requestID))
+			return
+		}
+		writeJSON(w, status)
+	}
+}
+
+// CancelVODJob stops an in-flight VOD job. Cancellation is asynchronous, the
+// caller receives a "cancelled" status callback once the pipeline has stopped.
+func (d *CatalystAPIHandlersCollection) CancelVODJob() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		requestID := params.ByName("request_id")
+		if !d.VODEngine.CancelJob(requestID) {
+			errors.WriteHTTPNotFound(w, "Job not found", fmt.Errorf("no in-flight job with request ID %q", requestID))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// VODJobEvents streams the status messages of an in-flight VOD job as
+// Server-Sent Events. The first event is the current status of the job, then
+// each status message is sent as an event named after its status, until the
+// terminal one ("success", "error" or "cancelled").
+func (d *CatalystAPIHandlersCollection) VODJobEvents() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		requestID := params.ByName("request_id")
+		// subscribe before looking the job up, so that no message is missed in between
+		events, unsubscribe := d.VODEngine.SubscribeJobEvents(requestID)
+		defer unsubscribe()
+
+		status, ok := d.VODEngine.GetJobStatus(requestID)
+		if !ok {
+			errors.WriteHTTPNotFound(w, "Job not found", fmt.Errorf("no in-flight job with request ID %q", requestID))
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			errors.WriteHTTPInternalServerError(w, "Streaming is not supported", nil)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		if err := writeEvent(w, "status", status); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		for {
+			select {
+			case tsm, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, tsm.Status.String(), tsm); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-req.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, data interface{}) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.LogNoRequestID("failed to marshal event", "event", event, "err", err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
+
+// ListVODJobs returns the status of all in-flight VOD jobs, optionally
+// filtered by the "state" and "external_id" query parameters
+func (d *CatalystAPIHandlersCollection) ListVODJobs() httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		state := req.URL.Query().Get("state")
+		externalID := req.URL.Query().Get("external_id")
+
+		statuses := []pipeline.JobStatus{}
+		for _, status := range d.VODEngine.ListJobStatuses() {
+			if state != "" && status.State != state {
+				continue
+			}
+			if externalID != "" && status.ExternalID != externalID {
+				continue
+			}
+			statuses = append(statuses, status)
+		}
+		writeJSON(w, statuses)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.LogNoRequestID("failed to write HTTP response", "err", err)
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func jobsRouter() (*httprouter.Router, *pipeline.Coordinator) {
+	coord := pipeline.NewStubCoordinator()
+	for _, p := range []pipeline.UploadJobPayload{
+		{RequestID: "req-1", ExternalID: "asset-1"},
+		{RequestID: "req-2", ExternalID: "asset-2"},
+	} {
+		coord.Jobs.Store(config.SegmentingStreamName(p.RequestID), &pipeline.JobInfo{UploadJobPayload: p})
+	}
+
+	catalystApiHandlers := CatalystAPIHandlersCollection{VODEngine: coord}
+	router := httprouter.New()
+	router.GET("/api/vod", catalystApiHandlers.ListVODJobs())
+	router.GET("/api/vod/:request_id", catalystApiHandlers.GetVODJob())
+	router.DELETE("/api/vod/:request_id", catalystApiHandlers.CancelVODJob())
+	return router, coord
+}
+
+func TestGetVODJob(t *testing.T) {
+	require := require.New(t)
+	router, _ := jobsRouter()
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/vod/req-2", nil)
+	router.ServeHTTP(rr, req)
+	require.Equal(http.StatusOK, rr.Code)
+
+	var status pipeline.JobStatus
+	require.NoError(json.Unmarshal(rr.Body.Bytes(), &status))
+	require.Equal("req-2", status.RequestID)
+	require.Equal("asset-2", status.ExternalID)
+	require.Equal("preparing", status.State)
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/vod/unknown", nil)
+	router.ServeHTTP(rr, req)
+	require.Equal(http.StatusNotFound, rr.Code)
+}
+
+func TestListVODJobs(t *testing.T) {
+	tests := []struct {
+		query       string
+		expectedIDs []string
+	}{
+		{query: "", expectedIDs: []string{"req-1", "req-2"}},
+		{query: "?external_id=asset-1", expectedIDs: []string{"req-1"}},
+		{query: "?state=preparing&external_id=asset-2", expectedIDs: []string{"req-2"}},
+		{query: "?state=transcoding", expectedIDs: []string{}},
+	}
+	router, _ := jobsRouter()
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/vod"+tt.query, nil)
+			router.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var statuses []pipeline.JobStatus
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &statuses))
+			ids := []string{}
+			for _, s := range statuses {
+				ids = append(ids, s.RequestID)
+			}
+			require.ElementsMatch(t, tt.expectedIDs, ids)
+		})
+	}
+}
+
+func TestVODJobEvents(t *testing.T) {
+	require := require.New(t)
+
+	pipeFfmpeg, release := pipeline.NewBlockingStubHandler()
+	defer release()
+	coord := pipeline.NewStubCoordinatorOpts(pipeline.StrategyCatalystFfmpegDominance, nil, pipeFfmpeg, nil, "")
+	coord.InputCopy = &clients.StubInputCopy{}
+	coord.StartUploadJob(pipeline.UploadJobPayload{
+		RequestID:   "req-1",
+		SourceFile:  "http://localhost/input",
+		CallbackURL: "http://localhost/callback",
+	})
+
+	catalystApiHandlers := CatalystAPIHandlersCollection{VODEngine: coord}
+	router := httprouter.New()
+	router.GET("/api/vod/:request_id/events", catalystApiHandlers.VODJobEvents())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/vod/unknown/events")
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/api/vod/req-1/events")
+	require.NoError(err)
+	defer resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := bufio.NewScanner(resp.Body)
+	nextEvent := func() (string, string) {
+		var event, data string
+		for events.Scan() {
+			line := events.Text()
+			if line == "" {
+				return event, data
+			}
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				event = v
+			}
+			if v, ok := strings.CutPrefix(line, "data: "); ok {
+				data = v
+			}
+		}
+		require.NoError(events.Err())
+		return event, data
+	}
+
+	event, data := nextEvent()
+	require.Equal("status", event)
+	var status pipeline.JobStatus
+	require.NoError(json.Unmarshal([]byte(data), &status))
+	require.Equal("req-1", status.RequestID)
+
+	// the stream ends with the terminal status of the job
+	release()
+	for {
+		event, data = nextEvent()
+		require.NotEmpty(event, "stream ended before the terminal event")
+		if event == clients.TranscodeStatusError.String() {
+			break
+		}
+	}
+	var tsm clients.TranscodeStatusMessage
+	require.NoError(json.Unmarshal([]byte(data), &tsm))
+	require.Equal("req-1", tsm.RequestID)
+	require.NotEmpty(tsm.Error)
+	event, _ = nextEvent()
+	require.Empty(event)
+}
+
+func TestCancelUnknownVODJob(t *testing.T) {
+	router, _ := jobsRouter()
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/vod/unknown", nil)
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
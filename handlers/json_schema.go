@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"embed"
+	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"strings"
 
+	"github.com/livepeer/catalyst-api/pipeline"
 	"github.com/xeipuuv/gojsonschema"
 	"sigs.k8s.io/yaml"
 )
@@ -12,6 +15,12 @@ import (
 //go:embed schemas/*
 var schemasDir embed.FS
 
+// schemaEnums holds the allowed values of schema properties that are defined in
+// code rather than in the schema files, keyed by schema name and property name.
+var schemaEnums = map[string]map[string][]string{
+	"UploadVOD": {"pipeline_strategy": pipeline.Strategies()},
+}
+
 func compileJsonSchemas() map[string]*gojsonschema.Schema {
 	compiled := make(map[string]*gojsonschema.Schema, 0)
 	inputSchemas, err := schemasDir.ReadDir("schemas")
@@ -33,18 +42,42 @@ func compileJsonSchemas() map[string]*gojsonschema.Schema {
 			panic(err)
 		}
 
+		name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		jsonText, err = injectEnums(jsonText, schemaEnums[name])
+		if err != nil {
+			panic(err)
+		}
+
 		schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(jsonText))
 		if err != nil {
 			// rase panic on program start
 			panic(err) // fix schema text
 		}
 
-		name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
 		compiled[name] = schema
 	}
 
 	return compiled
 }
 
+func injectEnums(jsonText []byte, enums map[string][]string) ([]byte, error) {
+	if len(enums) == 0 {
+		return jsonText, nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(jsonText, &schema); err != nil {
+		return nil, err
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for property, values := range enums {
+		p, ok := properties[property].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("schema has no %q property to set the enum of", property)
+		}
+		p["enum"] = values
+	}
+	return json.Marshal(schema)
+}
+
 // Run compile step on program start:
 var inputSchemasCompiled map[string]*gojsonschema.Schema = compileJsonSchemas()
@@ -23,8 +23,9 @@ import (
 )
 
 type UploadVODRequestOutputLocationOutputs struct {
-	HLS string `json:"hls"`
-	MP4 string `json:"mp4"`
+	HLS  string `json:"hls"`
+	MP4  string `json:"mp4"`
+	CMAF string `json:"cmaf"`
 }
 
 type UploadVODRequestOutputLocation struct {
@@ -44,9 +45,13 @@ type UploadVODRequest struct {
 	Encryption      *pipeline.EncryptionPayload      `json:"encryption,omitempty"`
 
 	// Forwarded to transcoding stage:
-	TargetSegmentSizeSecs int64                  `json:"target_segment_size_secs"`
-	Profiles              []video.EncodedProfile `json:"profiles"`
-	PipelineStrategy      pipeline.Strategy      `json:"pipeline_strategy"`
+	TargetSegmentSizeSecs int64                   `json:"target_segment_size_secs"`
+	Profiles              []video.EncodedProfile  `json:"profiles"`
+	PipelineStrategy      pipeline.Strategy       `json:"pipeline_strategy"`
+	Priority              int                     `json:"priority"`
+	Thumbnails            *video.ThumbnailOptions `json:"thumbnails,omitempty"`
+	Captions              []video.CaptionFile     `json:"captions,omitempty"`
+	PerTitle              *video.PerTitleOptions  `json:"per_title,omitempty"`
 }
 
 type UploadVODResponse struct {
@@ -92,6 +97,33 @@ func (r UploadVODRequest) getTargetMp4Output() (UploadVODRequestOutputLocation,
 	return UploadVODRequestOutputLocation{}, false
 }
 
+// cmafEnabled returns whether the CMAF output is requested. It is written next
+// to the HLS output, so it has to be enabled on the HLS output location.
+func (r UploadVODRequest) cmafEnabled() (bool, error) {
+	for _, o := range r.OutputLocations {
+		if o.Outputs.CMAF != "enabled" {
+			continue
+		}
+		if o.Outputs.HLS != "enabled" {
+			return false, errors2.New("cmaf output requires hls output on the same output location")
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// idempotencyKey returns the key used to detect retries of the same request.
+// An explicit Idempotency-Key header takes precedence over the external ID.
+func (r UploadVODRequest) idempotencyKey(req *http.Request) string {
+	if key := req.Header.Get("Idempotency-Key"); key != "" {
+		return "key:" + key
+	}
+	if r.ExternalID != "" {
+		return "external_id:" + r.ExternalID
+	}
+	return ""
+}
+
 func (d *CatalystAPIHandlersCollection) UploadVOD() httprouter.Handle {
 	schema := inputSchemasCompiled["UploadVOD"]
 	m := metrics.Metrics
@@ -159,13 +191,33 @@ func (d *CatalystAPIHandlersCollection) handleUploadVOD(w http.ResponseWriter, r
 	if hlsTargetURL == nil && mp4TargetURL == nil {
 		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", errors2.New("none of output enabled: hls or mp4"))
 	}
+	generateCMAF, err := uploadVODRequest.cmafEnabled()
+	if err != nil {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", err)
+	}
 
 	if strat := uploadVODRequest.PipelineStrategy; strat != "" && !strat.IsValid() {
 		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", fmt.Errorf("invalid value provided for pipeline strategy: %q", uploadVODRequest.PipelineStrategy))
 	}
 
+	for _, profile := range uploadVODRequest.Profiles {
+		if !video.IsSupportedCodec(profile.Codec) {
+			return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", fmt.Errorf("codec %q of profile %q is not supported", profile.Codec, profile.Name))
+		}
+	}
+
+	if uploadVODRequest.PerTitle != nil && len(uploadVODRequest.Profiles) > 0 {
+		return false, errors.WriteHTTPBadRequest(w, "Invalid request payload", errors2.New("per_title can't be used with profiles"))
+	}
+
 	log.Log(requestID, "Received VOD Upload request", "pipeline_strategy", uploadVODRequest.PipelineStrategy, "num_profiles", len(uploadVODRequest.Profiles))
 
+	// Retried requests return the job started by the first attempt
+	idempotencyKey := uploadVODRequest.idempotencyKey(req)
+	if existingRequestID, duplicate := d.VODEngine.DeduplicateUploadJob(idempotencyKey, requestID); duplicate {
+		return writeUploadVODResponse(w, existingRequestID)
+	}
+
 	// Once we're happy with the request, do the rest of the Segmenting stage asynchronously to allow us to
 	// from the API call and free up the HTTP connection
 
@@ -175,6 +227,7 @@ func (d *CatalystAPIHandlersCollection) handleUploadVOD(w http.ResponseWriter, r
 		HlsTargetURL:          hlsTargetURL,
 		Mp4TargetURL:          mp4TargetURL,
 		Mp4OnlyShort:          mp4OnlyShort,
+		GenerateCMAF:          generateCMAF,
 		AccessToken:           uploadVODRequest.AccessToken,
 		TranscodeAPIUrl:       uploadVODRequest.TranscodeAPIUrl,
 		RequestID:             requestID,
@@ -182,9 +235,18 @@ func (d *CatalystAPIHandlersCollection) handleUploadVOD(w http.ResponseWriter, r
 		Profiles:              uploadVODRequest.Profiles,
 		PipelineStrategy:      uploadVODRequest.PipelineStrategy,
 		TargetSegmentSizeSecs: uploadVODRequest.TargetSegmentSizeSecs,
+		Priority:              uploadVODRequest.Priority,
+		IdempotencyKey:        idempotencyKey,
 		Encryption:            uploadVODRequest.Encryption,
+		Thumbnails:            uploadVODRequest.Thumbnails,
+		Captions:              uploadVODRequest.Captions,
+		PerTitle:              uploadVODRequest.PerTitle,
 	})
 
+	return writeUploadVODResponse(w, requestID)
+}
+
+func writeUploadVODResponse(w http.ResponseWriter, requestID string) (bool, errors.APIError) {
 	respBytes, err := json.Marshal(UploadVODResponse{RequestID: requestID})
 	if err != nil {
 		log.LogError(requestID, "Failed to build a /upload HTTP API response", err)
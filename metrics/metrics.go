@@ -19,6 +19,10 @@ type VODPipelineMetrics struct {
 	TranscodedSegments *prometheus.CounterVec
 	SourceBytes        *prometheus.SummaryVec
 	SourceDuration     *prometheus.SummaryVec
+	QueueDepth         prometheus.Gauge
+	QueueWaitTime      *prometheus.HistogramVec
+	RoutingDecisions   *prometheus.CounterVec
+	StageDuration      *prometheus.HistogramVec
 }
 
 type CatalystAPIMetrics struct {
@@ -27,6 +31,7 @@ type CatalystAPIMetrics struct {
 	UploadVODRequestDurationSec *prometheus.SummaryVec
 	TranscodeSegmentDurationSec prometheus.Histogram
 	PlaybackRequestDurationSec  *prometheus.SummaryVec
+	CallbackOutboxSize          *prometheus.GaugeVec
 
 	TranscodingStatusUpdate ClientMetrics
 	BroadcasterClient       ClientMetrics
@@ -65,6 +70,11 @@ func NewMetrics() *CatalystAPIMetrics {
 			Help: "The latency of the requests made to /asset/hls in seconds broken up by success and status code",
 		}, []string{"success", "status_code", "version"}),
 
+		CallbackOutboxSize: promauto.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "callback_outbox_size",
+			Help: "Number of terminal status callbacks in the outbox, either pending delivery or in the dead-letter list",
+		}, []string{"queue"}),
+
 		// Clients metrics
 
 		TranscodingStatusUpdate: ClientMetrics{
@@ -156,6 +166,24 @@ func NewMetrics() *CatalystAPIMetrics {
 				Name: "vod_source_duration",
 				Help: "Duration of the source asset",
 			}, vodLabels),
+			QueueDepth: promauto.NewGauge(prometheus.GaugeOpts{
+				Name: "vod_queue_depth",
+				Help: "Number of VOD jobs waiting in the queue for a free slot",
+			}),
+			QueueWaitTime: promauto.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "vod_queue_wait_time_seconds",
+				Help:    "Time VOD jobs spent waiting in the queue before starting",
+				Buckets: []float64{.1, 1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+			}, []string{"priority"}),
+			RoutingDecisions: promauto.NewCounterVec(prometheus.CounterOpts{
+				Name: "vod_routing_decisions",
+				Help: "Number of VOD jobs routed to each strategy, by the routing rule that matched. The rule is empty for jobs that matched none",
+			}, []string{"rule", "strategy"}),
+			StageDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "vod_stage_duration_seconds",
+				Help:    "Time taken by each stage of the VOD jobs",
+				Buckets: []float64{.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+			}, []string{"stage", "pipeline"}),
 		},
 	}
 
@@ -9,6 +9,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/config"
 	"github.com/livepeer/catalyst-api/pipeline"
 	"github.com/stretchr/testify/require"
 )
@@ -44,8 +45,11 @@ func TestItErrorsWhenNoCapacityAvailable(t *testing.T) {
 	coordinator := pipeline.NewStubCoordinatorOpts(pipeline.StrategyCatalystFfmpegDominance, nil, pipeFfmpeg, nil, "")
 	coordinator.InputCopy = &clients.StubInputCopy{}
 
-	// Create a lot of in-flight jobs
-	for x := 0; x < 8; x++ {
+	defer func(queued int) { config.MaxQueuedJobs = queued }(config.MaxQueuedJobs)
+	config.MaxQueuedJobs = 2
+
+	// Fill all the slots and the queue
+	for x := 0; x < config.MaxJobsInFlight+config.MaxQueuedJobs; x++ {
 		coordinator.StartUploadJob(pipeline.UploadJobPayload{
 			RequestID: fmt.Sprintf("request-%d", x),
 		})
@@ -0,0 +1,326 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/grafov/m3u8"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/errors"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+const clipUploadTimeout = 5 * time.Minute
+
+// clip cuts a clip out of a rendition of an existing HLS asset. The segments
+// fully inside the clip are copied as they are and the boundary segments are
+// re-encoded to start and end on the exact frames, with a discontinuity
+// around them since their timestamps don't follow the copied ones.
+type clip struct{}
+
+func (c *clip) Name() string {
+	return "clip"
+}
+
+func (c *clip) HandleStartUploadJob(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+	log.Log(job.RequestID, "Handling job via clip pipeline")
+	if job.Clip == nil {
+		return nil, errors.Unretriable(fmt.Errorf("no clip range in clip job"))
+	}
+	if job.HlsTargetURL == nil {
+		return nil, errors.Unretriable(fmt.Errorf("clips require an HLS output"))
+	}
+
+	// the source can be the master manifest of the asset, in which case the
+	// highest rendition is clipped
+	sourceManifest, sourceManifestURL, err := clients.DownloadMediaManifest(job.RequestID, job.SourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading source manifest: %w", err)
+	}
+	sourceSegments, err := clients.GetSourceSegmentURLs(sourceManifestURL, sourceManifest)
+	if err != nil {
+		return nil, fmt.Errorf("error getting source segments: %w", err)
+	}
+	if len(sourceSegments) > 0 && sourceSegments[0].InitURL != nil {
+		return nil, errors.Unretriable(fmt.Errorf("clips of fMP4 sources aren't supported"))
+	}
+	var durations []float64
+	for _, segment := range sourceManifest.GetAllSegments() {
+		durations = append(durations, segment.Duration)
+	}
+	clipSegments, err := video.SelectClipSegments(durations, *job.Clip)
+	if err != nil {
+		return nil, errors.Unretriable(err)
+	}
+	job.sourceSegments = len(clipSegments)
+	job.ReportProgress(clients.TranscodeStatusPreparingCompleted, 1)
+
+	dir, err := os.MkdirTemp(os.TempDir(), "clip-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir for the clip: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	// the clip segments are spooled to disk until the rendition name is known
+	spoolDir := filepath.Join(dir, "segments")
+	if err := os.Mkdir(spoolDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create clip segments dir: %w", err)
+	}
+	segmentsList := video.NewTSegmentList(spoolDir)
+
+	// the MP4 is cut from the untrimmed segments, since the trimmed ones can't
+	// be concatenated to the others
+	var mp4Source *os.File
+	if job.GenerateMP4 {
+		mp4Source, err = os.Create(filepath.Join(dir, "source.ts"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mp4 source file: %w", err)
+		}
+		defer mp4Source.Close()
+	}
+
+	job.state = "clipping"
+	clipStart := time.Now()
+	playlist, err := m3u8.NewMediaPlaylist(0, uint(len(clipSegments)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clip playlist: %w", err)
+	}
+	playlist.TargetDuration = sourceManifest.TargetDuration
+	stats := &clipRenditionStats{}
+	for i, segment := range clipSegments {
+		data, err := downloadClipSegment(ctx, job.RequestID, sourceSegments[segment.Index])
+		if err != nil {
+			return nil, err
+		}
+		if mp4Source != nil {
+			if _, err := mp4Source.Write(data); err != nil {
+				return nil, fmt.Errorf("failed to write mp4 source file: %w", err)
+			}
+		}
+		if segment.Trimmed {
+			data, err = trimClipSegment(ctx, dir, data, segment, durations[segment.Index])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err := segmentsList.AddSegmentData(i, data); err != nil {
+			return nil, err
+		}
+		stats.addSegment(segment, data)
+
+		if err := playlist.Append(fmt.Sprintf("%d.ts", i), segment.DurationSecs, ""); err != nil {
+			return nil, fmt.Errorf("failed to append to clip playlist: %w", err)
+		}
+		if i > 0 && (segment.Trimmed || clipSegments[i-1].Trimmed) {
+			if err := playlist.SetDiscontinuity(); err != nil {
+				return nil, fmt.Errorf("failed to set discontinuity: %w", err)
+			}
+		}
+		job.ReportProgress(clients.TranscodeStatusTranscoding, float64(i+1)/float64(len(clipSegments))*0.5)
+	}
+
+	renditionStats := stats.finish()
+	renditionURL := job.HlsTargetURL.JoinPath(renditionStats.Name)
+	for _, i := range segmentsList.GetSortedSegments() {
+		data, err := segmentsList.GetSegment(i)
+		if err != nil {
+			return nil, err
+		}
+		err = backoff.Retry(func() error {
+			return clients.UploadToOSURL(renditionURL.String(), fmt.Sprintf("%d.ts", i), bytes.NewReader(data), clipUploadTimeout)
+		}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload clip segment %d: %w", i, err)
+		}
+		job.ReportProgress(clients.TranscodeStatusTranscoding, 0.5+float64(i+1)/float64(len(clipSegments))*0.5)
+	}
+	job.RecordStage(clients.NewJobStage("clipping", clipStart, renditionStats.Bytes, len(clipSegments)))
+
+	manifestURL, err := clients.GenerateAndUploadManifests(*playlist, job.HlsTargetURL.String(), []*video.RenditionStats{renditionStats}, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload clip manifests: %w", err)
+	}
+
+	var mp4Output *video.OutputVideoFile
+	if mp4Source != nil {
+		mp4Output, err = clipToMP4(ctx, job, mp4Source.Name(), clipSegments, renditionStats.Name)
+		if err != nil {
+			// the MP4 is best effort, like in the transcode pipelines
+			log.LogError(job.RequestID, "Failed to generate clip mp4", err)
+		}
+	}
+
+	hlsPlaybackBaseURL, mp4PlaybackBaseURL, err := clients.Publish(job.HlsTargetURL.String(), toStr(job.Mp4TargetURL))
+	if err != nil {
+		return nil, err
+	}
+	output := video.OutputVideo{
+		Type:     "object_store",
+		Manifest: strings.ReplaceAll(manifestURL, job.HlsTargetURL.String(), hlsPlaybackBaseURL),
+		Videos: []video.OutputVideoFile{{
+			Location:  strings.ReplaceAll(renditionStats.ManifestLocation, job.HlsTargetURL.String(), hlsPlaybackBaseURL),
+			SizeBytes: renditionStats.Bytes,
+		}},
+	}
+	if mp4Output != nil {
+		mp4Output.Location = strings.ReplaceAll(mp4Output.Location, toStr(job.Mp4TargetURL), mp4PlaybackBaseURL)
+		output.MP4Outputs = []video.OutputVideoFile{*mp4Output}
+	}
+	job.TranscodingDone = time.Now()
+	job.transcodedSegments = len(clipSegments)
+
+	return &HandlerOutput{
+		Result: &UploadJobResult{
+			InputVideo: job.InputFileInfo,
+			Outputs:    []video.OutputVideo{output},
+		}}, nil
+}
+
+func downloadClipSegment(ctx context.Context, requestID string, segment clients.SourceSegment) ([]byte, error) {
+	var data []byte
+	err := backoff.Retry(func() error {
+		ctx, cancel := context.WithTimeout(ctx, clients.MaxCopyFileDuration)
+		defer cancel()
+		rc, err := clients.GetSourceSegment(ctx, requestID, segment)
+		if err != nil {
+			return fmt.Errorf("failed to download source segment %q: %w", segment.URL, err)
+		}
+		defer rc.Close()
+		data, err = io.ReadAll(rc)
+		return err
+	}, backoff.WithContext(clients.DownloadRetryBackoff(), ctx))
+	return data, err
+}
+
+// trimClipSegment re-encodes the part of a boundary segment kept in the clip,
+// at the bitrate of the source segment.
+func trimClipSegment(ctx context.Context, dir string, data []byte, segment video.ClipSegment, sourceDurationSecs float64) ([]byte, error) {
+	inputFile := filepath.Join(dir, fmt.Sprintf("%d.ts", segment.Index))
+	outputFile := filepath.Join(dir, fmt.Sprintf("%d-trimmed.ts", segment.Index))
+	if err := os.WriteFile(inputFile, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write segment %d: %w", segment.Index, err)
+	}
+	var bitrate int64
+	if sourceDurationSecs > 0 {
+		bitrate = int64(float64(len(data)*8) / sourceDurationSecs)
+	}
+	// the trimmed segments are re-encoded with H264, which would switch codec
+	// in the middle of the rendition for the other video codecs
+	var videoCodec string
+	if info, err := video.ParseTSSegment(data); err == nil {
+		videoCodec, _, _ = strings.Cut(info.Codecs, ",")
+	}
+	if !strings.HasPrefix(videoCodec, "avc1.") {
+		videoCodec = ""
+		codecName, err := video.VideoCodecName(ctx, inputFile)
+		if err != nil {
+			return nil, err
+		}
+		if codecName != "" {
+			return nil, errors.Unretriable(fmt.Errorf("clips of %s sources aren't supported, only H264", codecName))
+		}
+	}
+	if err := video.TrimSegment(ctx, inputFile, outputFile, segment.StartSecs, segment.DurationSecs, bitrate, videoCodec); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(outputFile)
+}
+
+// clipRenditionStats accumulates the stats of the clip rendition as its
+// segments are cut, so that they don't need to be kept in memory.
+type clipRenditionStats struct {
+	stats    video.RenditionStats
+	segments int
+	// the codecs are only kept when all the segments agree, since the trimmed
+	// ones were re-encoded
+	codecs       string
+	codecsDiffer bool
+}
+
+func (s *clipRenditionStats) addSegment(segment video.ClipSegment, data []byte) {
+	durationMs := segment.DurationSecs * 1000
+	s.stats.Bytes += int64(len(data))
+	s.stats.DurationMs += durationMs
+	if durationMs > 0 {
+		if peak := uint32(float64(len(data)*8) / (durationMs / 1000)); peak > s.stats.PeakBitsPerSecond {
+			s.stats.PeakBitsPerSecond = peak
+		}
+	}
+	s.segments++
+
+	info, err := video.ParseTSSegment(data)
+	if err != nil {
+		s.codecsDiffer = true
+		return
+	}
+	if s.stats.Width == 0 && info.Width > 0 {
+		s.stats.Width, s.stats.Height, s.stats.FPS = info.Width, info.Height, info.FPS
+	}
+	if s.segments == 1 {
+		s.codecs = info.Codecs
+	} else if info.Codecs != s.codecs {
+		s.codecsDiffer = true
+	}
+}
+
+// finish returns the stats of the clip rendition, named after its resolution
+// like the transcoded renditions.
+func (s *clipRenditionStats) finish() *video.RenditionStats {
+	stats := s.stats
+	if stats.DurationMs > 0 {
+		stats.BitsPerSecond = uint32(float64(stats.Bytes*8) / (stats.DurationMs / 1000))
+	}
+	if !s.codecsDiffer {
+		stats.Codecs = s.codecs
+	}
+	stats.Name = "audio"
+	if stats.Height > 0 {
+		stats.Name = fmt.Sprintf("%dp0", stats.Height)
+	}
+	return &stats
+}
+
+func clipToMP4(ctx context.Context, job *JobInfo, tsFile string, segments []video.ClipSegment, rendition string) (*video.OutputVideoFile, error) {
+	var durationSecs float64
+	for _, segment := range segments {
+		durationSecs += segment.DurationSecs
+	}
+	mp4Start := time.Now()
+	mp4File := strings.TrimSuffix(tsFile, filepath.Ext(tsFile)) + ".mp4"
+	if err := video.ClipToMP4(ctx, tsFile, mp4File, segments[0].StartSecs, durationSecs); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(mp4File)
+	if err != nil {
+		return nil, fmt.Errorf("error opening mp4: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading mp4: %w", err)
+	}
+
+	filename := rendition + ".mp4"
+	err = backoff.Retry(func() error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return clients.UploadToOSURL(job.Mp4TargetURL.String(), filename, f, clipUploadTimeout)
+	}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload mp4: %w", err)
+	}
+	job.RecordStage(clients.NewJobStage("mp4_muxing", mp4Start, info.Size(), 0))
+	return &video.OutputVideoFile{
+		Type:      "mp4",
+		Location:  job.Mp4TargetURL.JoinPath(filename).String(),
+		SizeBytes: info.Size(),
+	}, nil
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/video"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItCanClipWholeSegments(t *testing.T) {
+	sourceManifest, err := filepath.Abs("../test/fixtures/tiny.m3u8")
+	require.NoError(t, err)
+	outputDir := t.TempDir()
+	hlsTargetURL, err := url.Parse("file://" + outputDir)
+	require.NoError(t, err)
+
+	p := UploadJobPayload{
+		SourceFile:    sourceManifest,
+		RequestID:     "clip-request",
+		HlsTargetURL:  hlsTargetURL,
+		Clip:          &video.ClipRange{StartSecs: 10, EndSecs: 30},
+		InputFileInfo: video.InputVideo{Format: "hls", Duration: 20},
+	}
+	p.status = newJobStatusTracker(p)
+	p.timeline = newJobTimeline()
+	job := &JobInfo{
+		UploadJobPayload: p,
+		statusClient:     &mockCallbackClient{},
+	}
+
+	out, err := (&clip{}).HandleStartUploadJob(context.Background(), job)
+	require.NoError(t, err)
+	require.Len(t, out.Result.Outputs, 1)
+	require.Len(t, out.Result.Outputs[0].Videos, 1)
+	require.Equal(t, 2, job.transcodedSegments)
+
+	// the segments inside the clip are copied as they are
+	for i, source := range []string{"seg-1.ts", "seg-2.ts"} {
+		expected, err := os.ReadFile(filepath.Join("../test/fixtures", source))
+		require.NoError(t, err)
+		actual, err := os.ReadFile(filepath.Join(outputDir, "270p0", fmt.Sprintf("%d.ts", i)))
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
+
+	rendition, err := os.ReadFile(filepath.Join(outputDir, "270p0", "index.m3u8"))
+	require.NoError(t, err)
+	require.Equal(t, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.000,\n0.ts\n#EXTINF:10.000,\n1.ts\n#EXT-X-ENDLIST\n", string(rendition))
+
+	master, err := os.ReadFile(filepath.Join(outputDir, clients.MASTER_MANIFEST_FILENAME))
+	require.NoError(t, err)
+	require.Contains(t, string(master), "RESOLUTION=480x270")
+	require.Contains(t, string(master), "270p0/index.m3u8")
+}
+
+func TestItCanClipFromAMasterManifest(t *testing.T) {
+	rendition, err := filepath.Abs("../test/fixtures/tiny.m3u8")
+	require.NoError(t, err)
+	sourceManifest := filepath.Join(t.TempDir(), "index.m3u8")
+	master := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000,RESOLUTION=480x270\n" + rendition + "\n"
+	require.NoError(t, os.WriteFile(sourceManifest, []byte(master), 0644))
+	outputDir := t.TempDir()
+	hlsTargetURL, err := url.Parse("file://" + outputDir)
+	require.NoError(t, err)
+
+	p := UploadJobPayload{
+		SourceFile:    sourceManifest,
+		RequestID:     "clip-request",
+		HlsTargetURL:  hlsTargetURL,
+		Clip:          &video.ClipRange{StartSecs: 10, EndSecs: 30},
+		InputFileInfo: video.InputVideo{Format: "hls", Duration: 20},
+	}
+	p.status = newJobStatusTracker(p)
+	p.timeline = newJobTimeline()
+	job := &JobInfo{
+		UploadJobPayload: p,
+		statusClient:     &mockCallbackClient{},
+	}
+
+	_, err = (&clip{}).HandleStartUploadJob(context.Background(), job)
+	require.NoError(t, err)
+	require.Equal(t, 2, job.transcodedSegments)
+	expected, err := os.ReadFile("../test/fixtures/seg-2.ts")
+	require.NoError(t, err)
+	actual, err := os.ReadFile(filepath.Join(outputDir, "270p0", "1.ts"))
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestClipDownloadsStopRetryingWhenCancelled(t *testing.T) {
+	u, err := url.Parse(filepath.Join(t.TempDir(), "missing.ts"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err = downloadClipSegment(ctx, "clip-request", clients.SourceSegment{URL: u})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestItRejectsClipsOutsideOfTheSource(t *testing.T) {
+	sourceManifest, err := filepath.Abs("../test/fixtures/tiny.m3u8")
+	require.NoError(t, err)
+	hlsTargetURL, err := url.Parse("file://" + t.TempDir())
+	require.NoError(t, err)
+
+	p := UploadJobPayload{
+		SourceFile:   sourceManifest,
+		RequestID:    "clip-request",
+		HlsTargetURL: hlsTargetURL,
+		Clip:         &video.ClipRange{StartSecs: 60, EndSecs: 70},
+	}
+	p.status = newJobStatusTracker(p)
+	job := &JobInfo{
+		UploadJobPayload: p,
+		statusClient:     &mockCallbackClient{},
+	}
+
+	_, err = (&clip{}).HandleStartUploadJob(context.Background(), job)
+	require.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"crypto/rsa"
 	"database/sql"
 	"fmt"
@@ -25,37 +26,6 @@ import (
 	"github.com/livepeer/catalyst-api/video"
 )
 
-// Strategy indicates how the pipelines should be coordinated. Mainly changes
-// which pipelines to execute, in what order, and which ones go in background.
-// Background pipelines are only logged and are not reported back to the client.
-type Strategy string
-
-const (
-	// Only execute the external pipeline
-	StrategyExternalDominance Strategy = "external"
-	// Only execute the FFMPEG / Livepeer pipeline
-	StrategyCatalystFfmpegDominance Strategy = "catalyst_ffmpeg"
-	// Execute the FFMPEG / Livepeer pipeline in foreground and the external transcoder in background.
-	StrategyBackgroundExternal Strategy = "background_external"
-	// Execute the FFMPEG pipeline first and fallback to the external transcoding
-	// provider on errors.
-	StrategyFallbackExternal Strategy = "fallback_external"
-)
-
-const (
-	// Only mp4s of maxMP4OutDuration will have MP4s generated for each rendition
-	maxMP4OutDuration = 2 * time.Minute
-)
-
-func (s Strategy) IsValid() bool {
-	switch s {
-	case StrategyExternalDominance, StrategyCatalystFfmpegDominance, StrategyBackgroundExternal, StrategyFallbackExternal:
-		return true
-	default:
-		return false
-	}
-}
-
 // UploadJobPayload is the required payload to start an upload job.
 type UploadJobPayload struct {
 	SourceFile            string
@@ -77,6 +47,35 @@ type UploadJobPayload struct {
 	SignedSourceURL       string
 	InFallbackMode        bool
 	LivepeerSupported     bool
+	// Jobs with a higher priority are started first when jobs are queued
+	Priority int
+	// Either the Idempotency-Key header or the external ID of the request
+	IdempotencyKey string
+	// Set when the job is restarted, so that the pipelines can reuse the
+	// outputs already written by the previous attempt
+	ResumeFromCheckpoint bool
+	// Number of the current attempt at running the job, starting from 1
+	Attempt int
+	// Generate seek-preview thumbnails and a poster next to the HLS output
+	Thumbnails *video.ThumbnailOptions
+	// Also write the renditions as CMAF fMP4 segments with HLS and DASH
+	// manifests. Only supported by the Catalyst ffmpeg pipelines.
+	GenerateCMAF bool
+	// Sidecar SRT or WebVTT files added as subtitle renditions to the HLS output
+	Captions []video.CaptionFile
+	// Build the ABR ladder from a complexity analysis of the source when no
+	// profiles are given. Only supported by the Catalyst ffmpeg pipelines.
+	PerTitle *video.PerTitleOptions
+	// Cut a clip out of the source HLS rendition instead of transcoding it
+	Clip *video.ClipRange
+
+	// set for foreground jobs when a JobStore is configured
+	persisted *persistedJob
+	status    *jobStatusTracker
+	timeline  *jobTimeline
+	// the payload the job was submitted with, which is restarted from
+	// scratch when the job is retried. Not set for background jobs.
+	original *UploadJobPayload
 }
 
 type EncryptionPayload struct {
@@ -113,6 +112,7 @@ type JobInfo struct {
 	SegmentingTargetURL string
 	SourceOutputURL     string
 
+	ctx          context.Context
 	handler      Handler
 	hasFallback  bool
 	statusClient clients.TranscodeStatusClient
@@ -138,6 +138,9 @@ type JobInfo struct {
 	sourceSampleRate   int
 	sourceSampleBits   int
 
+	// sidecar and embedded subtitles to add to the HLS output
+	subtitles []video.Subtitles
+
 	transcodedSegments    int
 	targetSegmentSizeSecs int64
 	pipeline              string
@@ -147,7 +150,10 @@ type JobInfo struct {
 }
 
 func (j *JobInfo) ReportProgress(stage clients.TranscodeStatus, completionRatio float64) {
+	j.persisted.updateStage(stage)
+	j.status.progress(stage, completionRatio)
 	tsm := clients.NewTranscodeStatusProgress(j.CallbackURL, j.RequestID, stage, completionRatio)
+	tsm.Attempt = j.Attempt
 	// Ignore errors, send the progress next time
 	_ = j.statusClient.SendTranscodeStatus(tsm)
 }
@@ -158,20 +164,28 @@ func (j *JobInfo) ReportProgress(stage clients.TranscodeStatus, completionRatio
 type Coordinator struct {
 	strategy     Strategy
 	statusClient clients.TranscodeStatusClient
+	events       *jobEvents
 
-	pipeFfmpeg, pipeExternal Handler
+	pipeFfmpeg, pipeExternal, pipeSoftware, pipeClip Handler
 
 	Jobs                 *cache.Cache[*JobInfo]
+	cancels              *cache.Cache[context.CancelFunc]
+	queue                *jobQueue
+	dedup                *jobDedup
+	JobStore             JobStore
+	Routing              *RoutingRules
+	CallbackOutbox       *clients.CallbackOutbox
 	MetricsDB            *sql.DB
 	InputCopy            clients.InputCopier
 	VodDecryptPrivateKey *rsa.PrivateKey
 }
 
-func NewCoordinator(strategy Strategy, sourceOutputURL, extTranscoderURL string, statusClient clients.TranscodeStatusClient, metricsDB *sql.DB, VodDecryptPrivateKey *rsa.PrivateKey) (*Coordinator, error) {
+func NewCoordinator(strategy Strategy, sourceOutputURL, extTranscoderURL string, statusClient clients.TranscodeStatusClient, metricsDB *sql.DB, jobStore JobStore, VodDecryptPrivateKey *rsa.PrivateKey) (*Coordinator, error) {
 
 	if !strategy.IsValid() {
 		return nil, fmt.Errorf("invalid strategy: %s", strategy)
 	}
+	strategy = strategy.resolve()
 
 	var extTranscoder clients.TranscodeProvider
 	if extTranscoderURL != "" {
@@ -181,16 +195,24 @@ func NewCoordinator(strategy Strategy, sourceOutputURL, extTranscoderURL string,
 			return nil, fmt.Errorf("error creating external transcoder: %v", err)
 		}
 	}
-	if strategy != StrategyCatalystFfmpegDominance && extTranscoder == nil {
+	if !strategies[strategy].local && extTranscoder == nil {
 		return nil, fmt.Errorf("external transcoder is required for strategy: %v", strategy)
 	}
 
+	events := newJobEvents(statusClient)
 	return &Coordinator{
 		strategy:     strategy,
-		statusClient: statusClient,
+		statusClient: events,
+		events:       events,
 		pipeFfmpeg:   &ffmpeg{SourceOutputUrl: sourceOutputURL},
 		pipeExternal: &external{extTranscoder},
+		pipeSoftware: newSoftware(sourceOutputURL),
+		pipeClip:     &clip{},
 		Jobs:         cache.New[*JobInfo](),
+		cancels:      cache.New[context.CancelFunc](),
+		queue:        newJobQueue(),
+		dedup:        newJobDedup(),
+		JobStore:     jobStore,
 		MetricsDB:    metricsDB,
 		InputCopy: &clients.InputCopy{
 			Probe:           video.Probe{},
@@ -200,6 +222,18 @@ func NewCoordinator(strategy Strategy, sourceOutputURL, extTranscoderURL string,
 	}, nil
 }
 
+// CheckStrategy returns an error if the strategy can't run with the
+// configuration of the coordinator, i.e. it needs a missing external transcoder.
+func (c *Coordinator) CheckStrategy(strategy Strategy) error {
+	if !strategy.IsValid() {
+		return fmt.Errorf("invalid strategy: %s", strategy)
+	}
+	if ext, ok := c.pipeExternal.(*external); ok && ext.transcoder == nil && !strategies[strategy.resolve()].local {
+		return fmt.Errorf("external transcoder is required for strategy: %v", strategy)
+	}
+	return nil
+}
+
 func NewStubCoordinator() *Coordinator {
 	return NewStubCoordinatorOpts(StrategyCatalystFfmpegDominance, nil, nil, nil, "")
 }
@@ -208,6 +242,7 @@ func NewStubCoordinatorOpts(strategy Strategy, statusClient clients.TranscodeSta
 	if strategy == "" {
 		strategy = StrategyCatalystFfmpegDominance
 	}
+	strategy = strategy.resolve()
 	if statusClient == nil {
 		statusClient = clients.TranscodeStatusFunc(func(tsm clients.TranscodeStatusMessage) error { return nil })
 	}
@@ -217,37 +252,76 @@ func NewStubCoordinatorOpts(strategy Strategy, statusClient clients.TranscodeSta
 	if pipeExternal == nil {
 		pipeExternal = &external{}
 	}
+	events := newJobEvents(statusClient)
 	return &Coordinator{
 		strategy:     strategy,
-		statusClient: statusClient,
+		statusClient: events,
+		events:       events,
 		pipeFfmpeg:   pipeFfmpeg,
 		pipeExternal: pipeExternal,
+		pipeSoftware: newSoftware(sourceOutputUrl),
+		pipeClip:     &clip{},
 		Jobs:         cache.New[*JobInfo](),
+		cancels:      cache.New[context.CancelFunc](),
+		queue:        newJobQueue(),
+		dedup:        newJobDedup(),
 		InputCopy: &clients.InputCopy{
 			Probe: video.Probe{},
 		},
 	}
 }
 
-// Starts a new upload job.
+// Starts a new upload job. The job waits in the queue if there are already
+// config.MaxJobsInFlight jobs running.
+func (c *Coordinator) StartUploadJob(p UploadJobPayload) {
+	if p.Attempt == 0 {
+		p.Attempt = 1
+	}
+	p.persisted = newPersistedJob(c.JobStore, p)
+	p.persisted.save()
+	p.status = newJobStatusTracker(p)
+	p.timeline = newJobTimeline()
+	original := p
+	p.original = &original
+	c.enqueueJob(p, time.Time{})
+}
+
+// runUploadJob runs an upload job once it's been picked from the queue.
 //
 // This has the main logic regarding the pipeline strategy. It starts jobs and
 // handles processing the response and triggering a fallback if appropriate.
-func (c *Coordinator) StartUploadJob(p UploadJobPayload) {
+func (c *Coordinator) runUploadJob(p UploadJobPayload) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancels.Store(p.RequestID, cancel)
+
 	// A bit hacky - this is effectively a dummy job object to allow us to reuse the runHandlerAsync and
-	// progress reporting logic. The real job objects still get created in startOneUploadJob().
+	// progress reporting logic. The real job objects still get created in startOneUploadJob() and
+	// replace this one in the jobs cache.
 	si := &JobInfo{
 		UploadJobPayload: p,
+		StreamName:       config.SegmentingStreamName(p.RequestID),
+		ctx:              ctx,
 		statusClient:     c.statusClient,
 		startTime:        time.Now(),
+		result:           make(chan bool, 1),
 
 		numProfiles:    len(p.Profiles),
 		state:          "segmenting",
 		catalystRegion: os.Getenv("MY_REGION"),
 	}
 	si.ReportProgress(clients.TranscodeStatusPreparing, 0)
+	c.Jobs.Store(si.StreamName, si)
 
 	c.runHandlerAsync(si, func() (*HandlerOutput, error) {
+		if p.Clip != nil {
+			// clips are cut from HLS assets that are already in storage, so
+			// there's nothing to copy nor to route to another pipeline
+			p.InputFileInfo = video.InputVideo{Format: "hls", Duration: p.Clip.Duration()}
+			p.GenerateMP4 = generateMP4(p.Mp4TargetURL, p.Mp4OnlyShort, p.Clip.Duration())
+			c.startOneUploadJob(ctx, p, c.pipeClip, true, false)
+			return nil, nil
+		}
+
 		sourceURL, err := url.Parse(si.SourceFile)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing source as url: %w", err)
@@ -262,57 +336,66 @@ func (c *Coordinator) StartUploadJob(p UploadJobPayload) {
 			}
 		}
 
-		inputVideoProbe, signedNewSourceURL, newSourceURL, err := c.InputCopy.CopyInputToS3(si.RequestID, sourceURL, decryptor)
+		inputCopyStart := time.Now()
+		inputVideoProbe, signedNewSourceURL, newSourceURL, err := c.InputCopy.CopyInputToS3(ctx, si.RequestID, sourceURL, decryptor)
 		if err != nil {
 			return nil, fmt.Errorf("error copying input to storage: %w", err)
 		}
+		si.RecordStage(clients.NewJobStage("input_copy", inputCopyStart, inputVideoProbe.SizeBytes, 0))
 
 		p.SourceFile = newSourceURL.String()   // OS URL used by mist
 		p.SignedSourceURL = signedNewSourceURL // http(s) URL used by mediaconvert
 		p.InputFileInfo = inputVideoProbe
-		p.GenerateMP4 = func(mp4TargetUrl *url.URL, mp4OnlyShort bool, duration float64) bool {
-			if mp4TargetUrl != nil && (!mp4OnlyShort || duration <= maxMP4OutDuration.Seconds()) {
-				return true
-			}
-			return false
-		}(p.Mp4TargetURL, p.Mp4OnlyShort, p.InputFileInfo.Duration)
+		p.GenerateMP4 = generateMP4(p.Mp4TargetURL, p.Mp4OnlyShort, p.InputFileInfo.Duration)
 
 		log.AddContext(si.RequestID, "new_source_url", newSourceURL)
 		log.AddContext(si.RequestID, "signed_url", signedNewSourceURL)
 
-		c.startUploadJob(p)
+		c.startUploadJob(ctx, p)
 		return nil, nil
 	})
 }
-func (c *Coordinator) startUploadJob(p UploadJobPayload) {
+
+func generateMP4(mp4TargetUrl *url.URL, mp4OnlyShort bool, duration float64) bool {
+	if mp4TargetUrl != nil && (!mp4OnlyShort || duration <= config.MP4OnlyShortMaxDuration.Seconds()) {
+		return true
+	}
+	return false
+}
+func (c *Coordinator) startUploadJob(ctx context.Context, p UploadJobPayload) {
 	strategy := c.strategy
 	if p.PipelineStrategy.IsValid() {
-		strategy = p.PipelineStrategy
+		strategy = p.PipelineStrategy.resolve()
 	}
-	p.LivepeerSupported, strategy = checkLivepeerCompatible(p.RequestID, strategy, p.InputFileInfo)
+	p.LivepeerSupported, strategy = c.routeJob(p, strategy)
 	log.AddContext(p.RequestID, "strategy", strategy)
+	p.status.update(func(s *JobStatus) {
+		s.Strategy = strategy
+	})
 	log.Log(p.RequestID, "Starting upload job")
 
-	switch strategy {
-	case StrategyExternalDominance:
-		c.startOneUploadJob(p, c.pipeExternal, true, false)
-	case StrategyCatalystFfmpegDominance:
-		c.startOneUploadJob(p, c.pipeFfmpeg, true, false)
-	case StrategyBackgroundExternal:
-		c.startOneUploadJob(p, c.pipeFfmpeg, true, false)
-		c.startOneUploadJob(p, c.pipeExternal, false, false)
-	case StrategyFallbackExternal:
-		// nolint:errcheck
-		go recovered(func() (t bool, e error) {
-			success := <-c.startOneUploadJob(p, c.pipeFfmpeg, true, true)
-			if !success {
+	strategies[strategy].start(c, ctx, p)
+}
+
+// startFallbackUploadJob runs the job in each of the handlers in turn, until
+// one of them succeeds.
+func (c *Coordinator) startFallbackUploadJob(ctx context.Context, p UploadJobPayload, handlers ...Handler) {
+	// nolint:errcheck
+	go recovered(func() (t bool, e error) {
+		for i, handler := range handlers {
+			if i > 0 {
 				p.InFallbackMode = true
-				log.Log(p.RequestID, "Entering fallback pipeline")
-				c.startOneUploadJob(p, c.pipeExternal, true, false)
+				log.Log(p.RequestID, "Entering fallback pipeline", "pipeline", handler.Name())
 			}
-			return
-		})
-	}
+			hasFallback := i < len(handlers)-1
+			success := <-c.startOneUploadJob(ctx, p, handler, true, hasFallback)
+			// a cancelled job must not fall back to the other pipeline
+			if success || ctx.Err() != nil {
+				return
+			}
+		}
+		return
+	})
 }
 
 // checkLivepeerCompatible checks if the input codecs are compatible with our Livepeer pipeline and overrides the pipeline strategy
@@ -337,8 +420,7 @@ func checkLivepeerCompatible(requestID string, strategy Strategy, iv video.Input
 }
 
 func livepeerNotSupported(strategy Strategy) (bool, Strategy) {
-	// Allow "dominance" strategies to pass through as these are used in tests and we might want to manually force them for debugging
-	if strategy == StrategyCatalystFfmpegDominance {
+	if strategies[strategy].livepeerOptional {
 		return false, strategy
 	}
 	return false, StrategyExternalDominance
@@ -383,7 +465,7 @@ func checkDisplayAspectRatio(track video.InputTrack, requestID string) bool {
 // The `hasFallback` argument means the caller has a special logic to handle
 // failures (today this means re-running the job in another pipeline). If it's
 // set to true, error callbacks from this job will not be sent.
-func (c *Coordinator) startOneUploadJob(p UploadJobPayload, handler Handler, foreground, hasFallback bool) <-chan bool {
+func (c *Coordinator) startOneUploadJob(ctx context.Context, p UploadJobPayload, handler Handler, foreground, hasFallback bool) <-chan bool {
 	if !foreground {
 		p.RequestID = fmt.Sprintf("bg_%s", p.RequestID)
 		if p.HlsTargetURL != nil {
@@ -391,6 +473,13 @@ func (c *Coordinator) startOneUploadJob(p UploadJobPayload, handler Handler, for
 		}
 		// this will prevent the callbacks for this job from actually being sent
 		p.CallbackURL = ""
+		p.persisted = nil
+		p.status = newJobStatusTracker(p)
+		p.timeline = newJobTimeline()
+		p.IdempotencyKey = ""
+		p.original = nil
+		p.Thumbnails = nil
+		p.GenerateCMAF = false
 	}
 	streamName := config.SegmentingStreamName(p.RequestID)
 	log.AddContext(p.RequestID, "stream_name", streamName)
@@ -400,6 +489,11 @@ func (c *Coordinator) startOneUploadJob(p UploadJobPayload, handler Handler, for
 	if pipeline == "external" {
 		pipeline = "aws-mediaconvert"
 	}
+	p.status.update(func(s *JobStatus) {
+		s.Pipeline = pipeline
+		s.InFallbackMode = p.InFallbackMode
+		s.SourceVideo = &p.InputFileInfo
+	})
 
 	// Codecs are parsed here primarily to write codec stats for each job
 	var videoCodec, audioCodec string
@@ -419,6 +513,7 @@ func (c *Coordinator) startOneUploadJob(p UploadJobPayload, handler Handler, for
 	si := &JobInfo{
 		UploadJobPayload: p,
 		StreamName:       streamName,
+		ctx:              ctx,
 		handler:          handler,
 		hasFallback:      hasFallback,
 		statusClient:     c.statusClient,
@@ -451,7 +546,7 @@ func (c *Coordinator) startOneUploadJob(p UploadJobPayload, handler Handler, for
 	log.Log(si.RequestID, "Wrote to jobs cache")
 
 	c.runHandlerAsync(si, func() (*HandlerOutput, error) {
-		return si.handler.HandleStartUploadJob(si)
+		return si.handler.HandleStartUploadJob(si.ctx, si)
 	})
 	return si.result
 }
@@ -467,6 +562,9 @@ func (c *Coordinator) runHandlerAsync(job *JobInfo, handler func() (*HandlerOutp
 		defer job.mu.Unlock()
 
 		out, err := recovered(handler)
+		if err == nil && out != nil && !out.Continue && out.Result != nil && job.Thumbnails != nil {
+			generateThumbnails(job, out.Result)
+		}
 		if err != nil || (out != nil && !out.Continue) {
 			c.finishJob(job, out, err)
 		}
@@ -477,8 +575,18 @@ func (c *Coordinator) runHandlerAsync(job *JobInfo, handler func() (*HandlerOutp
 
 func (c *Coordinator) finishJob(job *JobInfo, out *HandlerOutput, err error) {
 	defer close(job.result)
+	cancelled := err != nil && job.ctx != nil && job.ctx.Err() == context.Canceled
+	// a failed job with a fallback is not finished yet, the fallback pipeline takes over
+	retrying := err != nil && !cancelled && !job.hasFallback && c.shouldRetry(job, err)
 	var tsm clients.TranscodeStatusMessage
-	if err != nil {
+	if cancelled {
+		log.Log(job.RequestID, "Job was cancelled", "err", err)
+		tsm = clients.NewTranscodeStatusCancelled(job.CallbackURL, job.RequestID)
+		job.state = "cancelled"
+	} else if retrying {
+		log.Log(job.RequestID, "Job failed, retrying", "attempt", job.Attempt, "err", err)
+		job.state = "retrying"
+	} else if err != nil {
 		callbackURL := job.CallbackURL
 		if job.hasFallback {
 			// an empty url will skip actually sending the callback. we still want the log tho
@@ -490,15 +598,38 @@ func (c *Coordinator) finishJob(job *JobInfo, out *HandlerOutput, err error) {
 		tsm = clients.NewTranscodeStatusCompleted(job.CallbackURL, job.RequestID, out.Result.InputVideo, out.Result.Outputs)
 		job.state = "completed"
 	}
-	err2 := job.statusClient.SendTranscodeStatus(tsm)
+	var err2 error
+	if !retrying {
+		tsm.Attempt = job.Attempt
+		tsm.Timeline = job.timeline.snapshot()
+		err2 = job.statusClient.SendTranscodeStatus(tsm)
+	}
 	if err2 != nil {
 		log.LogError(tsm.RequestID, "failed sending finalize callback, job state set to 'failed'", err2)
 		job.state = "failed"
 	}
+	job.status.update(func(s *JobStatus) {
+		s.State = job.state
+		if err != nil && !cancelled {
+			s.LastError = err.Error()
+		}
+	})
 
 	// Automatically delete jobs after an error or result
 	success := err == nil && err2 == nil
 	c.Jobs.Remove(job.StreamName)
+	if retrying {
+		c.cancels.Remove(job.RequestID)
+		// free the slot first, since the new attempt has the same request ID
+		// and can be started as soon as it's enqueued
+		c.jobDone(job.RequestID)
+		c.retryJob(job, err)
+	} else if err == nil || !job.hasFallback || cancelled {
+		job.persisted.delete()
+		c.cancels.Remove(job.RequestID)
+		c.dedup.finish(job.IdempotencyKey, job.RequestID, err == nil)
+		c.jobDone(job.RequestID)
+	}
 
 	log.Log(job.RequestID, "Finished job and deleted from job cache", "success", success)
 
@@ -543,6 +674,69 @@ func (c *Coordinator) finishJob(job *JobInfo, out *HandlerOutput, err error) {
 	job.result <- success
 }
 
+// RecoverJobs loads the jobs that were still in flight when catalyst-api was
+// last stopped. They are either restarted from scratch (resume=true) or failed
+// with an error callback so that the caller doesn't wait for them until they
+// time out. Jobs that haven't been updated for longer than the callback
+// timeout are always failed, since the caller has given up on them already.
+func (c *Coordinator) RecoverJobs(resume bool) {
+	if c.JobStore == nil {
+		return
+	}
+	records, err := c.JobStore.List()
+	if err != nil {
+		log.LogNoRequestID("error listing jobs from the job store", "err", err)
+		return
+	}
+	for _, rec := range records {
+		p := rec.Payload
+		log.AddContext(p.RequestID, "external_id", p.ExternalID)
+		if resume && time.Since(rec.UpdatedAt) < clients.MAX_TIME_WITHOUT_UPDATE {
+			log.Log(p.RequestID, "Resuming job from the job store", "stage", rec.Stage.String())
+			p.ResumeFromCheckpoint = true
+			c.dedup.claim(p.IdempotencyKey, p.RequestID)
+			c.StartUploadJob(p)
+			continue
+		}
+
+		log.Log(p.RequestID, "Failing job interrupted by restart", "stage", rec.Stage.String(), "updated_at", rec.UpdatedAt)
+		tsm := clients.NewTranscodeStatusError(p.CallbackURL, p.RequestID, "job was interrupted by a catalyst-api restart", false)
+		if err := c.statusClient.SendTranscodeStatus(tsm); err != nil {
+			log.LogError(p.RequestID, "failed sending error callback for interrupted job", err)
+		}
+		if err := c.JobStore.Delete(p.RequestID); err != nil {
+			log.LogError(p.RequestID, "error deleting job from the job store", err)
+		}
+	}
+}
+
+// CancelJob stops the in-flight or queued job with the given request ID. The
+// handlers stop asynchronously and a "cancelled" status callback is sent once
+// they do. Returns false if there is no such job.
+func (c *Coordinator) CancelJob(requestID string) bool {
+	if p, ok := c.removeQueuedJob(requestID); ok {
+		log.Log(requestID, "Cancelling queued job")
+		p.persisted.delete()
+		c.dedup.finish(p.IdempotencyKey, p.RequestID, false)
+		tsm := clients.NewTranscodeStatusCancelled(p.CallbackURL, p.RequestID)
+		tsm.Attempt = p.Attempt
+		if err := c.statusClient.SendTranscodeStatus(tsm); err != nil {
+			log.LogError(requestID, "failed sending cancelled callback", err)
+		}
+		// the jobs behind this one moved up in the queue
+		c.dispatchJobs()
+		return true
+	}
+
+	cancel := c.cancels.Get(requestID)
+	if cancel == nil {
+		return false
+	}
+	log.Log(requestID, "Cancelling job")
+	cancel()
+	return true
+}
+
 func getProfileCount(out *HandlerOutput) int {
 	if out == nil || out.Result == nil || len(out.Result.Outputs) < 1 {
 		return 0
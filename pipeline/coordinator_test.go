@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"crypto/md5"
 	"errors"
 	"fmt"
@@ -75,7 +76,7 @@ func TestCoordinatorDoesNotBlock(t *testing.T) {
 	barrier := make(chan struct{})
 	var running atomic.Bool
 	blockHandler := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			running.Store(true)
 			defer running.Store(false)
 			<-barrier
@@ -108,7 +109,7 @@ func TestCoordinatorResistsPanics(t *testing.T) {
 
 	callbackHandler, callbacks := callbacksRecorder()
 	blockHandler := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			panic("oh no!")
 		},
 	}
@@ -156,7 +157,7 @@ func TestCoordinatorBackgroundJobsStrategies(t *testing.T) {
 	fgHandler, foregroundCalls := recordingHandler(nil)
 	backgroundCalls := make(chan *JobInfo, 10)
 	bgHandler := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			backgroundCalls <- job
 			// Test that background job is really hidden: status callbacks are not reported (empty URL)
 			job.ReportProgress(clients.TranscodeStatusPreparing, 0.2)
@@ -171,6 +172,8 @@ func TestCoordinatorBackgroundJobsStrategies(t *testing.T) {
 		var coord *Coordinator
 		if strategy == StrategyBackgroundExternal {
 			coord = NewStubCoordinatorOpts(strategy, callbackHandler, fgHandler, bgHandler, "")
+		} else if strategy == StrategyBackgroundMist {
+			coord = NewStubCoordinatorOpts(strategy, callbackHandler, bgHandler, fgHandler, "")
 		} else {
 			t.Fatalf("Unexpected strategy: %s", strategy)
 		}
@@ -205,6 +208,7 @@ func TestCoordinatorBackgroundJobsStrategies(t *testing.T) {
 	}
 
 	doTest(StrategyBackgroundExternal)
+	doTest(StrategyBackgroundMist)
 }
 
 func TestCoordinatorFallbackStrategySuccess(t *testing.T) {
@@ -249,7 +253,7 @@ func TestCoordinatorFallbackStrategyFailure(t *testing.T) {
 	ffmpeg, ffmpegCalls := recordingHandler(errors.New("ffmpeg error"))
 	externalCalls := make(chan *JobInfo, 10)
 	external := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			externalCalls <- job
 			job.ReportProgress(clients.TranscodeStatusPreparing, 0.2)
 			return testHandlerResult, nil
@@ -299,6 +303,77 @@ func TestCoordinatorFallbackStrategyFailure(t *testing.T) {
 	require.Zero(len(callbacks))
 }
 
+func TestCoordinatorFallbackSoftwareStrategy(t *testing.T) {
+	require := require.New(t)
+
+	callbackHandler, callbacks := callbacksRecorder()
+	ffmpeg, ffmpegCalls := recordingHandler(errors.New("ffmpeg error"))
+	external, externalCalls := recordingHandler(errors.New("external error"))
+	software, softwareCalls := recordingHandler(nil)
+
+	coord := NewStubCoordinatorOpts(StrategyFallbackSoftware, callbackHandler, ffmpeg, external, "")
+	coord.pipeSoftware = software
+
+	inputFile, _, cleanup := setupTransferDir(t, coord)
+	defer cleanup()
+	job := testJob
+	job.SourceFile = "file://" + inputFile.Name()
+	coord.StartUploadJob(job)
+
+	require.Equal("123", requireReceive(t, ffmpegCalls, 1*time.Second).RequestID)
+	require.Equal("123", requireReceive(t, externalCalls, 1*time.Second).RequestID)
+	softwareJob := requireReceive(t, softwareCalls, 1*time.Second)
+	require.Equal("123", softwareJob.RequestID)
+	require.True(softwareJob.InFallbackMode)
+
+	// only the last pipeline reports its result
+	for {
+		msg := requireReceive(t, callbacks, 1*time.Second)
+		require.NotEqual(clients.TranscodeStatusError, msg.Status)
+		if msg.Status == clients.TranscodeStatusCompleted {
+			break
+		}
+	}
+
+	time.Sleep(1 * time.Second)
+	require.Zero(len(callbacks))
+}
+
+func TestCoordinatorCancelsJob(t *testing.T) {
+	require := require.New(t)
+
+	callbackHandler, callbacks := callbacksRecorder()
+	running := make(chan struct{})
+	ffmpeg := &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			close(running)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	// the fallback must not be triggered for a cancelled job
+	coord := NewStubCoordinatorOpts(StrategyFallbackExternal, callbackHandler, ffmpeg, allFailingHandler(t), "")
+	inputFile, _, cleanup := setupTransferDir(t, coord)
+	defer cleanup()
+	job := testJob
+	job.SourceFile = "file://" + inputFile.Name()
+
+	require.False(coord.CancelJob("123"))
+	coord.StartUploadJob(job)
+	requireReceive(t, running, 5*time.Second)
+	require.True(coord.CancelJob("123"))
+
+	requireReceive(t, callbacks, 1*time.Second) // discard initial TranscodeStatusPreparing message
+	requireReceive(t, callbacks, 1*time.Second) // discard second TranscodeStatusPreparing message
+	msg := requireReceive(t, callbacks, 1*time.Second)
+	require.Equal(clients.TranscodeStatusCancelled, msg.Status)
+	require.Equal("123", msg.RequestID)
+
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(len(callbacks))
+	require.False(coord.CancelJob("123"))
+}
+
 func TestAllowsOverridingStrategyOnRequest(t *testing.T) {
 	require := require.New(t)
 
@@ -346,13 +421,13 @@ func TestPipelineCollectedMetrics(t *testing.T) {
 	defer metricsServer.Close()
 
 	ffmpeg := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			setJobInfoFields(job)
 			return testHandlerResult, nil
 		},
 	}
 	external := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			setJobInfoFields(job)
 			return testHandlerResult, nil
 		},
@@ -439,7 +514,7 @@ func Test_ProbeErrors(t *testing.T) {
 		{
 			name:        "audio only",
 			assetType:   "audio",
-			expectedErr: "error copying input to storage: no video track found in input video: no 'video' tracks found",
+			expectedErr: "",
 		},
 		{
 			name:        "filesize greater than max",
@@ -485,7 +560,7 @@ func Test_InputCopiedToTransferLocation(t *testing.T) {
 	var actualTransferInput string
 	callbackHandler, callbacks := callbacksRecorder()
 	ffmpeg := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			actualTransferInput = job.SourceFile
 			return testHandlerResult, nil
 		},
@@ -537,7 +612,7 @@ func retry(attempts int, sleep time.Duration, f func() error) (err error) {
 
 func allFailingHandler(t *testing.T) Handler {
 	return &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			require.Fail(t, "Unexpected handleStartUploadJob")
 			panic("unreachable")
 		},
@@ -568,7 +643,7 @@ func callbacksRecorder() (clients.TranscodeStatusClient, <-chan clients.Transcod
 func recordingHandler(err error) (Handler, <-chan *JobInfo) {
 	jobs := make(chan *JobInfo, 10)
 	handler := &StubHandler{
-		handleStartUploadJob: func(job *JobInfo) (*HandlerOutput, error) {
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 			jobs <- job
 			if err != nil {
 				fmt.Println("WROTE TO CHANNEL " + err.Error())
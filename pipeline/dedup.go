@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/log"
+)
+
+// jobDedup remembers the request ID of the jobs submitted with a given
+// idempotency key, so that a client retrying a request after a timeout doesn't
+// start a second job writing to the same output location. Keys are held for
+// as long as the job is in flight and for config.VODDedupWindow after it
+// completed successfully. Failed and cancelled jobs release their key straight
+// away so that they can be retried.
+type jobDedup struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	requestID   string
+	completedAt time.Time // zero while the job is in flight
+}
+
+func newJobDedup() *jobDedup {
+	return &jobDedup{entries: map[string]*dedupEntry{}}
+}
+
+// claim registers the key for the given request, unless it's already held by
+// another job in which case the request ID of that job is returned.
+func (d *jobDedup) claim(key, requestID string) (string, bool) {
+	if key == "" || config.VODDedupWindow <= 0 {
+		return "", false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune()
+	if e, ok := d.entries[key]; ok && e.requestID != requestID {
+		return e.requestID, true
+	}
+	d.entries[key] = &dedupEntry{requestID: requestID}
+	return "", false
+}
+
+// finish is called once the job for the given request is done. The key is
+// kept for the dedup window if the job completed, or released otherwise.
+func (d *jobDedup) finish(key, requestID string, completed bool) {
+	if key == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[key]
+	if !ok || e.requestID != requestID {
+		return
+	}
+	if completed {
+		e.completedAt = time.Now()
+	} else {
+		delete(d.entries, key)
+	}
+}
+
+// must be called with the lock held
+func (d *jobDedup) prune() {
+	for key, e := range d.entries {
+		if !e.completedAt.IsZero() && time.Since(e.completedAt) > config.VODDedupWindow {
+			delete(d.entries, key)
+		}
+	}
+}
+
+// DeduplicateUploadJob reserves the idempotency key for a new job. If a job
+// with the same key is in flight or completed recently, its request ID is
+// returned and the new job must not be started.
+func (c *Coordinator) DeduplicateUploadJob(idempotencyKey, requestID string) (string, bool) {
+	existingRequestID, duplicate := c.dedup.claim(idempotencyKey, requestID)
+	if duplicate {
+		log.Log(requestID, "Duplicate job submission, returning the existing job", "existing_request_id", existingRequestID)
+	}
+	return existingRequestID, duplicate
+}
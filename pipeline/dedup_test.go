@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobDedup(t *testing.T) {
+	require := require.New(t)
+	d := newJobDedup()
+
+	_, duplicate := d.claim("external_id:asset", "req-1")
+	require.False(duplicate)
+	existing, duplicate := d.claim("external_id:asset", "req-2")
+	require.True(duplicate)
+	require.Equal("req-1", existing)
+
+	// keys are not shared between different assets or requests without a key
+	_, duplicate = d.claim("external_id:other-asset", "req-3")
+	require.False(duplicate)
+	_, duplicate = d.claim("", "req-4")
+	require.False(duplicate)
+	_, duplicate = d.claim("", "req-5")
+	require.False(duplicate)
+
+	// a failed job can be retried straight away
+	d.finish("external_id:other-asset", "req-3", false)
+	_, duplicate = d.claim("external_id:other-asset", "req-6")
+	require.False(duplicate)
+
+	// a completed job is remembered until the window expires
+	d.finish("external_id:asset", "req-1", true)
+	existing, duplicate = d.claim("external_id:asset", "req-7")
+	require.True(duplicate)
+	require.Equal("req-1", existing)
+
+	defer func(window time.Duration) { config.VODDedupWindow = window }(config.VODDedupWindow)
+	config.VODDedupWindow = time.Millisecond
+	time.Sleep(5 * time.Millisecond)
+	_, duplicate = d.claim("external_id:asset", "req-8")
+	require.False(duplicate)
+}
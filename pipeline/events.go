@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/livepeer/catalyst-api/clients"
+)
+
+// Number of status messages buffered for each subscriber. When a subscriber
+// falls behind, the oldest messages are dropped so that the pipeline is never
+// blocked by it.
+const jobEventsBufferSize = 64
+
+// jobEvents forwards the status messages of the jobs to the live subscribers,
+// on top of sending them to the status client. Subscriptions end with the
+// terminal status message of the job.
+type jobEvents struct {
+	clients.TranscodeStatusClient
+
+	mu          sync.Mutex
+	subscribers map[string][]chan clients.TranscodeStatusMessage
+}
+
+func newJobEvents(statusClient clients.TranscodeStatusClient) *jobEvents {
+	return &jobEvents{
+		TranscodeStatusClient: statusClient,
+		subscribers:           map[string][]chan clients.TranscodeStatusMessage{},
+	}
+}
+
+func (e *jobEvents) SendTranscodeStatus(tsm clients.TranscodeStatusMessage) error {
+	// Messages without a callback URL come from background jobs or from
+	// pipelines with a fallback, and aren't reported to the caller either
+	if tsm.URL != "" {
+		e.publish(tsm)
+	}
+	return e.TranscodeStatusClient.SendTranscodeStatus(tsm)
+}
+
+func (e *jobEvents) publish(tsm clients.TranscodeStatusMessage) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, ch := range e.subscribers[tsm.RequestID] {
+		select {
+		case ch <- tsm:
+		default:
+			// we're the only writer, so there's room after dropping the oldest message
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- tsm
+		}
+		if tsm.IsTerminal() {
+			close(ch)
+		}
+	}
+	if tsm.IsTerminal() {
+		delete(e.subscribers, tsm.RequestID)
+	}
+}
+
+func (e *jobEvents) subscribe(requestID string) (<-chan clients.TranscodeStatusMessage, func()) {
+	ch := make(chan clients.TranscodeStatusMessage, jobEventsBufferSize)
+	e.mu.Lock()
+	e.subscribers[requestID] = append(e.subscribers[requestID], ch)
+	e.mu.Unlock()
+
+	unsubscribe := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		subscribers := e.subscribers[requestID]
+		for i, sub := range subscribers {
+			if sub == ch {
+				e.subscribers[requestID] = append(subscribers[:i], subscribers[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+		if len(e.subscribers[requestID]) == 0 {
+			delete(e.subscribers, requestID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// SubscribeJobEvents returns a channel receiving all the status messages of
+// the job, which is closed after the terminal one. The returned function must
+// be called to stop the subscription early.
+func (c *Coordinator) SubscribeJobEvents(requestID string) (<-chan clients.TranscodeStatusMessage, func()) {
+	return c.events.subscribe(requestID)
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/video"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobEventsDropOldestMessagesForSlowSubscribers(t *testing.T) {
+	events := newJobEvents(clients.TranscodeStatusFunc(func(tsm clients.TranscodeStatusMessage) error { return nil }))
+	ch, unsubscribe := events.subscribe("req-1")
+	defer unsubscribe()
+	other, unsubscribeOther := events.subscribe("req-2")
+
+	for i := 0; i < jobEventsBufferSize*2; i++ {
+		require.NoError(t, events.SendTranscodeStatus(clients.NewTranscodeStatusProgress("http://localhost/callback", "req-1", clients.TranscodeStatusTranscoding, float64(i)/100)))
+	}
+	// not reported to the caller, so not published either
+	require.NoError(t, events.SendTranscodeStatus(clients.NewTranscodeStatusError("", "req-1", "fallback error", false)))
+	require.NoError(t, events.SendTranscodeStatus(clients.NewTranscodeStatusCompleted("http://localhost/callback", "req-1", video.InputVideo{}, nil)))
+
+	var received []clients.TranscodeStatusMessage
+	for tsm := range ch {
+		received = append(received, tsm)
+	}
+	require.Len(t, received, jobEventsBufferSize)
+	require.Equal(t, clients.TranscodeStatusCompleted, received[len(received)-1].Status)
+
+	unsubscribeOther()
+	_, ok := <-other
+	require.False(t, ok)
+	require.Empty(t, events.subscribers)
+}
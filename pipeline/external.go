@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/log"
 )
 
 type external struct {
@@ -17,14 +18,22 @@ func (m *external) Name() string {
 	return "external"
 }
 
-func (e *external) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
+func (e *external) HandleStartUploadJob(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 	sourceFileUrl, err := url.Parse(job.SignedSourceURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid source file URL: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	if job.GenerateCMAF {
+		log.Log(job.RequestID, "CMAF output is not supported by the external transcoder, skipping it")
+	}
+	if job.PerTitle != nil && len(job.Profiles) == 0 {
+		log.Log(job.RequestID, "Per-title ladder is not supported by the external transcoder, using the default one")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 6*time.Hour)
 	defer cancel()
+	transcodingStart := time.Now()
 	outputVideos, err := e.transcoder.Transcode(ctx, clients.TranscodeJobArgs{
 		RequestID:         job.RequestID,
 		SegmentSizeSecs:   job.targetSegmentSizeSecs,
@@ -48,6 +57,7 @@ func (e *external) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
 		return nil, fmt.Errorf("external transcoder error: %w", err)
 	}
 	job.TranscodingDone = time.Now()
+	job.RecordStage(clients.NewJobStage("transcoding", transcodingStart, job.sourceBytes, job.transcodedSegments))
 
 	return &HandlerOutput{
 		Result: &UploadJobResult{
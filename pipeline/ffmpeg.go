@@ -24,6 +24,23 @@ const LocalSourceFilePattern = "sourcevideo*"
 type ffmpeg struct {
 	// The base of where to output source segments to
 	SourceOutputUrl string
+	// Transcodes the segments instead of the Livepeer Broadcasters when set
+	Transcoder clients.BroadcasterClient
+}
+
+// software is the ffmpeg pipeline with the segments transcoded by a local
+// software ffmpeg, used when neither Livepeer nor the external transcoder are
+// available.
+type software struct {
+	ffmpeg
+}
+
+func newSoftware(sourceOutputURL string) *software {
+	return &software{ffmpeg{SourceOutputUrl: sourceOutputURL, Transcoder: clients.FfmpegTranscoder{}}}
+}
+
+func (s *software) Name() string {
+	return "software_ffmpeg"
 }
 
 func init() {
@@ -32,13 +49,16 @@ func init() {
 	if err := cleanUpLocalTmpFiles(os.TempDir(), LocalSourceFilePattern, 6*time.Hour); err != nil {
 		log.LogNoRequestID("cleanUpLocalTmpFiles error: %w", err)
 	}
+	if err := cleanUpLocalTmpDirs(os.TempDir(), transcode.SEGMENT_SPOOL_PATTERN, 6*time.Hour); err != nil {
+		log.LogNoRequestID("cleanUpLocalTmpDirs error: %w", err)
+	}
 }
 
 func (f *ffmpeg) Name() string {
 	return "catalyst_ffmpeg"
 }
 
-func (f *ffmpeg) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
+func (f *ffmpeg) HandleStartUploadJob(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 	log.Log(job.RequestID, "Handling job via FFMPEG/Livepeer pipeline")
 
 	sourceOutputBaseURL, err := url.Parse(f.SourceOutputUrl)
@@ -53,13 +73,34 @@ func (f *ffmpeg) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
 	log.AddContext(job.RequestID, "segmented_url", job.SegmentingTargetURL)
 	job.ReportProgress(clients.TranscodeStatusPreparing, 0.3)
 
+	job.subtitles = nil
+	// sidecar captions don't depend on the video, audio-only inputs get them too
+	if len(job.Captions) > 0 {
+		captions, err := loadCaptionFiles(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		job.subtitles = captions
+	}
+
 	// Segment only for non-HLS inputs
 	if job.InputFileInfo.Format != "hls" {
-		if err := copyFileToLocalTmpAndSegment(job); err != nil {
+		segmentingStart := time.Now()
+		if err := copyFileToLocalTmpAndSegment(ctx, job); err != nil {
 			return nil, err
 		}
+		job.RecordStage(clients.NewJobStage("segmenting", segmentingStart, job.InputFileInfo.SizeBytes, 0))
 	} else {
 		job.SegmentingTargetURL = job.SourceFile
+		// The per-title ladder is best effort, the default one is used when the analysis fails
+		if job.PerTitle != nil && len(job.Profiles) == 0 && !job.InputFileInfo.IsAudioOnly() {
+			profiles, err := hlsPerTitleProfiles(ctx, job)
+			if err != nil {
+				log.LogError(job.RequestID, "Failed to build per-title ladder, using the default one", err)
+			} else {
+				job.Profiles = profiles
+			}
+		}
 	}
 	job.SegmentingDone = time.Now()
 	sendSourcePlayback(job)
@@ -81,6 +122,12 @@ func (f *ffmpeg) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
 		RequestID:         job.RequestID,
 		ReportProgress:    job.ReportProgress,
 		GenerateMP4:       job.GenerateMP4,
+		GenerateCMAF:      job.GenerateCMAF,
+
+		ResumeFromCheckpoint: job.ResumeFromCheckpoint,
+		Transcoder:           f.Transcoder,
+		RecordStage:          job.RecordStage,
+		Subtitles:            job.subtitles,
 	}
 
 	inputInfo := video.InputVideo{
@@ -117,6 +164,14 @@ func (f *ffmpeg) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
 		},
 	}
 
+	if job.InputFileInfo.IsAudioOnly() {
+		// drop the video track
+		inputInfo.Tracks = inputInfo.Tracks[1:]
+	} else if audioTracks := job.InputFileInfo.AudioTracks(); len(audioTracks) > 1 {
+		// keep all the audio tracks and their language for the alternate audio renditions
+		inputInfo.Tracks = append(inputInfo.Tracks[:1], audioTracks...)
+	}
+
 	job.state = "transcoding"
 
 	sourceManifest, err := clients.DownloadRenditionManifest(transcodeRequest.RequestID, transcodeRequest.SourceManifestURL)
@@ -139,7 +194,7 @@ func (f *ffmpeg) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
 		}
 	}
 
-	outputs, transcodedSegments, err := transcode.RunTranscodeProcess(transcodeRequest, job.StreamName, inputInfo)
+	outputs, transcodedSegments, err := transcode.RunTranscodeProcess(ctx, transcodeRequest, job.StreamName, inputInfo)
 	if err != nil {
 		log.LogError(job.RequestID, "RunTranscodeProcess returned an error", err)
 		return nil, fmt.Errorf("transcoding failed: %w", err)
@@ -185,16 +240,26 @@ func sendSourcePlayback(job *JobInfo) {
 	}
 
 	sourceMaster := m3u8.NewMasterPlaylist()
-	videoTrack, err := job.InputFileInfo.GetTrack(video.TrackTypeVideo)
-	if err != nil {
-		log.LogError(job.RequestID, "unable to find a video track for source playback", err)
-		return
+	var params m3u8.VariantParams
+	if job.InputFileInfo.IsAudioOnly() {
+		audioTrack, _ := job.InputFileInfo.GetTrack(video.TrackTypeAudio)
+		params = m3u8.VariantParams{
+			Bandwidth: uint32(audioTrack.Bitrate),
+			Name:      "audio",
+		}
+	} else {
+		videoTrack, err := job.InputFileInfo.GetTrack(video.TrackTypeVideo)
+		if err != nil {
+			log.LogError(job.RequestID, "unable to find a video track for source playback", err)
+			return
+		}
+		params = m3u8.VariantParams{
+			Bandwidth:  uint32(videoTrack.Bitrate),
+			Resolution: fmt.Sprintf("%dx%d", videoTrack.Width, videoTrack.Height),
+			Name:       fmt.Sprintf("%dp", videoTrack.Height),
+		}
 	}
-	sourceMaster.Append("/"+path.Join(segmentingPath[2:]...), &m3u8.MediaPlaylist{}, m3u8.VariantParams{
-		Bandwidth:  uint32(videoTrack.Bitrate),
-		Resolution: fmt.Sprintf("%dx%d", videoTrack.Width, videoTrack.Height),
-		Name:       fmt.Sprintf("%dp", videoTrack.Height),
-	})
+	sourceMaster.Append("/"+path.Join(segmentingPath[2:]...), &m3u8.MediaPlaylist{}, params)
 	err = clients.UploadToOSURLFields(job.HlsTargetURL.String(), "index.m3u8", sourceMaster.Encode(), 10*time.Minute, &drivers.FileProperties{CacheControl: "max-age=60"})
 	if err != nil {
 		log.LogError(job.RequestID, "failed to write source playback playlist", err)
@@ -206,6 +271,7 @@ func sendSourcePlayback(job *JobInfo) {
 		Manifest: sourcePlaylist,
 	}
 	tsm := clients.NewTranscodeStatusSourcePlayback(job.CallbackURL, job.RequestID, clients.TranscodeStatusPreparingCompleted, 1, &sourceOutput)
+	tsm.Attempt = job.Attempt
 	err = job.statusClient.SendTranscodeStatus(tsm)
 	if err != nil {
 		log.LogError(job.RequestID, "failed to send status message for source playback", err)
@@ -230,7 +296,7 @@ func probeSourceSegment(requestID string, seg *m3u8.MediaSegment, sourceManifest
 	return nil
 }
 
-func copyFileToLocalTmpAndSegment(job *JobInfo) error {
+func copyFileToLocalTmpAndSegment(ctx context.Context, job *JobInfo) error {
 	// Create a temporary local file to write to
 	localSourceFile, err := os.CreateTemp(os.TempDir(), LocalSourceFilePattern)
 	if err != nil {
@@ -241,7 +307,7 @@ func copyFileToLocalTmpAndSegment(job *JobInfo) error {
 
 	// Copy the file locally because of issues with ffmpeg segmenting and remote files
 	// We can be aggressive with the timeout because we're copying from cloud storage
-	timeout, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	timeout, cancel := context.WithTimeout(ctx, 30*time.Minute)
 	defer cancel()
 	_, err = clients.CopyFile(timeout, job.SignedSourceURL, localSourceFile.Name(), "", job.RequestID)
 	if err != nil {
@@ -259,10 +325,24 @@ func copyFileToLocalTmpAndSegment(job *JobInfo) error {
 	}
 
 	destinationURL := fmt.Sprintf("%s/api/ffmpeg/%s/index.m3u8", internalAddress, job.StreamName)
-	if err := video.Segment(localSourceFile.Name(), destinationURL, job.TargetSegmentSizeSecs); err != nil {
+	if err := video.Segment(ctx, localSourceFile.Name(), destinationURL, job.TargetSegmentSizeSecs, job.InputFileInfo.IsAudioOnly()); err != nil {
 		return err
 	}
 
+	if !job.InputFileInfo.IsAudioOnly() {
+		job.subtitles = append(job.subtitles, extractEmbeddedSubtitles(ctx, job, localSourceFile.Name())...)
+	}
+
+	// The per-title ladder is best effort, the default one is used when the analysis fails
+	if job.PerTitle != nil && len(job.Profiles) == 0 && !job.InputFileInfo.IsAudioOnly() {
+		profiles, err := perTitleProfiles(ctx, job, localSourceFile.Name())
+		if err != nil {
+			log.LogError(job.RequestID, "Failed to build per-title ladder, using the default one", err)
+		} else {
+			job.Profiles = profiles
+		}
+	}
+
 	return nil
 }
 
@@ -286,6 +366,33 @@ func cleanUpLocalTmpFiles(dir string, filenamePattern string, maxAge time.Durati
 	})
 }
 
+// cleanUpLocalTmpDirs removes the directories directly under dir that match
+// the pattern and haven't been modified for maxAge, with all their content.
+func cleanUpLocalTmpDirs(dir string, dirnamePattern string, maxAge time.Duration) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if match, _ := filepath.Match(dirnamePattern, entry.Name()); !match {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) <= maxAge {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("error removing dir %s: %w", path, err)
+		}
+		log.LogNoRequestID("Cleaned up dir", "path", path, "age", info.ModTime())
+	}
+	return nil
+}
+
 func toStr(URL *url.URL) string {
 	if URL != nil {
 		return URL.String()
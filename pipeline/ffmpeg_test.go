@@ -3,6 +3,7 @@ package pipeline
 import (
 	"net/url"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -39,6 +40,26 @@ func TestItCleansUpLocalFiles(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestItCleansUpLocalDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"spool-old", "spool-new", "other-old"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name, "0"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name, "0", "0.ts"), []byte("segment"), 0600))
+	}
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "spool-old"), old, old))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "other-old"), old, old))
+
+	require.NoError(t, cleanUpLocalTmpDirs(dir, "spool-*", time.Minute))
+
+	_, err := os.Stat(filepath.Join(dir, "spool-old"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "spool-new", "0", "0.ts"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "other-old", "0", "0.ts"))
+	require.NoError(t, err)
+}
+
 type mockCallbackClient struct {
 	tsm clients.TranscodeStatusMessage
 }
@@ -15,13 +15,15 @@ import (
 // Hence there is also the restriction that only one of these functions may
 // execute concurrently. All functions run in a goroutine, so they can block as
 // much as needed and they should not leave background jobs running after
-// returning.
+// returning. The context is cancelled when the job is cancelled through the
+// API, in which case the handlers should stop as soon as possible and return
+// the context error.
 type Handler interface {
 	// Name of the handler, used for logging and metrics.
 	Name() string
 	// Handle start job request. This may start async processes like on mist an
 	// wait for triggers or do the full job synchronously on exeution.
-	HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error)
+	HandleStartUploadJob(ctx context.Context, job *JobInfo) (*HandlerOutput, error)
 }
 
 // HandlerOutput is the result provided by the pipeline handlers when no
@@ -42,14 +44,14 @@ var ContinuePipeline = &HandlerOutput{Continue: true}
 // Used for testing
 type StubHandler struct {
 	name                      string
-	handleStartUploadJob      func(job *JobInfo) (*HandlerOutput, error)
+	handleStartUploadJob      func(ctx context.Context, job *JobInfo) (*HandlerOutput, error)
 	handleRecordingEndTrigger func(job *JobInfo, p RecordingEndPayload) (*HandlerOutput, error)
 	handlePushEndTrigger      func(job *JobInfo, p PushEndPayload) (*HandlerOutput, error)
 }
 
 func NewBlockingStubHandler() (blockedHandler *StubHandler, release func()) {
 	ctx, cancel := context.WithCancel(context.Background())
-	handle := func(job *JobInfo) (*HandlerOutput, error) {
+	handle := func(_ context.Context, job *JobInfo) (*HandlerOutput, error) {
 		<-ctx.Done()
 		return nil, errors.New("unblocked but i have no idea what i'm doing")
 	}
@@ -65,11 +67,11 @@ func (s *StubHandler) Name() string {
 	return s.name
 }
 
-func (h *StubHandler) HandleStartUploadJob(job *JobInfo) (*HandlerOutput, error) {
+func (h *StubHandler) HandleStartUploadJob(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
 	if h.handleStartUploadJob == nil {
 		return nil, errors.New("not implemented")
 	}
-	return h.handleStartUploadJob(job)
+	return h.handleStartUploadJob(ctx, job)
 }
 
 func (h *StubHandler) HandleRecordingEndTrigger(job *JobInfo, p RecordingEndPayload) (*HandlerOutput, error) {
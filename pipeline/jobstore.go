@@ -0,0 +1,338 @@
+package pipeline
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/log"
+)
+
+const jobStoreFileExt = ".json"
+
+// JobStore persists the in-flight upload jobs so that they can be recovered
+// after catalyst-api is restarted.
+type JobStore interface {
+	// Save creates or replaces the record for the job with the same request ID
+	Save(rec JobRecord) error
+	Delete(requestID string) error
+	// List returns all the unfinished jobs that this node is responsible for
+	List() ([]JobRecord, error)
+}
+
+// JobRecord is what gets persisted about an upload job. The payload is the
+// original one received by the coordinator, so that the job can be restarted
+// from scratch.
+type JobRecord struct {
+	Payload         UploadJobPayload
+	Stage           clients.TranscodeStatus
+	StageTimestamps map[string]time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// storedPayload overrides the URL fields of the payload, since url.URL doesn't
+// round-trip through JSON (the user info is lost).
+type storedPayload struct {
+	UploadJobPayload
+	HlsTargetURL string
+	Mp4TargetURL string
+}
+
+type storedJobRecord struct {
+	Payload         storedPayload
+	Stage           clients.TranscodeStatus
+	StageTimestamps map[string]time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (r JobRecord) MarshalJSON() ([]byte, error) {
+	stored := storedJobRecord{
+		Payload:         storedPayload{UploadJobPayload: r.Payload},
+		Stage:           r.Stage,
+		StageTimestamps: r.StageTimestamps,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+	if r.Payload.HlsTargetURL != nil {
+		stored.Payload.HlsTargetURL = r.Payload.HlsTargetURL.String()
+	}
+	if r.Payload.Mp4TargetURL != nil {
+		stored.Payload.Mp4TargetURL = r.Payload.Mp4TargetURL.String()
+	}
+	return json.Marshal(stored)
+}
+
+func (r *JobRecord) UnmarshalJSON(b []byte) error {
+	var stored storedJobRecord
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return err
+	}
+	payload := stored.Payload.UploadJobPayload
+	if stored.Payload.HlsTargetURL != "" {
+		u, err := url.Parse(stored.Payload.HlsTargetURL)
+		if err != nil {
+			return fmt.Errorf("invalid HLS target URL: %w", err)
+		}
+		payload.HlsTargetURL = u
+	}
+	if stored.Payload.Mp4TargetURL != "" {
+		u, err := url.Parse(stored.Payload.Mp4TargetURL)
+		if err != nil {
+			return fmt.Errorf("invalid MP4 target URL: %w", err)
+		}
+		payload.Mp4TargetURL = u
+	}
+	*r = JobRecord{
+		Payload:         payload,
+		Stage:           stored.Stage,
+		StageTimestamps: stored.StageTimestamps,
+		CreatedAt:       stored.CreatedAt,
+		UpdatedAt:       stored.UpdatedAt,
+	}
+	return nil
+}
+
+// FileJobStore keeps one JSON file per job in a local directory.
+type FileJobStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileJobStore(dir string) (*FileJobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating job store directory: %w", err)
+	}
+	return &FileJobStore{dir: dir}, nil
+}
+
+func (s *FileJobStore) path(requestID string) string {
+	return filepath.Join(s.dir, url.PathEscape(requestID)+jobStoreFileExt)
+}
+
+func (s *FileJobStore) Save(rec JobRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error marshalling job record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Write to a temporary file first so that a crash never leaves a half-written record behind
+	tmp, err := os.CreateTemp(s.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating job record file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("error writing job record file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing job record file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path(rec.Payload.RequestID))
+}
+
+func (s *FileJobStore) Delete(requestID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(requestID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileJobStore) List() ([]JobRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading job store directory: %w", err)
+	}
+
+	var records []JobRecord
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), jobStoreFileExt) {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading job record %s: %w", entry.Name(), err)
+		}
+		var rec JobRecord
+		if err := json.Unmarshal(b, &rec); err != nil {
+			log.LogNoRequestID("skipping invalid job record", "file", entry.Name(), "err", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// VodJobsTableDDL creates the table used by PostgresJobStore. It is run when
+// the store is created, so the table doesn't need to be provisioned separately.
+const VodJobsTableDDL = `create table if not exists "vod_jobs"(
+                            "request_id" text primary key,
+                            "node" text not null,
+                            "record" text not null,
+                            "updated_at" bigint not null
+                            );
+                            create index if not exists "vod_jobs_node_idx" on "vod_jobs"("node")`
+
+// PostgresJobStore keeps the jobs in the "vod_jobs" table of the metrics DB.
+// Rows are tagged with the node name, so a single table can be shared by all
+// the nodes in the cluster.
+type PostgresJobStore struct {
+	db   *sql.DB
+	node string
+}
+
+func NewPostgresJobStore(db *sql.DB, node string) (*PostgresJobStore, error) {
+	if _, err := db.Exec(VodJobsTableDDL); err != nil {
+		return nil, fmt.Errorf("error creating vod_jobs table: %w", err)
+	}
+	return &PostgresJobStore{db: db, node: node}, nil
+}
+
+func (s *PostgresJobStore) Save(rec JobRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error marshalling job record: %w", err)
+	}
+	upsertStmt := `insert into "vod_jobs"(
+                            "request_id",
+                            "node",
+                            "record",
+                            "updated_at"
+                            ) values($1, $2, $3, $4)
+                            on conflict ("request_id") do update set
+                            "node" = excluded."node",
+                            "record" = excluded."record",
+                            "updated_at" = excluded."updated_at"`
+	_, err = s.db.Exec(upsertStmt, rec.Payload.RequestID, s.node, string(b), rec.UpdatedAt.Unix())
+	return err
+}
+
+func (s *PostgresJobStore) Delete(requestID string) error {
+	_, err := s.db.Exec(`delete from "vod_jobs" where "request_id" = $1`, requestID)
+	return err
+}
+
+func (s *PostgresJobStore) List() ([]JobRecord, error) {
+	rows, err := s.db.Query(`select "record" from "vod_jobs" where "node" = $1`, s.node)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []JobRecord
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		var rec JobRecord
+		if err := json.Unmarshal([]byte(b), &rec); err != nil {
+			log.LogNoRequestID("skipping invalid job record", "err", err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// persistedJob is shared by all the JobInfo objects created for the same
+// foreground request, so that the stage changes are saved to a single record.
+type persistedJob struct {
+	mu     sync.Mutex
+	store  JobStore
+	record JobRecord
+}
+
+func newPersistedJob(store JobStore, p UploadJobPayload) *persistedJob {
+	if store == nil {
+		return nil
+	}
+	now := time.Now()
+	return &persistedJob{
+		store: store,
+		record: JobRecord{
+			Payload:         p,
+			Stage:           clients.TranscodeStatusPreparing,
+			StageTimestamps: map[string]time.Time{clients.TranscodeStatusPreparing.String(): now},
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		},
+	}
+}
+
+func (pj *persistedJob) save() {
+	if pj == nil {
+		return
+	}
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+	if err := pj.store.Save(pj.record); err != nil {
+		log.LogError(pj.record.Payload.RequestID, "error saving job to the job store", err)
+	}
+}
+
+// updateStage only writes to the store when the stage actually changes, since
+// progress is reported very often.
+func (pj *persistedJob) updateStage(stage clients.TranscodeStatus) {
+	if pj == nil {
+		return
+	}
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+	if pj.record.Stage == stage {
+		return
+	}
+	now := time.Now()
+	pj.record.Stage = stage
+	pj.record.StageTimestamps[stage.String()] = now
+	pj.record.UpdatedAt = now
+	if err := pj.store.Save(pj.record); err != nil {
+		log.LogError(pj.record.Payload.RequestID, "error saving job stage to the job store", err)
+	}
+}
+
+// setAttempt records the attempt number, so that retries continue counting
+// from it if the job is resumed after a restart.
+func (pj *persistedJob) setAttempt(attempt int) {
+	if pj == nil {
+		return
+	}
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+	pj.record.Payload.Attempt = attempt
+	pj.record.UpdatedAt = time.Now()
+	if err := pj.store.Save(pj.record); err != nil {
+		log.LogError(pj.record.Payload.RequestID, "error saving job attempt to the job store", err)
+	}
+}
+
+func (pj *persistedJob) delete() {
+	if pj == nil {
+		return
+	}
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+	if err := pj.store.Delete(pj.record.Payload.RequestID); err != nil {
+		log.LogError(pj.record.Payload.RequestID, "error deleting job from the job store", err)
+	}
+}
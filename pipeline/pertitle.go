@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// perTitleProfiles builds the ABR ladder of the job from trial encodes of the
+// local source file.
+func perTitleProfiles(ctx context.Context, job *JobInfo, sourceFile string) ([]video.EncodedProfile, error) {
+	return buildPerTitleProfiles(job, func(source video.InputTrack) (video.Complexity, error) {
+		return video.MeasureComplexity(ctx, sourceFile, source, job.InputFileInfo.Duration)
+	})
+}
+
+// hlsPerTitleProfiles builds the ABR ladder of an HLS input job from trial
+// encodes of a few of its source segments.
+func hlsPerTitleProfiles(ctx context.Context, job *JobInfo) ([]video.EncodedProfile, error) {
+	return buildPerTitleProfiles(job, func(source video.InputTrack) (video.Complexity, error) {
+		manifest, manifestURL, err := clients.DownloadMediaManifest(job.RequestID, job.SourceFile)
+		if err != nil {
+			return video.Complexity{}, fmt.Errorf("failed to download source manifest: %w", err)
+		}
+		segments, err := clients.GetSourceSegmentURLs(manifestURL, manifest)
+		if err != nil {
+			return video.Complexity{}, fmt.Errorf("failed to get source segment URLs: %w", err)
+		}
+
+		dir, err := os.MkdirTemp(os.TempDir(), "complexity-segments-*")
+		if err != nil {
+			return video.Complexity{}, fmt.Errorf("failed to create temp dir for the source segments: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		var files []string
+		var durations []float64
+		for _, i := range video.ComplexitySegments(len(segments)) {
+			file := filepath.Join(dir, fmt.Sprintf("segment-%d", i))
+			if err := downloadSourceSegment(ctx, job.RequestID, segments[i], file); err != nil {
+				return video.Complexity{}, fmt.Errorf("failed to download source segment %d: %w", i, err)
+			}
+			files = append(files, file)
+			durations = append(durations, float64(segments[i].DurationMillis)/1000)
+		}
+		return video.MeasureSegmentsComplexity(ctx, files, durations, source)
+	})
+}
+
+func buildPerTitleProfiles(job *JobInfo, measure func(video.InputTrack) (video.Complexity, error)) ([]video.EncodedProfile, error) {
+	source := video.InputTrack{
+		Type:    video.TrackTypeVideo,
+		Bitrate: job.sourceBitrateVideo,
+		VideoTrack: video.VideoTrack{
+			Width:  job.sourceWidth,
+			Height: job.sourceHeight,
+			FPS:    job.sourceFPS,
+		},
+	}
+	start := time.Now()
+	complexity, err := measure(source)
+	if err != nil {
+		return nil, fmt.Errorf("complexity analysis failed: %w", err)
+	}
+	job.RecordStage(clients.NewJobStage("complexity_analysis", start, 0, 0))
+
+	profiles, err := video.PerTitleProfiles(source, complexity, *job.PerTitle)
+	if err != nil {
+		return nil, err
+	}
+	log.Log(job.RequestID, "Built per-title ladder", "complexity_bitrate", complexity.Bitrate, "complexity_height", complexity.Height, "num_profiles", len(profiles))
+	return profiles, nil
+}
+
+func downloadSourceSegment(ctx context.Context, requestID string, segment clients.SourceSegment, file string) error {
+	rc, err := clients.GetSourceSegment(ctx, requestID, segment)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, rc); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,244 @@
+package pipeline
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/metrics"
+)
+
+// jobQueue holds the upload jobs waiting for a free slot, since we can only
+// run config.MaxJobsInFlight jobs at the same time. Jobs are picked by:
+//   - priority, higher first
+//   - tenant with the fewest running jobs, so that a single user uploading a
+//     lot of files doesn't starve everyone else
+//   - arrival order
+//
+// Jobs being retried also wait in the queue until their backoff has elapsed.
+type jobQueue struct {
+	mu      sync.Mutex
+	queued  []*queuedJob
+	running map[string]string // request ID -> tenant
+}
+
+type queuedJob struct {
+	payload UploadJobPayload
+	tenant  string
+	// when the job was enqueued, or the end of the backoff for retries
+	readyAt time.Time
+	// last position reported to the caller
+	position int
+}
+
+func newJobQueue() *jobQueue {
+	return &jobQueue{running: map[string]string{}}
+}
+
+// jobTenant returns the key used to share the capacity fairly between users.
+// The access token identifies a user when present, otherwise we fall back to
+// the prefix of the external ID (the part before the first "/").
+func jobTenant(p UploadJobPayload) string {
+	if p.AccessToken != "" {
+		return "token:" + p.AccessToken
+	}
+	prefix, _, _ := strings.Cut(p.ExternalID, "/")
+	return "external_id:" + prefix
+}
+
+// IsQueueFull returns whether the coordinator can't accept any more jobs.
+// Retries waiting out their backoff don't count towards the capacity.
+func (c *Coordinator) IsQueueFull() bool {
+	c.queue.mu.Lock()
+	defer c.queue.mu.Unlock()
+	now := time.Now()
+	ready := 0
+	for _, job := range c.queue.queued {
+		if !job.readyAt.After(now) {
+			ready++
+		}
+	}
+	return ready >= config.MaxQueuedJobs
+}
+
+// enqueueJob adds a job to the queue. It won't be started before notBefore,
+// if set.
+func (c *Coordinator) enqueueJob(p UploadJobPayload, notBefore time.Time) {
+	readyAt := time.Now()
+	if notBefore.After(readyAt) {
+		readyAt = notBefore
+		time.AfterFunc(time.Until(notBefore), c.dispatchJobs)
+	}
+	c.queue.mu.Lock()
+	c.queue.queued = append(c.queue.queued, &queuedJob{
+		payload: p,
+		tenant:  jobTenant(p),
+		readyAt: readyAt,
+	})
+	c.queue.mu.Unlock()
+
+	c.dispatchJobs()
+}
+
+// jobDone frees the slot of a job after it finished, starting the next one in
+// the queue if any.
+func (c *Coordinator) jobDone(requestID string) {
+	c.queue.mu.Lock()
+	_, ok := c.queue.running[requestID]
+	delete(c.queue.running, requestID)
+	c.queue.mu.Unlock()
+
+	if ok {
+		c.dispatchJobs()
+	}
+}
+
+// removeQueuedJob removes a job that hasn't been started yet from the queue.
+func (c *Coordinator) removeQueuedJob(requestID string) (UploadJobPayload, bool) {
+	c.queue.mu.Lock()
+	defer c.queue.mu.Unlock()
+	for i, job := range c.queue.queued {
+		if job.payload.RequestID == requestID {
+			c.queue.queued = append(c.queue.queued[:i], c.queue.queued[i+1:]...)
+			metrics.Metrics.VODPipelineMetrics.QueueDepth.Set(float64(len(c.queue.queued)))
+			return job.payload, true
+		}
+	}
+	return UploadJobPayload{}, false
+}
+
+// queuedJobs returns the payloads of the jobs waiting in the queue.
+func (c *Coordinator) queuedJobs() []UploadJobPayload {
+	c.queue.mu.Lock()
+	defer c.queue.mu.Unlock()
+	payloads := make([]UploadJobPayload, 0, len(c.queue.queued))
+	for _, job := range c.queue.queued {
+		payloads = append(payloads, job.payload)
+	}
+	return payloads
+}
+
+func (c *Coordinator) dispatchJobs() {
+	c.queue.mu.Lock()
+	var toStart []*queuedJob
+	for len(c.queue.running) < config.MaxJobsInFlight {
+		i := c.queue.next()
+		if i < 0 {
+			break
+		}
+		job := c.queue.queued[i]
+		c.queue.queued = append(c.queue.queued[:i], c.queue.queued[i+1:]...)
+		c.queue.running[job.payload.RequestID] = job.tenant
+		toStart = append(toStart, job)
+	}
+	positions := c.queue.positions()
+	metrics.Metrics.VODPipelineMetrics.QueueDepth.Set(float64(len(c.queue.queued)))
+	c.queue.mu.Unlock()
+
+	for _, job := range toStart {
+		wait := time.Since(job.readyAt)
+		metrics.Metrics.VODPipelineMetrics.QueueWaitTime.
+			WithLabelValues(strconv.Itoa(job.payload.Priority)).
+			Observe(wait.Seconds())
+		log.Log(job.payload.RequestID, "Starting job from the queue", "wait", wait, "tenant_running_jobs", c.tenantRunningJobs(job.tenant))
+		job.payload.status.update(func(s *JobStatus) {
+			s.QueuePosition = 0
+		})
+		c.runUploadJob(job.payload)
+	}
+
+	for _, pos := range positions {
+		pos.job.payload.status.update(func(s *JobStatus) {
+			s.State = jobStateQueued
+			s.QueuePosition = pos.position
+		})
+		tsm := clients.NewTranscodeStatusQueued(pos.job.payload.CallbackURL, pos.job.payload.RequestID, pos.position)
+		tsm.Attempt = pos.job.payload.Attempt
+		// Ignore errors, the position will be sent again when the queue moves
+		_ = c.statusClient.SendTranscodeStatus(tsm)
+	}
+}
+
+func (c *Coordinator) tenantRunningJobs(tenant string) int {
+	c.queue.mu.Lock()
+	defer c.queue.mu.Unlock()
+	return c.queue.tenantRunningJobs(tenant)
+}
+
+// must be called with the lock held
+func (q *jobQueue) tenantRunningJobs(tenant string) int {
+	count := 0
+	for _, t := range q.running {
+		if t == tenant {
+			count++
+		}
+	}
+	return count
+}
+
+// next returns the index of the job to start next, or -1 if no job is ready to
+// start. Must be called with the lock held.
+func (q *jobQueue) next() int {
+	best, bestRunning := -1, 0
+	now := time.Now()
+	for i, job := range q.queued {
+		if job.readyAt.After(now) {
+			continue
+		}
+		if best < 0 {
+			best, bestRunning = i, q.tenantRunningJobs(job.tenant)
+			continue
+		}
+		bestJob := q.queued[best]
+		if job.payload.Priority != bestJob.payload.Priority {
+			if job.payload.Priority > bestJob.payload.Priority {
+				best, bestRunning = i, q.tenantRunningJobs(job.tenant)
+			}
+			continue
+		}
+		// same priority, prefer the tenant with less jobs running. Ties are
+		// broken by arrival order, since the queue is sorted by it already.
+		if running := q.tenantRunningJobs(job.tenant); running < bestRunning {
+			best, bestRunning = i, running
+		}
+	}
+	return best
+}
+
+type queuePosition struct {
+	job      *queuedJob
+	position int
+}
+
+// positions estimates the position of each queued job, counting the jobs with
+// higher priority or with the same priority that arrived earlier. Fair sharing
+// may still reorder jobs with the same priority. Retries waiting out their
+// backoff are left out until it elapses. Only the positions that changed since
+// the last call are returned. Must be called with the lock held.
+func (q *jobQueue) positions() []queuePosition {
+	var positions []queuePosition
+	now := time.Now()
+	for i, job := range q.queued {
+		if job.readyAt.After(now) {
+			continue
+		}
+		position := 1
+		for j, other := range q.queued {
+			if other.readyAt.After(now) {
+				continue
+			}
+			if other.payload.Priority > job.payload.Priority || (other.payload.Priority == job.payload.Priority && j < i) {
+				position++
+			}
+		}
+		if position != job.position {
+			job.position = position
+			positions = append(positions, queuePosition{job: job, position: position})
+		}
+	}
+	return positions
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueuePicksJobsByPriorityAndTenant(t *testing.T) {
+	q := newJobQueue()
+	q.running["running-1"] = "token:busy"
+	q.running["running-2"] = "token:busy"
+	for _, p := range []UploadJobPayload{
+		{RequestID: "busy-low", AccessToken: "busy"},
+		{RequestID: "idle-low", ExternalID: "idle/asset"},
+		{RequestID: "busy-high", AccessToken: "busy", Priority: 5},
+		{RequestID: "other-high", AccessToken: "other", Priority: 5},
+	} {
+		q.queued = append(q.queued, &queuedJob{payload: p, tenant: jobTenant(p)})
+	}
+
+	var order []string
+	for len(q.queued) > 0 {
+		i := q.next()
+		order = append(order, q.queued[i].payload.RequestID)
+		q.queued = append(q.queued[:i], q.queued[i+1:]...)
+	}
+	require.Equal(t, []string{"other-high", "busy-high", "idle-low", "busy-low"}, order)
+}
+
+func TestCoordinatorQueuesJobsAboveCapacity(t *testing.T) {
+	require := require.New(t)
+	defer func(inFlight int) { config.MaxJobsInFlight = inFlight }(config.MaxJobsInFlight)
+	config.MaxJobsInFlight = 1
+
+	callbackHandler, callbacks := callbacksRecorder()
+	started := make(chan string, 10)
+	release := make(chan struct{})
+	ffmpeg := &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			started <- job.RequestID
+			<-release
+			return testHandlerResult, nil
+		},
+	}
+	coord := NewStubCoordinatorOpts(StrategyCatalystFfmpegDominance, callbackHandler, ffmpeg, nil, "")
+	coord.InputCopy = &clients.StubInputCopy{}
+
+	for _, id := range []string{"first", "second", "third"} {
+		job := testJob
+		job.RequestID = id
+		coord.StartUploadJob(job)
+	}
+	require.Equal("first", requireReceive(t, started, 5*time.Second))
+
+	status, ok := coord.GetJobStatus("third")
+	require.True(ok)
+	require.Equal(jobStateQueued, status.State)
+	require.Equal(2, status.QueuePosition)
+	require.Len(coord.ListJobStatuses(), 3)
+
+	// the cancelled job is never started and the one behind it moves up
+	require.True(coord.CancelJob("second"))
+	var cancelled, movedUp bool
+	for !cancelled || !movedUp {
+		msg := requireReceive(t, callbacks, 5*time.Second)
+		if msg.RequestID == "second" && msg.Status == clients.TranscodeStatusCancelled {
+			cancelled = true
+		}
+		if msg.RequestID == "third" && msg.QueuePosition == 1 {
+			movedUp = true
+		}
+	}
+
+	close(release)
+	require.Equal("third", requireReceive(t, started, 5*time.Second))
+	require.Eventually(func() bool {
+		return len(coord.ListJobStatuses()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestQueueIgnoresJobsWaitingForTheirBackoff(t *testing.T) {
+	defer func(queued int) { config.MaxQueuedJobs = queued }(config.MaxQueuedJobs)
+	config.MaxQueuedJobs = 1
+
+	coord := NewStubCoordinator()
+	coord.queue.queued = append(coord.queue.queued,
+		&queuedJob{payload: UploadJobPayload{RequestID: "retry"}, readyAt: time.Now().Add(time.Hour)},
+		&queuedJob{payload: UploadJobPayload{RequestID: "ready"}, readyAt: time.Now()},
+	)
+
+	positions := coord.queue.positions()
+	require.Len(t, positions, 1)
+	require.Equal(t, "ready", positions[0].job.payload.RequestID)
+	require.Equal(t, 1, positions[0].position)
+	require.True(t, coord.IsQueueFull())
+
+	coord.queue.queued = coord.queue.queued[:1]
+	require.False(t, coord.IsQueueFull())
+}
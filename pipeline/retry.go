@@ -0,0 +1,48 @@
+package pipeline
+
+import (
+	"time"
+
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/errors"
+	"github.com/livepeer/catalyst-api/log"
+)
+
+// Upper bound of the delay between two attempts of the same job
+const maxRetryBackoff = 10 * time.Minute
+
+// shouldRetry returns whether a failed job should be attempted again rather
+// than sending the error callback. Errors that retrying wouldn't fix (e.g.
+// invalid input files) are flagged as unretriable by the pipelines.
+func (c *Coordinator) shouldRetry(job *JobInfo, err error) bool {
+	return job.original != nil && job.Attempt < config.VODMaxAttempts && !errors.IsUnretriable(err)
+}
+
+// retryJob puts a failed job back in the queue, to be restarted from scratch
+// once the backoff has elapsed. The outputs already written are reused.
+func (c *Coordinator) retryJob(job *JobInfo, err error) {
+	p := *job.original
+	p.original = job.original
+	p.Attempt = job.Attempt + 1
+	p.ResumeFromCheckpoint = true
+	p.persisted.setAttempt(p.Attempt)
+	p.status.update(func(s *JobStatus) {
+		s.Attempt = p.Attempt
+	})
+
+	backoff := retryBackoff(job.Attempt)
+	log.Log(p.RequestID, "Scheduling job retry", "attempt", p.Attempt, "backoff", backoff)
+	c.enqueueJob(p, time.Now().Add(backoff))
+}
+
+// retryBackoff returns the delay before the attempt following the given one.
+func retryBackoff(attempt int) time.Duration {
+	backoff := config.VODRetryBackoff
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	defer func(backoff time.Duration) { config.VODRetryBackoff = backoff }(config.VODRetryBackoff)
+	config.VODRetryBackoff = 30 * time.Second
+
+	require.Equal(t, 30*time.Second, retryBackoff(1))
+	require.Equal(t, 60*time.Second, retryBackoff(2))
+	require.Equal(t, 120*time.Second, retryBackoff(3))
+	require.Equal(t, maxRetryBackoff, retryBackoff(100))
+}
+
+func retryTestCoordinator(t *testing.T, handlerErrs ...error) (*Coordinator, <-chan clients.TranscodeStatusMessage, <-chan int) {
+	callbackHandler, callbacks := callbacksRecorder()
+	attempts := make(chan int, 10)
+	ffmpeg := &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			attempts <- job.Attempt
+			if job.Attempt <= len(handlerErrs) {
+				return nil, handlerErrs[job.Attempt-1]
+			}
+			return testHandlerResult, nil
+		},
+	}
+	coord := NewStubCoordinatorOpts(StrategyCatalystFfmpegDominance, callbackHandler, ffmpeg, nil, "")
+	coord.InputCopy = &clients.StubInputCopy{}
+	return coord, callbacks, attempts
+}
+
+func TestCoordinatorRetriesFailedJobs(t *testing.T) {
+	require := require.New(t)
+	defer func(attempts int, backoff time.Duration) {
+		config.VODMaxAttempts, config.VODRetryBackoff = attempts, backoff
+	}(config.VODMaxAttempts, config.VODRetryBackoff)
+	config.VODMaxAttempts = 3
+	config.VODRetryBackoff = 10 * time.Millisecond
+
+	coord, callbacks, attempts := retryTestCoordinator(t, fmt.Errorf("storage error"), fmt.Errorf("broadcaster error"))
+	coord.StartUploadJob(testJob)
+
+	for {
+		msg := requireReceive(t, callbacks, 5*time.Second)
+		require.NotEqual(clients.TranscodeStatusError, msg.Status)
+		if msg.Status == clients.TranscodeStatusCompleted {
+			require.Equal(3, msg.Attempt)
+			break
+		}
+	}
+	for _, attempt := range []int{1, 2, 3} {
+		require.Equal(attempt, requireReceive(t, attempts, time.Second))
+	}
+}
+
+func TestCoordinatorDoesNotRetryUnretriableErrors(t *testing.T) {
+	require := require.New(t)
+	defer func(attempts int) { config.VODMaxAttempts = attempts }(config.VODMaxAttempts)
+	config.VODMaxAttempts = 3
+
+	coord, callbacks, attempts := retryTestCoordinator(t, errors.Unretriable(fmt.Errorf("invalid input")))
+	coord.StartUploadJob(testJob)
+
+	for {
+		msg := requireReceive(t, callbacks, 5*time.Second)
+		if msg.Status == clients.TranscodeStatusError {
+			require.True(msg.Unretriable)
+			require.Equal(1, msg.Attempt)
+			break
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(1, requireReceive(t, attempts, time.Second))
+	require.Zero(len(attempts))
+}
+
+func TestCoordinatorKeepsTheSlotOfAnImmediateRetry(t *testing.T) {
+	require := require.New(t)
+	defer func(attempts int, backoff time.Duration, inFlight int) {
+		config.VODMaxAttempts, config.VODRetryBackoff, config.MaxJobsInFlight = attempts, backoff, inFlight
+	}(config.VODMaxAttempts, config.VODRetryBackoff, config.MaxJobsInFlight)
+	config.VODMaxAttempts = 2
+	config.VODRetryBackoff = 0
+	config.MaxJobsInFlight = 2
+
+	var coord *Coordinator
+	running := make(chan bool, 1)
+	release := make(chan struct{})
+	coord = NewStubCoordinatorOpts(StrategyCatalystFfmpegDominance, nil, &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			if job.Attempt == 1 {
+				return nil, fmt.Errorf("storage error")
+			}
+			// the previous attempt finishing must not free the slot of this one
+			time.Sleep(50 * time.Millisecond)
+			coord.queue.mu.Lock()
+			_, ok := coord.queue.running[job.RequestID]
+			coord.queue.mu.Unlock()
+			running <- ok
+			<-release
+			return testHandlerResult, nil
+		},
+	}, nil, "")
+	coord.InputCopy = &clients.StubInputCopy{}
+	coord.StartUploadJob(testJob)
+
+	require.True(requireReceive(t, running, 5*time.Second))
+	close(release)
+}
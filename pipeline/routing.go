@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/metrics"
+	"github.com/livepeer/catalyst-api/video"
+	"sigs.k8s.io/yaml"
+)
+
+// RoutingRules picks the strategy of a job from the probed input file, based
+// on a list of rules loaded from a YAML file. The first matching rule wins:
+//
+//	rules:
+//	  - name: large-inputs
+//	    match:
+//	      min_height: 2161
+//	    strategy: external
+//	  - name: non-h264
+//	    except:
+//	      video_codec: [h264]
+//	    strategy: fallback_software
+//
+// Routed jobs are subject to the usual Livepeer compatibility checks, like the
+// ones matching none of the rules, which keep the default strategy. Jobs
+// forcing a strategy in the request are not routed.
+type RoutingRules struct {
+	path string
+	// checkStrategy rejects the strategies that the coordinator can't run
+	checkStrategy func(Strategy) error
+
+	mu    sync.RWMutex
+	rules []RoutingRule
+}
+
+type routingRulesFile struct {
+	Rules []RoutingRule `json:"rules"`
+}
+
+// RoutingRule sends the inputs that satisfy Match but not Except to Strategy.
+type RoutingRule struct {
+	Name     string        `json:"name"`
+	Match    RoutingMatch  `json:"match"`
+	Except   *RoutingMatch `json:"except,omitempty"`
+	Strategy Strategy      `json:"strategy"`
+}
+
+// RoutingMatch holds the conditions on the input file, all of which must be
+// satisfied for the match to succeed. Unset conditions are ignored. Codecs,
+// containers and pixel formats match any of the values in the list.
+type RoutingMatch struct {
+	VideoCodec  []string `json:"video_codec,omitempty"`
+	AudioCodec  []string `json:"audio_codec,omitempty"`
+	Container   []string `json:"container,omitempty"`
+	PixelFormat []string `json:"pixel_format,omitempty"`
+	Rotated     *bool    `json:"rotated,omitempty"`
+
+	MinWidth    *int64   `json:"min_width,omitempty"`
+	MaxWidth    *int64   `json:"max_width,omitempty"`
+	MinHeight   *int64   `json:"min_height,omitempty"`
+	MaxHeight   *int64   `json:"max_height,omitempty"`
+	MinDuration *float64 `json:"min_duration_secs,omitempty"`
+	MaxDuration *float64 `json:"max_duration_secs,omitempty"`
+	MinSize     *int64   `json:"min_size_bytes,omitempty"`
+	MaxSize     *int64   `json:"max_size_bytes,omitempty"`
+	MinBitrate  *int64   `json:"min_bitrate,omitempty"`
+	MaxBitrate  *int64   `json:"max_bitrate,omitempty"`
+}
+
+// LoadRoutingRules reads the rules from the given YAML file. The strategy of
+// each rule must pass checkStrategy, if set, usually Coordinator.CheckStrategy.
+func LoadRoutingRules(path string, checkStrategy func(Strategy) error) (*RoutingRules, error) {
+	r := &RoutingRules{path: path, checkStrategy: checkStrategy}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the rules file again. The current rules are kept if the file
+// is invalid.
+func (r *RoutingRules) Reload() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("error reading routing rules file: %w", err)
+	}
+	var file routingRulesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return fmt.Errorf("error parsing routing rules file: %w", err)
+	}
+	for i, rule := range file.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("routing rule %d has no name", i)
+		}
+		if !rule.Strategy.IsValid() {
+			return fmt.Errorf("routing rule %q has an invalid strategy: %q", rule.Name, rule.Strategy)
+		}
+		if r.checkStrategy != nil {
+			if err := r.checkStrategy(rule.Strategy); err != nil {
+				return fmt.Errorf("routing rule %q: %w", rule.Name, err)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = file.Rules
+	log.LogNoRequestID("Loaded VOD routing rules", "path", r.path, "rules", len(file.Rules))
+	return nil
+}
+
+// Route returns the first rule matching the input, if any.
+func (r *RoutingRules) Route(iv video.InputVideo) (RoutingRule, bool) {
+	if r == nil {
+		return RoutingRule{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rule := range r.rules {
+		if rule.Match.matches(iv) && (rule.Except == nil || !rule.Except.matches(iv)) {
+			return rule, true
+		}
+	}
+	return RoutingRule{}, false
+}
+
+func (m RoutingMatch) matches(iv video.InputVideo) bool {
+	videoTrack, _ := iv.GetTrack(video.TrackTypeVideo)
+	audioTrack, _ := iv.GetTrack(video.TrackTypeAudio)
+
+	if len(m.VideoCodec) > 0 && !containsFold(m.VideoCodec, videoTrack.Codec) {
+		return false
+	}
+	if len(m.AudioCodec) > 0 && !containsFold(m.AudioCodec, audioTrack.Codec) {
+		return false
+	}
+	if len(m.PixelFormat) > 0 && !containsFold(m.PixelFormat, videoTrack.PixelFormat) {
+		return false
+	}
+	if len(m.Container) > 0 {
+		// ffprobe reports a list of names for some containers, e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+		found := false
+		for _, format := range strings.Split(iv.Format, ",") {
+			found = found || containsFold(m.Container, format)
+		}
+		if !found {
+			return false
+		}
+	}
+	if m.Rotated != nil && *m.Rotated != (videoTrack.Rotation != 0) {
+		return false
+	}
+	return inRange(videoTrack.Width, m.MinWidth, m.MaxWidth) &&
+		inRange(videoTrack.Height, m.MinHeight, m.MaxHeight) &&
+		inRange(iv.Duration, m.MinDuration, m.MaxDuration) &&
+		inRange(iv.SizeBytes, m.MinSize, m.MaxSize) &&
+		inRange(videoTrack.Bitrate, m.MinBitrate, m.MaxBitrate)
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func inRange[T int64 | float64](v T, min, max *T) bool {
+	return (min == nil || v >= *min) && (max == nil || v <= *max)
+}
+
+// routeJob picks the strategy of the job and whether Livepeer supports its
+// input, either from the routing rules or from the built-in compatibility checks.
+// Audio-only inputs always go to a local pipeline, which transcodes them with
+// ffmpeg since neither Livepeer nor the external transcoder handle them.
+func (c *Coordinator) routeJob(p UploadJobPayload, strategy Strategy) (bool, Strategy) {
+	if p.InputFileInfo.IsAudioOnly() {
+		if !strategies[strategy].local {
+			strategy = StrategyCatalystFfmpegDominance
+		}
+		log.Log(p.RequestID, "Routing audio-only input", "strategy", strategy)
+		metrics.Metrics.VODPipelineMetrics.RoutingDecisions.WithLabelValues("audio_only", string(strategy)).Inc()
+		return false, strategy
+	}
+	ruleName := ""
+	if rule, ok := c.Routing.Route(p.InputFileInfo); ok && !p.PipelineStrategy.IsValid() {
+		ruleName = rule.Name
+		strategy = rule.Strategy.resolve()
+		log.Log(p.RequestID, "Routing rule matched", "rule", rule.Name, "strategy", strategy)
+	}
+	livepeerSupported, checkedStrategy := checkLivepeerCompatible(p.RequestID, strategy, p.InputFileInfo)
+	metrics.Metrics.VODPipelineMetrics.RoutingDecisions.WithLabelValues(ruleName, string(checkedStrategy)).Inc()
+	return livepeerSupported, checkedStrategy
+}
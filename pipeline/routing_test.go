@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/livepeer/catalyst-api/video"
+	"github.com/stretchr/testify/require"
+)
+
+const testRoutingRules = `
+rules:
+  - name: rotated
+    match:
+      rotated: true
+    strategy: fallback_software
+  - name: large-hevc
+    match:
+      video_codec: [hevc, h265]
+      min_height: 1081
+    except:
+      container: [mp4]
+    strategy: external
+  - name: long-yuv444
+    match:
+      pixel_format: [yuv444p]
+      min_duration_secs: 3600
+    strategy: catalyst
+`
+
+func routingTestInput(codec, format, pixFmt string, height int64, duration float64, rotation int64) video.InputVideo {
+	return video.InputVideo{
+		Format:   format,
+		Duration: duration,
+		Tracks: []video.InputTrack{{
+			Type:  video.TrackTypeVideo,
+			Codec: codec,
+			VideoTrack: video.VideoTrack{
+				Height:      height,
+				PixelFormat: pixFmt,
+				Rotation:    rotation,
+			},
+		}},
+	}
+}
+
+func TestRoutingRulesMatchInputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testRoutingRules), 0644))
+	rules, err := LoadRoutingRules(path, nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		input        video.InputVideo
+		expectedRule string
+		strategy     Strategy
+	}{
+		{"rotated", routingTestInput("h264", "mov,mp4,m4a,3gp,3g2,mj2", "yuv420p", 720, 10, 90), "rotated", StrategyFallbackSoftware},
+		{"large hevc", routingTestInput("HEVC", "mpegts", "yuv420p", 2160, 10, 0), "large-hevc", StrategyExternalDominance},
+		{"large hevc mp4", routingTestInput("hevc", "mov,mp4,m4a,3gp,3g2,mj2", "yuv420p", 2160, 10, 0), "", ""},
+		{"small hevc", routingTestInput("hevc", "mpegts", "yuv420p", 1080, 10, 0), "", ""},
+		{"long yuv444", routingTestInput("h264", "mpegts", "yuv444p", 720, 7200, 0), "long-yuv444", StrategyCatalyst},
+		{"short yuv444", routingTestInput("h264", "mpegts", "yuv444p", 720, 60, 0), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := rules.Route(tt.input)
+			require.Equal(t, tt.expectedRule != "", ok)
+			require.Equal(t, tt.expectedRule, rule.Name)
+			require.Equal(t, tt.strategy, rule.Strategy)
+		})
+	}
+
+	var noRules *RoutingRules
+	_, ok := noRules.Route(routingTestInput("h264", "mpegts", "yuv420p", 720, 10, 90))
+	require.False(t, ok)
+}
+
+func TestRoutingAudioOnlyInputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: everything\n    strategy: external\n"), 0644))
+	rules, err := LoadRoutingRules(path, nil)
+	require.NoError(t, err)
+	c := &Coordinator{Routing: rules}
+	p := UploadJobPayload{
+		RequestID:     "audio-only",
+		InputFileInfo: video.InputVideo{Tracks: []video.InputTrack{{Type: video.TrackTypeAudio, Codec: "mp3"}}},
+	}
+
+	livepeerSupported, strategy := c.routeJob(p, StrategyFallbackExternal)
+	require.False(t, livepeerSupported)
+	require.Equal(t, StrategyCatalystFfmpegDominance, strategy)
+
+	_, strategy = c.routeJob(p, StrategySoftware)
+	require.Equal(t, StrategySoftware, strategy)
+}
+
+func TestRoutingRulesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testRoutingRules), 0644))
+	rules, err := LoadRoutingRules(path, nil)
+	require.NoError(t, err)
+	input := routingTestInput("h264", "mpegts", "yuv420p", 720, 10, 0)
+
+	_, ok := rules.Route(input)
+	require.False(t, ok)
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: everything\n    strategy: external\n"), 0644))
+	require.NoError(t, rules.Reload())
+	rule, ok := rules.Route(input)
+	require.True(t, ok)
+	require.Equal(t, "everything", rule.Name)
+
+	// invalid files keep the previous rules
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: bad\n    strategy: nope\n"), 0644))
+	require.Error(t, rules.Reload())
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: bad\n    unknown_field: 1\n"), 0644))
+	require.Error(t, rules.Reload())
+	rule, ok = rules.Route(input)
+	require.True(t, ok)
+	require.Equal(t, "everything", rule.Name)
+
+	_, err = LoadRoutingRules(filepath.Join(t.TempDir(), "missing.yaml"), nil)
+	require.Error(t, err)
+}
+
+func TestRoutingRulesRejectStrategiesTheCoordinatorCantRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: everything\n    strategy: fallback_external\n"), 0644))
+
+	// the stub coordinator has no external transcoder
+	c := NewStubCoordinatorOpts(StrategyCatalystFfmpegDominance, nil, nil, &external{}, "")
+	_, err := LoadRoutingRules(path, c.CheckStrategy)
+	require.ErrorContains(t, err, "external transcoder is required")
+
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: everything\n    strategy: software\n"), 0644))
+	_, err = LoadRoutingRules(path, c.CheckStrategy)
+	require.NoError(t, err)
+}
+
+func TestRoutedJobsAreCheckedForLivepeerCompatibility(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - name: hevc\n    match:\n      video_codec: [hevc]\n    strategy: fallback_external\n  - name: vp9\n    match:\n      video_codec: [vp9]\n    strategy: software\n"), 0644))
+	rules, err := LoadRoutingRules(path, nil)
+	require.NoError(t, err)
+	c := &Coordinator{Routing: rules}
+
+	livepeerSupported, strategy := c.routeJob(UploadJobPayload{InputFileInfo: routingTestInput("hevc", "mpegts", "yuv420p", 720, 10, 0)}, StrategyCatalystFfmpegDominance)
+	require.False(t, livepeerSupported)
+	require.Equal(t, StrategyExternalDominance, strategy)
+
+	livepeerSupported, strategy = c.routeJob(UploadJobPayload{InputFileInfo: routingTestInput("vp9", "mpegts", "yuv420p", 720, 10, 0)}, StrategyCatalystFfmpegDominance)
+	require.False(t, livepeerSupported)
+	require.Equal(t, StrategySoftware, strategy)
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/config"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// JobStatus is the view of an upload job returned by the job status API. It's
+// decoupled from JobInfo so that the JSON format stays stable when the
+// internals of the pipeline change.
+type JobStatus struct {
+	RequestID       string               `json:"request_id"`
+	ExternalID      string               `json:"external_id,omitempty"`
+	State           string               `json:"state"`
+	CompletionRatio float64              `json:"completion_ratio"`
+	Pipeline        string               `json:"pipeline,omitempty"`
+	Strategy        Strategy             `json:"strategy,omitempty"`
+	InFallbackMode  bool                 `json:"in_fallback_mode"`
+	SourceVideo     *video.InputVideo    `json:"source_video,omitempty"`
+	StageTimestamps map[string]time.Time `json:"stage_timestamps"`
+	LastError       string               `json:"last_error,omitempty"`
+	QueuePosition   int                  `json:"queue_position,omitempty"`
+	Attempt         int                  `json:"attempt"`
+	StartedAt       time.Time            `json:"started_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+// jobStateQueued is the state of the jobs waiting in the queue for a free slot
+const jobStateQueued = "queued"
+
+// jobStatusTracker holds the status of a job behind its own lock, since the
+// JobInfo lock is held for the whole duration of the pipeline handlers. It's
+// shared by all the JobInfo objects created for the same request, so the
+// history survives the input copy and fallback stages.
+type jobStatusTracker struct {
+	mu     sync.Mutex
+	status JobStatus
+}
+
+func newJobStatusTracker(p UploadJobPayload) *jobStatusTracker {
+	now := time.Now()
+	return &jobStatusTracker{
+		status: JobStatus{
+			RequestID:       p.RequestID,
+			ExternalID:      p.ExternalID,
+			Attempt:         p.Attempt,
+			State:           clients.TranscodeStatusPreparing.String(),
+			StageTimestamps: map[string]time.Time{},
+			StartedAt:       now,
+			UpdatedAt:       now,
+		},
+	}
+}
+
+func (t *jobStatusTracker) update(f func(s *JobStatus)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f(&t.status)
+	t.status.UpdatedAt = time.Now()
+}
+
+func (t *jobStatusTracker) progress(stage clients.TranscodeStatus, completionRatio float64) {
+	t.update(func(s *JobStatus) {
+		s.State = stage.String()
+		s.CompletionRatio = clients.OverallCompletionRatio(stage, completionRatio)
+		if _, ok := s.StageTimestamps[s.State]; !ok {
+			s.StageTimestamps[s.State] = time.Now()
+		}
+	})
+}
+
+func (t *jobStatusTracker) snapshot() JobStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := t.status
+	status.StageTimestamps = make(map[string]time.Time, len(t.status.StageTimestamps))
+	for k, v := range t.status.StageTimestamps {
+		status.StageTimestamps[k] = v
+	}
+	if t.status.SourceVideo != nil {
+		sourceVideo := *t.status.SourceVideo
+		status.SourceVideo = &sourceVideo
+	}
+	return status
+}
+
+// Status returns the current status of the job.
+func (j *JobInfo) Status() JobStatus {
+	if j.status == nil {
+		// all jobs created by the coordinator have a tracker, so this is only hit by tests
+		return newJobStatusTracker(j.UploadJobPayload).snapshot()
+	}
+	return j.status.snapshot()
+}
+
+// GetJobStatus returns the status of the in-flight or queued job with the given request ID.
+func (c *Coordinator) GetJobStatus(requestID string) (JobStatus, bool) {
+	job := c.Jobs.Get(config.SegmentingStreamName(requestID))
+	if job != nil {
+		return job.Status(), true
+	}
+	for _, p := range c.queuedJobs() {
+		if p.RequestID == requestID && p.status != nil {
+			return p.status.snapshot(), true
+		}
+	}
+	return JobStatus{}, false
+}
+
+// ListJobStatuses returns the status of all the in-flight and queued jobs, oldest first.
+func (c *Coordinator) ListJobStatuses() []JobStatus {
+	statuses := []JobStatus{}
+	for _, p := range c.queuedJobs() {
+		if p.status != nil {
+			statuses = append(statuses, p.status.snapshot())
+		}
+	}
+	for _, key := range c.Jobs.GetKeys() {
+		job := c.Jobs.Get(key)
+		if job == nil {
+			continue
+		}
+		statuses = append(statuses, job.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StartedAt.Before(statuses[j].StartedAt)
+	})
+	return statuses
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobStatusFollowsTheJob(t *testing.T) {
+	require := require.New(t)
+
+	callbackHandler, callbacks := callbacksRecorder()
+	barrier := make(chan struct{})
+	ffmpeg := &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			return nil, errors.New("ffmpeg failed")
+		},
+	}
+	external := &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			job.ReportProgress(clients.TranscodeStatusTranscoding, 0.5)
+			<-barrier
+			return testHandlerResult, nil
+		},
+	}
+	coord := NewStubCoordinatorOpts(StrategyFallbackExternal, callbackHandler, ffmpeg, external, "")
+	inputFile, _, cleanup := setupTransferDir(t, coord)
+	defer cleanup()
+
+	job := testJob
+	job.SourceFile = "file://" + inputFile.Name()
+	job.ExternalID = "asset-id"
+	coord.StartUploadJob(job)
+
+	status, ok := coord.GetJobStatus("123")
+	require.True(ok)
+	require.Equal("asset-id", status.ExternalID)
+
+	for {
+		msg := requireReceive(t, callbacks, 5*time.Second)
+		if msg.Status == clients.TranscodeStatusTranscoding {
+			break
+		}
+	}
+
+	status, ok = coord.GetJobStatus("123")
+	require.True(ok)
+	require.Equal("transcoding", status.State)
+	require.Equal(StrategyFallbackExternal, status.Strategy)
+	require.Equal("stub", status.Pipeline)
+	require.True(status.InFallbackMode)
+	require.Equal("ffmpeg failed", status.LastError)
+	require.NotNil(status.SourceVideo)
+	require.Contains(status.StageTimestamps, "preparing")
+	require.Contains(status.StageTimestamps, "transcoding")
+	require.Len(coord.ListJobStatuses(), 1)
+
+	close(barrier)
+	msg := requireReceive(t, callbacks, 5*time.Second)
+	require.Equal(clients.TranscodeStatusCompleted, msg.Status)
+
+	require.Eventually(func() bool {
+		_, ok := coord.GetJobStatus("123")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
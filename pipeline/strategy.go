@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+)
+
+// Strategy indicates how the pipelines should be coordinated. Mainly changes
+// which pipelines to execute, in what order, and which ones go in background.
+// Background pipelines are only logged and are not reported back to the client.
+type Strategy string
+
+const (
+	// Only execute the external pipeline
+	StrategyExternalDominance Strategy = "external"
+	// Only execute the FFMPEG / Livepeer pipeline
+	StrategyCatalystFfmpegDominance Strategy = "catalyst_ffmpeg"
+	// Execute the FFMPEG / Livepeer pipeline in foreground and the external transcoder in background.
+	StrategyBackgroundExternal Strategy = "background_external"
+	// Execute the external transcoder in foreground and the FFMPEG / Livepeer pipeline in background.
+	// The name dates from when the background pipeline was the Mist one, which was replaced by FFMPEG.
+	StrategyBackgroundMist Strategy = "background_mist"
+	// Execute the FFMPEG pipeline first and fallback to the external transcoding
+	// provider on errors.
+	StrategyFallbackExternal Strategy = "fallback_external"
+	// Only execute the FFMPEG pipeline, transcoding with a local software ffmpeg
+	StrategySoftware Strategy = "software"
+	// Execute the FFMPEG / Livepeer pipeline first, fallback to the external
+	// transcoding provider and then to the software pipeline on errors.
+	StrategyFallbackSoftware Strategy = "fallback_software"
+	// Legacy name of the Mist pipeline, now an alias of catalyst_ffmpeg
+	StrategyCatalyst Strategy = "catalyst"
+)
+
+type strategyDefinition struct {
+	// none of the pipelines need the external transcoder
+	local bool
+	// the strategy is kept for inputs that Livepeer can't transcode, instead
+	// of switching to the external pipeline
+	livepeerOptional bool
+	start            func(c *Coordinator, ctx context.Context, p UploadJobPayload)
+}
+
+// strategies is the registry of the supported strategies. It's the source of
+// truth for the strategies accepted by the API, including its JSON schema.
+var strategies map[Strategy]strategyDefinition
+
+// strategyAliases maps the legacy strategy names to the strategy they run now.
+var strategyAliases = map[Strategy]Strategy{
+	StrategyCatalyst: StrategyCatalystFfmpegDominance,
+}
+
+// populated in init() since the strategies call back into the coordinator,
+// which would otherwise be an initialization cycle
+func init() {
+	strategies = map[Strategy]strategyDefinition{
+		StrategyExternalDominance: {
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				c.startOneUploadJob(ctx, p, c.pipeExternal, true, false)
+			},
+		},
+		StrategyCatalystFfmpegDominance: {
+			local: true,
+			// Allow "dominance" strategies to pass through as these are used in tests and we might want to manually force them for debugging
+			livepeerOptional: true,
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				c.startOneUploadJob(ctx, p, c.pipeFfmpeg, true, false)
+			},
+		},
+		StrategyBackgroundExternal: {
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				c.startOneUploadJob(ctx, p, c.pipeFfmpeg, true, false)
+				c.startOneUploadJob(ctx, p, c.pipeExternal, false, false)
+			},
+		},
+		StrategyBackgroundMist: {
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				c.startOneUploadJob(ctx, p, c.pipeExternal, true, false)
+				c.startOneUploadJob(ctx, p, c.pipeFfmpeg, false, false)
+			},
+		},
+		StrategyFallbackExternal: {
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				c.startFallbackUploadJob(ctx, p, c.pipeFfmpeg, c.pipeExternal)
+			},
+		},
+		StrategySoftware: {
+			local:            true,
+			livepeerOptional: true,
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				c.startOneUploadJob(ctx, p, c.pipeSoftware, true, false)
+			},
+		},
+		StrategyFallbackSoftware: {
+			// the software pipeline doesn't depend on Livepeer, these jobs skip the Livepeer pipeline instead
+			livepeerOptional: true,
+			start: func(c *Coordinator, ctx context.Context, p UploadJobPayload) {
+				if p.LivepeerSupported {
+					c.startFallbackUploadJob(ctx, p, c.pipeFfmpeg, c.pipeExternal, c.pipeSoftware)
+				} else {
+					c.startFallbackUploadJob(ctx, p, c.pipeExternal, c.pipeSoftware)
+				}
+			},
+		},
+	}
+}
+
+// Strategies returns the names of all the supported strategies, including the aliases.
+func Strategies() []string {
+	var names []string
+	for s := range strategies {
+		names = append(names, string(s))
+	}
+	for s := range strategyAliases {
+		names = append(names, string(s))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s Strategy) IsValid() bool {
+	if _, ok := strategyAliases[s]; ok {
+		return true
+	}
+	_, ok := strategies[s]
+	return ok
+}
+
+// resolve returns the strategy that an alias stands for.
+func (s Strategy) resolve() Strategy {
+	if target, ok := strategyAliases[s]; ok {
+		return target
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategyAliases(t *testing.T) {
+	require.True(t, StrategyCatalyst.IsValid())
+	require.Equal(t, StrategyCatalystFfmpegDominance, StrategyCatalyst.resolve())
+	require.Equal(t, StrategyFallbackExternal, StrategyFallbackExternal.resolve())
+	require.False(t, Strategy("mist").IsValid())
+
+	for alias, target := range strategyAliases {
+		require.Contains(t, strategies, target, "alias %s", alias)
+		require.NotContains(t, strategies, alias)
+	}
+}
+
+func TestCatalystStrategyRunsTheFfmpegPipeline(t *testing.T) {
+	ffmpeg, ffmpegCalls := recordingHandler(nil)
+	coord := NewStubCoordinatorOpts(StrategyCatalyst, nil, ffmpeg, allFailingHandler(t), "")
+	inputFile, _, cleanup := setupTransferDir(t, coord)
+	defer cleanup()
+
+	job := testJob
+	job.SourceFile = "file://" + inputFile.Name()
+	coord.StartUploadJob(job)
+	ffmpegJob := requireReceive(t, ffmpegCalls, 5*time.Second)
+	require.Equal(t, "123", ffmpegJob.RequestID)
+	require.Equal(t, StrategyCatalystFfmpegDominance, ffmpegJob.Status().Strategy)
+}
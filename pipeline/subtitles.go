@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// maxCaptionFileSize protects against sidecar URLs pointing to something else
+// than a caption file
+const maxCaptionFileSize = 10 * 1024 * 1024
+
+// loadCaptionFiles downloads and parses the sidecar caption files of the
+// request. They were explicitly asked for, so any failure fails the job.
+func loadCaptionFiles(ctx context.Context, job *JobInfo) ([]video.Subtitles, error) {
+	var subtitles []video.Subtitles
+	for i, captions := range job.Captions {
+		rc, err := clients.GetFile(ctx, job.RequestID, captions.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download caption file %d: %w", i, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(rc, maxCaptionFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read caption file %d: %w", i, err)
+		}
+		if len(data) > maxCaptionFileSize {
+			return nil, fmt.Errorf("caption file %d is larger than %d bytes", i, maxCaptionFileSize)
+		}
+		cues, err := video.ParseSubtitles(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse caption file %d: %w", i, err)
+		}
+		subtitles = append(subtitles, video.Subtitles{
+			Name:     subtitlesName("captions", i, captions.Language),
+			Language: captions.Language,
+			Label:    captions.Label,
+			Cues:     cues,
+		})
+	}
+	return subtitles, nil
+}
+
+// extractEmbeddedSubtitles converts the text subtitle tracks and the CEA-608
+// captions of the local source file to WebVTT. Embedded subtitles are best
+// effort: failures are logged and the track skipped.
+func extractEmbeddedSubtitles(ctx context.Context, job *JobInfo, sourceFile string) []video.Subtitles {
+	dir, err := os.MkdirTemp(os.TempDir(), "subtitles-*")
+	if err != nil {
+		log.LogError(job.RequestID, "Failed to create temp dir for subtitles", err)
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	var subtitles []video.Subtitles
+	extract := func(name string, track video.InputTrack, run func(outputFile string) error) {
+		outputFile := filepath.Join(dir, name+".vtt")
+		if err := run(outputFile); err != nil {
+			log.LogError(job.RequestID, "Failed to extract embedded subtitles", err, "track", name)
+			return
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			log.LogError(job.RequestID, "Failed to read embedded subtitles", err, "track", name)
+			return
+		}
+		cues, err := video.ParseSubtitles(data)
+		if err != nil || len(cues) == 0 {
+			log.Log(job.RequestID, "Skipping empty or invalid embedded subtitles", "track", name, "err", err)
+			return
+		}
+		subtitles = append(subtitles, video.Subtitles{Name: name, Language: track.Language, Label: track.Title, Cues: cues})
+	}
+
+	for i, track := range job.InputFileInfo.SubtitleTracks() {
+		if !video.IsTextSubtitleCodec(track.Codec) {
+			log.Log(job.RequestID, "Skipping embedded subtitles that aren't text", "codec", track.Codec)
+			continue
+		}
+		i := i
+		extract(subtitlesName("subtitles", i, track.Language), track, func(outputFile string) error {
+			return video.ExtractSubtitles(ctx, sourceFile, outputFile, i)
+		})
+	}
+
+	hasCaptions, err := video.HasClosedCaptions(ctx, sourceFile)
+	if err != nil {
+		log.LogError(job.RequestID, "Failed to check for closed captions", err)
+	} else if hasCaptions {
+		extract("cc1", video.InputTrack{Title: "CC1"}, func(outputFile string) error {
+			return video.ExtractClosedCaptions(ctx, sourceFile, outputFile)
+		})
+	}
+	return subtitles
+}
+
+// subtitlesName returns the name of a subtitles rendition, which is also its
+// directory in the output. The language comes from the request or from the
+// input file, so it is sanitized.
+func subtitlesName(prefix string, index int, language string) string {
+	name := fmt.Sprintf("%s-%d", prefix, index)
+	if language := video.SanitizeRenditionName(language); language != "" {
+		name += "-" + language
+	}
+	return name
+}
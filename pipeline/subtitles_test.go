@@ -0,0 +1,14 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubtitlesName(t *testing.T) {
+	require.Equal(t, "captions-0", subtitlesName("captions", 0, ""))
+	require.Equal(t, "captions-1-pt-BR", subtitlesName("captions", 1, "pt-BR"))
+	// languages can't escape the output directory
+	require.Equal(t, "subtitles-2-en", subtitlesName("subtitles", 2, "../../e/n"))
+}
@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+const thumbnailUploadTimeout = 5 * time.Minute
+
+// generateThumbnails builds the seek-preview sprite sheets, the WebVTT track
+// pointing to them and the poster image of the job, uploads them next to the
+// HLS output and adds them to the first output of the result. Thumbnails are
+// best effort: failures are logged and the job still succeeds without them.
+func generateThumbnails(job *JobInfo, result *UploadJobResult) {
+	if len(result.Outputs) == 0 || job.InputFileInfo.IsAudioOnly() {
+		return
+	}
+	start := time.Now()
+	targetURL := job.HlsTargetURL
+	if targetURL == nil {
+		targetURL = job.Mp4TargetURL
+	}
+	if targetURL == nil {
+		log.LogError(job.RequestID, "Failed to generate thumbnails", fmt.Errorf("no target to upload the thumbnails to"))
+		return
+	}
+	files, bytesWritten, frames, err := uploadThumbnails(job, thumbnailsURL(targetURL))
+	if err != nil {
+		log.LogError(job.RequestID, "Failed to generate thumbnails", err)
+		return
+	}
+	playbackBaseURL, err := thumbnailsPlaybackBaseURL(job, targetURL)
+	if err != nil {
+		log.LogError(job.RequestID, "Failed to publish thumbnails", err)
+		return
+	}
+	for i, f := range files {
+		files[i].Location = thumbnailLocation(f.Location, targetURL, playbackBaseURL)
+	}
+	result.Outputs[0].Thumbnails = files
+	job.RecordStage(clients.NewJobStage("thumbnails", start, bytesWritten, frames))
+}
+
+// thumbnailsURL returns where the thumbnails files are uploaded, the
+// "thumbnails" directory inside the target directory of the job.
+func thumbnailsURL(targetURL *url.URL) *url.URL {
+	return targetURL.JoinPath("thumbnails")
+}
+
+// uploadThumbnails uploads the thumbnails files to thumbnailsURL and returns
+// them with their upload location.
+func uploadThumbnails(job *JobInfo, thumbnailsURL *url.URL) ([]video.OutputVideoFile, int64, int, error) {
+	input := job.SignedSourceURL
+	if input == "" {
+		input = job.SourceFile
+	}
+	opts := job.Thumbnails.WithDefaults()
+
+	dir, err := os.MkdirTemp(os.TempDir(), "thumbnails-*")
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	framePaths, err := video.ExtractThumbnailFrames(job.ctx, input, dir, opts)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if len(framePaths) == 0 {
+		return nil, 0, 0, fmt.Errorf("no thumbnail frames extracted")
+	}
+
+	var files []video.OutputVideoFile
+	var bytesWritten int64
+	upload := func(name, fileType string, data []byte) error {
+		if err := clients.UploadToOSURL(thumbnailsURL.String(), name, bytes.NewReader(data), thumbnailUploadTimeout); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", name, err)
+		}
+		files = append(files, video.OutputVideoFile{Type: fileType, Location: thumbnailsURL.JoinPath(name).String(), SizeBytes: int64(len(data))})
+		bytesWritten += int64(len(data))
+		return nil
+	}
+
+	// Only the frames of one sheet are decoded at a time, since long inputs
+	// can have thousands of them
+	var sheetNames []string
+	var tileWidth, tileHeight int
+	for first := 0; first < len(framePaths); first += video.ThumbnailsPerSpriteSheet {
+		last := first + video.ThumbnailsPerSpriteSheet
+		if last > len(framePaths) {
+			last = len(framePaths)
+		}
+		sheet, width, height, err := buildSpriteSheet(framePaths[first:last])
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if first == 0 {
+			tileWidth, tileHeight = width, height
+		}
+		name := fmt.Sprintf("sprite-%d.jpg", len(sheetNames))
+		if err := upload(name, "thumbnail_sprite", sheet); err != nil {
+			return nil, 0, 0, err
+		}
+		sheetNames = append(sheetNames, name)
+	}
+	vtt := video.ThumbnailsVTT(len(framePaths), opts.IntervalSecs, job.InputFileInfo.Duration, tileWidth, tileHeight, sheetNames)
+	if err := upload("thumbnails.vtt", "thumbnails_vtt", []byte(vtt)); err != nil {
+		return nil, 0, 0, err
+	}
+
+	// the very first frame is often black, so pick one a bit later in the video
+	posterPath := filepath.Join(dir, "poster.jpg")
+	if err := video.ExtractPoster(job.ctx, input, posterPath, job.InputFileInfo.Duration/10); err != nil {
+		return nil, 0, 0, err
+	}
+	poster, err := os.ReadFile(posterPath)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to read poster: %w", err)
+	}
+	if err := upload("poster.jpg", "poster", poster); err != nil {
+		return nil, 0, 0, err
+	}
+	return files, bytesWritten, len(framePaths), nil
+}
+
+// buildSpriteSheet decodes the frames of a single sprite sheet and returns it
+// encoded as a JPEG, along with the size of its tiles.
+func buildSpriteSheet(framePaths []string) ([]byte, int, int, error) {
+	frames := make([]image.Image, 0, len(framePaths))
+	for _, p := range framePaths {
+		frame, err := decodeJPEG(p)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		frames = append(frames, frame)
+	}
+	sheets, tileWidth, tileHeight := video.BuildSpriteSheets(frames)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sheets[0], &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode sprite sheet: %w", err)
+	}
+	return buf.Bytes(), tileWidth, tileHeight, nil
+}
+
+func decodeJPEG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open thumbnail frame: %w", err)
+	}
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode thumbnail frame %s: %w", filepath.Base(path), err)
+	}
+	return img, nil
+}
+
+// thumbnailsPlaybackBaseURL returns the playback URL of the target directory
+// of the thumbnails, like the pipelines do for their outputs.
+func thumbnailsPlaybackBaseURL(job *JobInfo, targetURL *url.URL) (string, error) {
+	if targetURL == job.HlsTargetURL {
+		playbackBaseURL, _, err := clients.Publish(targetURL.String(), "")
+		return playbackBaseURL, err
+	}
+	_, playbackBaseURL, err := clients.Publish("", targetURL.String())
+	return playbackBaseURL, err
+}
+
+// thumbnailLocation returns the playback location of an uploaded thumbnails file.
+func thumbnailLocation(uploadLocation string, targetURL *url.URL, playbackBaseURL string) string {
+	return strings.Replace(uploadLocation, targetURL.String(), playbackBaseURL, 1)
+}
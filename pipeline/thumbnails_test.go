@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailsAreAdvertisedWhereTheyAreUploaded(t *testing.T) {
+	parent := t.TempDir()
+	var locations []string
+	for _, asset := range []string{"asset-1", "asset-2"} {
+		targetURL, err := url.Parse("file://" + filepath.Join(parent, asset))
+		require.NoError(t, err)
+		job := &JobInfo{UploadJobPayload: UploadJobPayload{HlsTargetURL: targetURL}}
+
+		uploadURL := thumbnailsURL(targetURL)
+		require.NoError(t, clients.UploadToOSURL(uploadURL.String(), "poster.jpg", strings.NewReader(asset), thumbnailUploadTimeout))
+
+		playbackBaseURL, err := thumbnailsPlaybackBaseURL(job, targetURL)
+		require.NoError(t, err)
+		location := thumbnailLocation(uploadURL.JoinPath("poster.jpg").String(), targetURL, playbackBaseURL)
+		u, err := url.Parse(location)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(parent, asset, "thumbnails", "poster.jpg"), u.Path)
+
+		data, err := os.ReadFile(u.Path)
+		require.NoError(t, err)
+		require.Equal(t, asset, string(data))
+		locations = append(locations, location)
+	}
+	// the assets sharing a parent directory don't overwrite each other's thumbnails
+	require.NotEqual(t, locations[0], locations[1])
+}
+
+func TestBuildSpriteSheet(t *testing.T) {
+	dir := t.TempDir()
+	var framePaths []string
+	for i := 0; i < 7; i++ {
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 8)), nil))
+		p := filepath.Join(dir, fmt.Sprintf("frame-%05d.jpg", i))
+		require.NoError(t, os.WriteFile(p, buf.Bytes(), 0644))
+		framePaths = append(framePaths, p)
+	}
+
+	data, tileWidth, tileHeight, err := buildSpriteSheet(framePaths)
+	require.NoError(t, err)
+	require.Equal(t, 16, tileWidth)
+	require.Equal(t, 8, tileHeight)
+	sheet, err := jpeg.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	// 7 frames take two rows of 5 columns
+	require.Equal(t, image.Rect(0, 0, 80, 16), sheet.Bounds())
+}
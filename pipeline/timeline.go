@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/metrics"
+)
+
+// jobTimeline collects the stages of a job, to be sent with its terminal
+// status message. Like jobStatusTracker, it's shared by all the JobInfo
+// objects created for the same request, including the retries.
+type jobTimeline struct {
+	mu     sync.Mutex
+	stages []clients.JobStage
+}
+
+func newJobTimeline() *jobTimeline {
+	return &jobTimeline{}
+}
+
+// record adds a stage to the timeline. A stage run again replaces the
+// previous run, counting it as a retry.
+func (t *jobTimeline) record(stage clients.JobStage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, s := range t.stages {
+		if s.Name == stage.Name {
+			stage.Retries = s.Retries + 1
+			t.stages[i] = stage
+			return
+		}
+	}
+	t.stages = append(t.stages, stage)
+}
+
+func (t *jobTimeline) snapshot() []clients.JobStage {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]clients.JobStage{}, t.stages...)
+}
+
+// RecordStage adds a stage that just finished to the timeline of the job.
+func (j *JobInfo) RecordStage(stage clients.JobStage) {
+	metrics.Metrics.VODPipelineMetrics.StageDuration.
+		WithLabelValues(stage.Name, j.pipeline).
+		Observe(stage.DurationSecs)
+	j.timeline.record(stage)
+}
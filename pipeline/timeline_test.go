@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobTimelineCountsRetries(t *testing.T) {
+	timeline := newJobTimeline()
+	start := time.Now().Add(-time.Second)
+	timeline.record(clients.NewJobStage("input_copy", start, 100, 0))
+	timeline.record(clients.NewJobStage("transcoding", start, 1000, 5))
+	timeline.record(clients.NewJobStage("transcoding", start, 2000, 5))
+
+	stages := timeline.snapshot()
+	require.Len(t, stages, 2)
+	require.Equal(t, "input_copy", stages[0].Name)
+	require.Equal(t, 0, stages[0].Retries)
+	require.Equal(t, "transcoding", stages[1].Name)
+	require.Equal(t, int64(2000), stages[1].Bytes)
+	require.Equal(t, 1, stages[1].Retries)
+	require.GreaterOrEqual(t, stages[1].DurationSecs, 1.0)
+
+	var noTimeline *jobTimeline
+	noTimeline.record(clients.NewJobStage("input_copy", start, 100, 0))
+	require.Empty(t, noTimeline.snapshot())
+}
+
+func TestCoordinatorSendsTimelineInCallback(t *testing.T) {
+	require := require.New(t)
+
+	callbackHandler, callbacks := callbacksRecorder()
+	ffmpeg := &StubHandler{
+		handleStartUploadJob: func(ctx context.Context, job *JobInfo) (*HandlerOutput, error) {
+			job.RecordStage(clients.NewJobStage("transcoding", time.Now(), 1000, 3))
+			return testHandlerResult, nil
+		},
+	}
+	coord := NewStubCoordinatorOpts(StrategyCatalystFfmpegDominance, callbackHandler, ffmpeg, nil, "")
+	coord.InputCopy = &clients.StubInputCopy{}
+	coord.StartUploadJob(testJob)
+
+	for {
+		msg := requireReceive(t, callbacks, 5*time.Second)
+		if msg.Status == clients.TranscodeStatusCompleted {
+			require.Len(msg.Timeline, 2)
+			require.Equal("input_copy", msg.Timeline[0].Name)
+			require.Equal("transcoding", msg.Timeline[1].Name)
+			require.Equal(3, msg.Timeline[1].Segments)
+			break
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// audioRenditions transcodes the audio tracks of multi-audio inputs into the
+// alternate audio renditions of the output, with a local ffmpeg since the
+// Broadcasters only keep the first audio track.
+type audioRenditions struct {
+	renditions []video.AudioRendition
+	stats      *renditionsStats
+}
+
+func newAudioRenditions(renditions []video.AudioRendition) *audioRenditions {
+	a := &audioRenditions{renditions: renditions, stats: newRenditionsStats(nil)}
+	for _, r := range renditions {
+		a.stats.stats = append(a.stats.stats, &video.RenditionStats{
+			Name:     r.Name,
+			Codecs:   video.AACCodecs,
+			Language: r.Language,
+			Title:    r.Title,
+		})
+	}
+	return a
+}
+
+// transcodeSegment writes the segment of each audio rendition to the target.
+// Segments are always transcoded again when resuming from a checkpoint, since
+// they are much cheaper than the video ones.
+func (a *audioRenditions) transcodeSegment(ctx context.Context, segment segmentInfo, requestID string, targetOSURL *url.URL) error {
+	if len(a.renditions) == 0 {
+		return nil
+	}
+	dir, err := os.MkdirTemp(os.TempDir(), "audio-renditions-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for audio renditions: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputFile := filepath.Join(dir, fmt.Sprintf("source-%d.ts", segment.Index))
+	err = backoff.Retry(func() error {
+		rc, err := clients.GetSourceSegment(ctx, requestID, segment.Input)
+		if err != nil {
+			return fmt.Errorf("failed to download source segment %q: %s", segment.Input, err)
+		}
+		defer rc.Close()
+		f, err := os.Create(inputFile)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		defer f.Close()
+		_, err = f.ReadFrom(rc)
+		return err
+	}, backoff.WithContext(clients.DownloadRetryBackoff(), ctx))
+	if err != nil {
+		return err
+	}
+
+	var outputFiles []string
+	for _, r := range a.renditions {
+		outputFiles = append(outputFiles, filepath.Join(dir, fmt.Sprintf("%s-%d.ts", r.Name, segment.Index)))
+	}
+	if err := video.TranscodeAudioRenditions(ctx, inputFile, outputFiles, a.renditions); err != nil {
+		return err
+	}
+
+	for i, r := range a.renditions {
+		data, err := os.ReadFile(outputFiles[i])
+		if err != nil {
+			return fmt.Errorf("failed to read audio rendition %q: %w", r.Name, err)
+		}
+		err = backoff.Retry(func() error {
+			return clients.UploadToOSURL(targetOSURL.JoinPath(r.Name).String(), fmt.Sprintf("%d.ts", segment.Index), bytes.NewReader(data), UPLOAD_TIMEOUT)
+		}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
+		if err != nil {
+			return fmt.Errorf("failed to upload audio rendition segment: %s", err)
+		}
+		a.stats.addSegment(i, int64(len(data)), segment.Input.DurationMillis, nil)
+	}
+	return nil
+}
+
+// outputs lists the audio renditions in the completion callback.
+func (a *audioRenditions) outputs(targetOSURL, playbackBaseURL string) []video.OutputAudioTrack {
+	var tracks []video.OutputAudioTrack
+	for _, stats := range a.stats.stats {
+		tracks = append(tracks, video.OutputAudioTrack{
+			Name:      stats.Name,
+			Language:  stats.Language,
+			Title:     stats.Title,
+			Location:  strings.ReplaceAll(stats.ManifestLocation, targetOSURL, playbackBaseURL),
+			SizeBytes: stats.Bytes,
+			Bitrate:   int64(stats.BitsPerSecond),
+		})
+	}
+	return tracks
+}
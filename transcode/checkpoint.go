@@ -0,0 +1,205 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/log"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// CHECKPOINT_PROFILE_FILENAME is written in every rendition directory before
+// its segments, with the profile they are transcoded with. Segments are only
+// reused when the profile still matches and they were written after it.
+const CHECKPOINT_PROFILE_FILENAME = "profile.json"
+
+// segmentCheckpoint holds the size of the rendition segments that were already
+// written to the target by a previous attempt of the same job, keyed by
+// rendition name and then segment index.
+type segmentCheckpoint map[string]map[int]int64
+
+// loadSegmentCheckpoint lists the rendition segments present at the target
+// that were transcoded with the same profile. Renditions without a matching
+// profile file are left out of the checkpoint. Errors are only logged, since
+// the worst case is transcoding the segments again.
+func loadSegmentCheckpoint(ctx context.Context, requestID string, targetOSURL *url.URL, profiles []video.EncodedProfile) segmentCheckpoint {
+	checkpoint := segmentCheckpoint{}
+	for _, profile := range profiles {
+		renditionURL := targetOSURL.JoinPath(profile.Name)
+		segments, profileWritten, err := listRenditionSegments(ctx, renditionURL.String())
+		if err != nil {
+			log.Log(requestID, "Failed to list existing rendition segments, transcoding all of them", "rendition", profile.Name, "err", err)
+			return segmentCheckpoint{}
+		}
+		if profileWritten == nil {
+			log.Log(requestID, "No checkpoint profile for rendition, transcoding all of its segments", "rendition", profile.Name)
+			continue
+		}
+		matches, err := checkpointProfileMatches(ctx, requestID, renditionURL.JoinPath(CHECKPOINT_PROFILE_FILENAME).String(), profile)
+		if err != nil {
+			log.Log(requestID, "Failed to read checkpoint profile, transcoding all of the rendition segments", "rendition", profile.Name, "err", err)
+			continue
+		}
+		if !matches {
+			log.Log(requestID, "Rendition profile changed since the previous attempt, transcoding all of its segments", "rendition", profile.Name)
+			continue
+		}
+
+		sizes := map[int]int64{}
+		for index, segment := range segments {
+			// older segments are left over by an attempt with another profile
+			if !segment.modified.Before(*profileWritten) {
+				sizes[index] = segment.size
+			}
+		}
+		checkpoint[profile.Name] = sizes
+	}
+	return checkpoint
+}
+
+type renditionSegment struct {
+	size     int64
+	modified time.Time
+}
+
+// listRenditionSegments returns the segments of a rendition by index, and when
+// its profile file was written, nil if it doesn't exist.
+func listRenditionSegments(ctx context.Context, renditionURL string) (map[int]renditionSegment, *time.Time, error) {
+	segments := map[int]renditionSegment{}
+	var profileWritten *time.Time
+	page, err := clients.ListOSURL(ctx, renditionURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		for _, f := range page.Files() {
+			if path.Base(f.Name) == CHECKPOINT_PROFILE_FILENAME {
+				modified := f.LastModified
+				profileWritten = &modified
+				continue
+			}
+			index, err := strconv.Atoi(strings.TrimSuffix(path.Base(f.Name), ".ts"))
+			if err != nil || !strings.HasSuffix(f.Name, ".ts") || f.Size == nil || *f.Size == 0 {
+				continue
+			}
+			segments[index] = renditionSegment{size: *f.Size, modified: f.LastModified}
+		}
+		if !page.HasNextPage() {
+			return segments, profileWritten, nil
+		}
+		page, err = page.NextPage()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error fetching next page: %w", err)
+		}
+	}
+}
+
+func checkpointProfileMatches(ctx context.Context, requestID, profileURL string, profile video.EncodedProfile) (bool, error) {
+	rc, err := clients.GetFile(ctx, requestID, profileURL, nil)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	var written video.EncodedProfile
+	if err := json.NewDecoder(rc).Decode(&written); err != nil {
+		return false, fmt.Errorf("failed to parse checkpoint profile: %w", err)
+	}
+	return written == profile, nil
+}
+
+// writeCheckpointProfiles writes the profile file of the renditions that are
+// not resumed from the checkpoint, before any of their segments. The ones that
+// are resumed keep theirs, since rewriting it would invalidate their segments.
+func writeCheckpointProfiles(ctx context.Context, targetOSURL *url.URL, profiles []video.EncodedProfile, checkpoint segmentCheckpoint) error {
+	for _, profile := range profiles {
+		if _, ok := checkpoint[profile.Name]; ok {
+			continue
+		}
+		data, err := json.Marshal(profile)
+		if err != nil {
+			return err
+		}
+		renditionURL := targetOSURL.JoinPath(profile.Name).String()
+		err = backoff.Retry(func() error {
+			return clients.UploadToOSURL(renditionURL, CHECKPOINT_PROFILE_FILENAME, bytes.NewReader(data), UPLOAD_TIMEOUT)
+		}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
+		if err != nil {
+			return fmt.Errorf("failed to upload checkpoint profile of rendition %s: %w", profile.Name, err)
+		}
+	}
+	return nil
+}
+
+// isComplete returns whether the segment was written for all the renditions.
+func (c segmentCheckpoint) isComplete(index int, profiles []video.EncodedProfile) bool {
+	if len(profiles) == 0 {
+		return false
+	}
+	for _, profile := range profiles {
+		if _, ok := c[profile.Name][index]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// len returns the number of segments complete for all the renditions.
+func (c segmentCheckpoint) len(profiles []video.EncodedProfile) int {
+	if len(profiles) == 0 {
+		return 0
+	}
+	count := 0
+	for index := range c[profiles[0].Name] {
+		if c.isComplete(index, profiles) {
+			count++
+		}
+	}
+	return count
+}
+
+// reuseSegment accounts for a segment written by a previous attempt in the
+// rendition stats, without transcoding it again. The segment is only downloaded
+// if its data is needed to build the MP4 outputs.
+func reuseSegment(
+	ctx context.Context,
+	segment segmentInfo,
+	checkpoint segmentCheckpoint,
+	transcodeRequest TranscodeSegmentRequest,
+	transcodeProfiles []video.EncodedProfile,
+	targetOSURL *url.URL,
+	transcodedStats *renditionsStats,
+	renditionList *video.TRenditionList,
+) error {
+	for i, profile := range transcodeProfiles {
+		var info *video.SegmentInfo
+		if transcodeRequest.keepSegments() {
+			segmentURL := targetOSURL.JoinPath(profile.Name, fmt.Sprintf("%d.ts", segment.Index)).String()
+			rc, err := clients.GetFile(ctx, transcodeRequest.RequestID, segmentURL, nil)
+			if err != nil {
+				return fmt.Errorf("failed to download existing rendition segment %q: %w", segmentURL, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read existing rendition segment %q: %w", segmentURL, err)
+			}
+			if err := renditionList.GetSegmentList(profile.Name).AddSegmentData(segment.Index, data); err != nil {
+				return err
+			}
+			info = parseSegmentInfo(data)
+		}
+
+		transcodedStats.addSegment(i, checkpoint[profile.Name][segment.Index], segment.Input.DurationMillis, info)
+	}
+	return nil
+}
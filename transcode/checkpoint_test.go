@@ -0,0 +1,94 @@
+package transcode
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/video"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentCheckpoint(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	target, err := url.Parse(dir)
+	require.NoError(err)
+	profiles := []video.EncodedProfile{{Name: "360p0"}, {Name: "720p0"}}
+	require.NoError(writeCheckpointProfiles(context.Background(), target, profiles, segmentCheckpoint{}))
+	writeSegment := func(rendition, name string, size int) {
+		require.NoError(os.MkdirAll(filepath.Join(dir, rendition), 0700))
+		require.NoError(os.WriteFile(filepath.Join(dir, rendition, name), make([]byte, size), 0600))
+	}
+	writeSegment("360p0", "0.ts", 100)
+	writeSegment("720p0", "0.ts", 300)
+	// only written for one of the renditions
+	writeSegment("360p0", "1.ts", 100)
+	// empty segments are left over by failed uploads
+	writeSegment("360p0", "2.ts", 0)
+	writeSegment("720p0", "2.ts", 300)
+	writeSegment("720p0", "index.m3u8", 10)
+
+	checkpoint := loadSegmentCheckpoint(context.Background(), "req", target, profiles)
+	require.True(checkpoint.isComplete(0, profiles))
+	require.False(checkpoint.isComplete(1, profiles))
+	require.False(checkpoint.isComplete(2, profiles))
+	require.False(checkpoint.isComplete(3, profiles))
+	require.Equal(1, checkpoint.len(profiles))
+
+	stats := newRenditionsStats(statsFromProfiles(profiles))
+	segment := segmentInfo{Input: clients.SourceSegment{DurationMillis: 2000}, Index: 0}
+	require.NoError(reuseSegment(context.Background(), segment, checkpoint, TranscodeSegmentRequest{}, profiles, target, stats, nil))
+	require.Equal(int64(100), stats.stats[0].Bytes)
+	require.Equal(int64(300), stats.stats[1].Bytes)
+	require.Equal(float64(2000), stats.stats[1].DurationMs)
+	require.Equal(uint32(1200), stats.stats[1].BitsPerSecond)
+}
+
+func TestSegmentCheckpointIgnoresSegmentsOfOtherProfiles(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	target, err := url.Parse(dir)
+	require.NoError(err)
+	oldProfiles := []video.EncodedProfile{{Name: "360p0", Bitrate: 1_000_000}, {Name: "720p0", Bitrate: 3_000_000}}
+	require.NoError(writeCheckpointProfiles(context.Background(), target, oldProfiles, segmentCheckpoint{}))
+	for _, rendition := range []string{"360p0", "720p0"} {
+		for _, name := range []string{"0.ts", "1.ts"} {
+			require.NoError(os.WriteFile(filepath.Join(dir, rendition, name), make([]byte, 100), 0600))
+		}
+	}
+	// the 720p0 segments were written before the profile of the next attempt
+	for _, name := range []string{"0.ts", "1.ts"} {
+		require.NoError(os.Chtimes(filepath.Join(dir, "720p0", name), time.Now().Add(-time.Minute), time.Now().Add(-time.Minute)))
+	}
+
+	// the next attempt got another bitrate for the 720p0 rendition
+	profiles := []video.EncodedProfile{{Name: "360p0", Bitrate: 1_000_000}, {Name: "720p0", Bitrate: 2_000_000}}
+	checkpoint := loadSegmentCheckpoint(context.Background(), "req", target, profiles)
+	require.Contains(checkpoint, "360p0")
+	require.NotContains(checkpoint, "720p0")
+	require.False(checkpoint.isComplete(0, profiles))
+
+	// its segment 0 was transcoded again before it failed too
+	require.NoError(writeCheckpointProfiles(context.Background(), target, profiles, checkpoint))
+	require.NoError(os.WriteFile(filepath.Join(dir, "720p0", "0.ts"), make([]byte, 200), 0600))
+
+	// the segments left over by the first attempt are still not reused
+	checkpoint = loadSegmentCheckpoint(context.Background(), "req", target, profiles)
+	require.True(checkpoint.isComplete(0, profiles))
+	require.False(checkpoint.isComplete(1, profiles))
+	require.Equal(int64(200), checkpoint["720p0"][0])
+}
+
+func TestSegmentCheckpointMissingTarget(t *testing.T) {
+	target, err := url.Parse(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	checkpoint := loadSegmentCheckpoint(context.Background(), "req", target, []video.EncodedProfile{{Name: "360p0"}})
+	require.False(t, checkpoint.isComplete(0, []video.EncodedProfile{{Name: "360p0"}}))
+}
@@ -0,0 +1,83 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// CMAF_SUBDIR is where the CMAF output is written, relative to the HLS output
+const CMAF_SUBDIR = "cmaf"
+
+// generateCMAF remuxes the transcoded segments of each rendition into CMAF
+// fMP4 segments and uploads them with their HLS and DASH manifests to the
+// target. It returns the number of bytes uploaded.
+func generateCMAF(
+	ctx context.Context,
+	renditionList *video.TRenditionList,
+	transcodeProfiles []video.EncodedProfile,
+	segmentDurationSecs float64,
+	hasVideo, hasAudio bool,
+	targetURL *url.URL,
+) (int64, error) {
+	dir, err := os.MkdirTemp(os.TempDir(), "cmaf-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp dir for CMAF muxing: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	outputDir := filepath.Join(dir, "output")
+	if err := os.Mkdir(outputDir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create CMAF output dir: %w", err)
+	}
+
+	// The representations keep the order of the profiles
+	var renditionFiles []string
+	for _, profile := range transcodeProfiles {
+		segments := renditionList.GetSegmentList(profile.Name)
+		if segments == nil || segments.Len() == 0 {
+			continue
+		}
+		renditionFile := filepath.Join(dir, profile.Name+".ts")
+		if _, err := video.ConcatTS(renditionFile, segments); err != nil {
+			return 0, err
+		}
+		renditionFiles = append(renditionFiles, renditionFile)
+	}
+	if len(renditionFiles) == 0 {
+		return 0, fmt.Errorf("no transcoded segments to mux into CMAF")
+	}
+
+	if err := video.MuxTSToCMAF(renditionFiles, outputDir, segmentDurationSecs, hasVideo, hasAudio); err != nil {
+		return 0, err
+	}
+
+	files, err := os.ReadDir(outputDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list CMAF output: %w", err)
+	}
+	var bytesWritten int64
+	for _, file := range files {
+		path := filepath.Join(outputDir, file.Name())
+		err := backoff.Retry(func() error {
+			f, err := os.Open(path)
+			if err != nil {
+				return backoff.Permanent(err)
+			}
+			defer f.Close()
+			return clients.UploadToOSURL(targetURL.String(), file.Name(), f, UPLOAD_TIMEOUT)
+		}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
+		if err != nil {
+			return bytesWritten, fmt.Errorf("failed to upload CMAF file %s: %w", file.Name(), err)
+		}
+		if info, err := file.Info(); err == nil {
+			bytesWritten += info.Size()
+		}
+	}
+	return bytesWritten, nil
+}
@@ -0,0 +1,63 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/grafov/m3u8"
+	"github.com/livepeer/catalyst-api/clients"
+	"github.com/livepeer/catalyst-api/video"
+)
+
+// uploadSubtitles writes the WebVTT segments of each subtitles rendition to
+// the target, aligned with the segments of the source.
+func uploadSubtitles(ctx context.Context, subtitles []video.Subtitles, sourceManifest m3u8.MediaPlaylist, targetOSURL *url.URL) ([]*video.RenditionStats, error) {
+	var durations []float64
+	for _, segment := range sourceManifest.Segments {
+		// The segments list is a ring buffer, the end is the first nil element
+		if segment == nil {
+			break
+		}
+		durations = append(durations, segment.Duration)
+	}
+
+	var stats []*video.RenditionStats
+	for _, s := range subtitles {
+		renditionURL := targetOSURL.JoinPath(s.Name).String()
+		var size int64
+		for i, segment := range video.SegmentWebVTT(s.Cues, durations) {
+			err := backoff.Retry(func() error {
+				return clients.UploadToOSURL(renditionURL, fmt.Sprintf("%d.vtt", i), bytes.NewReader(segment), UPLOAD_TIMEOUT)
+			}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
+			if err != nil {
+				return nil, fmt.Errorf("failed to upload subtitles segment: %s", err)
+			}
+			size += int64(len(segment))
+		}
+		stats = append(stats, &video.RenditionStats{
+			Name:     s.Name,
+			Language: s.Language,
+			Title:    s.Label,
+			Bytes:    size,
+		})
+	}
+	return stats, nil
+}
+
+// subtitleOutputs lists the subtitles renditions in the completion callback.
+func subtitleOutputs(stats []*video.RenditionStats, targetOSURL, playbackBaseURL string) []video.OutputTextTrack {
+	var tracks []video.OutputTextTrack
+	for _, s := range stats {
+		tracks = append(tracks, video.OutputTextTrack{
+			Name:     s.Name,
+			Language: s.Language,
+			Label:    s.Title,
+			Location: strings.ReplaceAll(s.ManifestLocation, targetOSURL, playbackBaseURL),
+		})
+	}
+	return tracks
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +24,10 @@ import (
 const UPLOAD_TIMEOUT = 5 * time.Minute
 const TRANSMUX_STORAGE_DIR = "/tmp/transmux_stage"
 
+// SEGMENT_SPOOL_PATTERN is the name of the local directories where the
+// transcoded segments are kept for the MP4 and CMAF outputs
+const SEGMENT_SPOOL_PATTERN = "transcoded-segments-*"
+
 type TranscodeSegmentRequest struct {
 	SourceFile        string                 `json:"source_location"`
 	CallbackURL       string                 `json:"callback_url"`
@@ -45,6 +50,31 @@ type TranscodeSegmentRequest struct {
 	RequestID      string                                 `json:"-"`
 	ReportProgress func(clients.TranscodeStatus, float64) `json:"-"`
 	GenerateMP4    bool
+	// Also write the renditions as CMAF fMP4 segments with HLS and DASH
+	// manifests, in the CMAF_SUBDIR directory of the HLS output
+	GenerateCMAF bool `json:"-"`
+	// Skip the segments already written to the target for all the renditions
+	// by a previous attempt of the same job
+	ResumeFromCheckpoint bool `json:"-"`
+	// Overrides the Broadcaster used to transcode the segments when set
+	Transcoder clients.BroadcasterClient `json:"-"`
+	// Called with the timings of each stage of the process once it's done
+	RecordStage func(clients.JobStage) `json:"-"`
+	// Subtitles renditions to add to the output
+	Subtitles []video.Subtitles `json:"-"`
+}
+
+// keepSegments is true when the transcoded segments are spooled to a local
+// SEGMENT_SPOOL_PATTERN directory, to be remuxed into other formats once all
+// of them are transcoded
+func (r TranscodeSegmentRequest) keepSegments() bool {
+	return r.GenerateMP4 || r.GenerateCMAF
+}
+
+func (r TranscodeSegmentRequest) recordStage(name string, start time.Time, bytes int64, segments int) {
+	if r.RecordStage != nil {
+		r.RecordStage(clients.NewJobStage(name, start, bytes, segments))
+	}
 }
 
 var LocalBroadcasterClient clients.BroadcasterClient
@@ -57,7 +87,7 @@ func init() {
 	LocalBroadcasterClient = b
 }
 
-func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName string, inputInfo video.InputVideo) ([]video.OutputVideo, int, error) {
+func RunTranscodeProcess(ctx context.Context, transcodeRequest TranscodeSegmentRequest, streamName string, inputInfo video.InputVideo) ([]video.OutputVideo, int, error) {
 	log.AddContext(transcodeRequest.RequestID, "source", transcodeRequest.SourceFile, "source_manifest", transcodeRequest.SourceManifestURL, "stream_name", streamName)
 	log.Log(transcodeRequest.RequestID, "RunTranscodeProcess (v2) Beginning")
 
@@ -75,8 +105,18 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 	// transcodeProfiles are desired constraints for transcoding process
 	transcodeProfiles := transcodeRequest.Profiles
 
-	// If Profiles haven't been overridden, use the default set
-	if len(transcodeProfiles) == 0 {
+	audioOnly := inputInfo.IsAudioOnly()
+	if audioOnly {
+		// Livepeer can't transcode inputs without video, so the audio renditions
+		// are always transcoded with a local ffmpeg and the video profiles ignored
+		transcodeProfiles, err = video.GetAudioPlaybackProfiles(inputInfo)
+		if err != nil {
+			return outputs, segmentsCount, fmt.Errorf("failed to get audio playback profiles: %w", err)
+		}
+		transcodeRequest.Transcoder = clients.FfmpegTranscoder{AudioOnly: true}
+		log.Log(transcodeRequest.RequestID, "Transcoding audio-only input", "profiles", len(transcodeProfiles))
+	} else if len(transcodeProfiles) == 0 {
+		// If Profiles haven't been overridden, use the default set
 		transcodeProfiles, err = video.GetPlaybackProfiles(inputInfo)
 		if err != nil {
 			return outputs, segmentsCount, fmt.Errorf("failed to get playback profiles: %w", err)
@@ -99,54 +139,106 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 	// The last segment in an HLS manifest may contain an audio-only track - this is common
 	// during a livestream recording where the video stream can end sooner with a trailing audio stream
 	// which results in a segment at the end that just contains audio. This segment should *not* be
-	// submitted to the T.
-	lastSegment := sourceSegmentURLs[len(sourceSegmentURLs)-1]
-	lastSegmentURL, err := clients.SignURL(lastSegment.URL)
-	if err != nil {
-		return outputs, segmentsCount, fmt.Errorf("failed to create signed url for last segment %s: %w", lastSegment.URL, err)
-	}
-	p := video.Probe{}
-	// ProbeFile will return err for various reasons so we use the subsequent GetTrack method to check for video tracks
-	lastSegmentProbe, _ := p.ProbeFile(transcodeRequest.RequestID, lastSegmentURL)
-	// GetTrack will return an err if TrackTypeVideo was not found
-	_, err = lastSegmentProbe.GetTrack(video.TrackTypeVideo)
-	if err != nil {
-		var lastSegmentIdx int
-		for i, entry := range sourceManifest.Segments {
-			if entry == nil {
-				lastSegmentIdx = i - 1
-				break
+	// submitted to the T. Audio-only inputs are made of such segments only and
+	// are transcoded locally, so there's nothing to skip.
+	if !audioOnly {
+		lastSegment := sourceSegmentURLs[len(sourceSegmentURLs)-1]
+		lastSegmentURL, err := clients.SignURL(lastSegment.URL)
+		if err != nil {
+			return outputs, segmentsCount, fmt.Errorf("failed to create signed url for last segment %s: %w", lastSegment.URL, err)
+		}
+		p := video.Probe{}
+		// ProbeFile will return err for various reasons so we use the subsequent GetTrack method to check for video tracks
+		lastSegmentProbe, _ := p.ProbeFile(transcodeRequest.RequestID, lastSegmentURL)
+		// GetTrack will return an err if TrackTypeVideo was not found
+		_, err = lastSegmentProbe.GetTrack(video.TrackTypeVideo)
+		if err != nil {
+			var lastSegmentIdx int
+			for i, entry := range sourceManifest.Segments {
+				if entry == nil {
+					lastSegmentIdx = i - 1
+					break
+				}
 			}
+			log.Log(transcodeRequest.RequestID, "last segment in manifest contains an audio-only track", "skipped-segment", lastSegmentIdx)
+			// remove the last segment from both the manifest and list of segment URLs
+			sourceManifest.Segments[lastSegmentIdx] = nil
+			sourceSegmentURLs = sourceSegmentURLs[:len(sourceSegmentURLs)-1]
 		}
-		log.Log(transcodeRequest.RequestID, "last segment in manifest contains an audio-only track", "skipped-segment", lastSegmentIdx)
-		// remove the last segment from both the manifest and list of segment URLs
-		sourceManifest.Segments[lastSegmentIdx] = nil
-		sourceSegmentURLs = sourceSegmentURLs[:len(sourceSegmentURLs)-1]
 	}
 
 	// Use RequestID as part of manifestID when talking to the Broadcaster
 	manifestID := "manifest-" + transcodeRequest.RequestID
 	// transcodedStats hold actual info from transcoded results within requested constraints (this usually differs from requested profiles)
-	transcodedStats := statsFromProfiles(transcodeProfiles)
+	transcodedStats := newRenditionsStats(statsFromProfiles(transcodeProfiles))
+	if audioOnly {
+		for _, stats := range transcodedStats.stats {
+			stats.Codecs = video.AACCodecs
+		}
+	} else {
+		videoTrack, _ := inputInfo.GetTrack(video.TrackTypeVideo)
+		_, audioErr := inputInfo.GetTrack(video.TrackTypeAudio)
+		for i, stats := range transcodedStats.stats {
+			stats.Codecs = video.RenditionCodecs(transcodeProfiles[i], videoTrack.FPS, audioErr == nil)
+		}
+	}
 
 	renditionList := video.TRenditionList{RenditionSegmentTable: make(map[string]*video.TSegmentList)}
-	// only populate video.TRenditionList map if MP4 is enabled via override or short-form video detection
-	if transcodeRequest.GenerateMP4 {
-		for _, profile := range transcodeProfiles {
-			renditionList.AddRenditionSegment(profile.Name,
-				&video.TSegmentList{
-					SegmentDataTable: make(map[int][]byte),
-				})
+	// only populate video.TRenditionList map if MP4 is enabled via override or short-form video detection,
+	// or if CMAF is enabled. The segments are spooled to disk until the end of the job.
+	if transcodeRequest.keepSegments() {
+		spoolDir, err := os.MkdirTemp(os.TempDir(), SEGMENT_SPOOL_PATTERN)
+		if err != nil {
+			return outputs, segmentsCount, fmt.Errorf("failed to create temp dir for the transcoded segments: %w", err)
+		}
+		defer os.RemoveAll(spoolDir)
+		for i, profile := range transcodeProfiles {
+			// profile names aren't necessarily valid file names
+			dir := filepath.Join(spoolDir, strconv.Itoa(i))
+			if err := os.Mkdir(dir, 0700); err != nil {
+				return outputs, segmentsCount, fmt.Errorf("failed to create temp dir for the %s segments: %w", profile.Name, err)
+			}
+			renditionList.AddRenditionSegment(profile.Name, video.NewTSegmentList(dir))
+		}
+	}
+
+	audio := newAudioRenditions(nil)
+	if !audioOnly {
+		audio = newAudioRenditions(video.GetAudioRenditions(inputInfo))
+		if len(audio.renditions) > 0 {
+			log.Log(transcodeRequest.RequestID, "Transcoding alternate audio renditions", "renditions", len(audio.renditions))
 		}
 	}
 
+	checkpoint := segmentCheckpoint{}
+	if transcodeRequest.ResumeFromCheckpoint {
+		checkpoint = loadSegmentCheckpoint(ctx, transcodeRequest.RequestID, hlsTargetURL, transcodeProfiles)
+		log.Log(transcodeRequest.RequestID, "Resuming transcode from checkpoint", "existing_segments", checkpoint.len(transcodeProfiles), "total_segments", len(sourceSegmentURLs))
+	}
+	if err := writeCheckpointProfiles(ctx, hlsTargetURL, transcodeProfiles, checkpoint); err != nil {
+		return outputs, segmentsCount, err
+	}
+
+	transcodingStart := time.Now()
 	var jobs *ParallelTranscoding
 	jobs = NewParallelTranscoding(sourceSegmentURLs, func(segment segmentInfo) error {
-		err := transcodeSegment(segment, streamName, manifestID, transcodeRequest, transcodeProfiles, hlsTargetURL, transcodedStats, &renditionList)
-		segmentsCount++
+		// Stop picking up new segments once the job was cancelled
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if checkpoint.isComplete(segment.Index, transcodeProfiles) {
+			err = reuseSegment(ctx, segment, checkpoint, transcodeRequest, transcodeProfiles, hlsTargetURL, transcodedStats, &renditionList)
+		} else {
+			err = transcodeSegment(ctx, segment, streamName, manifestID, transcodeRequest, transcodeProfiles, hlsTargetURL, transcodedStats, &renditionList)
+			segmentsCount++
+		}
 		if err != nil {
 			return err
 		}
+		if err := audio.transcodeSegment(ctx, segment, transcodeRequest.RequestID, hlsTargetURL); err != nil {
+			return err
+		}
 		if jobs.IsRunning() && transcodeRequest.ReportProgress != nil {
 			// Sending callback only if we are still running
 			var completedRatio = calculateCompletedRatio(jobs.GetTotalCount(), jobs.GetCompletedCount()+1)
@@ -159,16 +251,44 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 		// return first error to caller
 		return outputs, segmentsCount, err
 	}
+	var transcodedBytes int64
+	for _, stats := range transcodedStats.stats {
+		transcodedBytes += stats.Bytes
+	}
+	transcodeRequest.recordStage("transcoding", transcodingStart, transcodedBytes, len(sourceSegmentURLs))
 
 	// Build the manifests and push them to storage
-	manifestURL, err := clients.GenerateAndUploadManifests(sourceManifest, hlsTargetURL.String(), transcodedStats)
+	manifestStart := time.Now()
+	subtitleStats, err := uploadSubtitles(ctx, transcodeRequest.Subtitles, sourceManifest, hlsTargetURL)
+	if err != nil {
+		return outputs, segmentsCount, err
+	}
+	manifestURL, err := clients.GenerateAndUploadManifests(sourceManifest, hlsTargetURL.String(), transcodedStats.stats, audio.stats.stats, subtitleStats)
 	if err != nil {
 		return outputs, segmentsCount, err
 	}
+	transcodeRequest.recordStage("manifest_upload", manifestStart, 0, 0)
+
+	cmafTargetURL := hlsTargetURL.JoinPath(CMAF_SUBDIR)
+	generatedCMAF := false
+	if transcodeRequest.GenerateCMAF {
+		cmafStart := time.Now()
+		_, audioErr := inputInfo.GetTrack(video.TrackTypeAudio)
+		segmentDurationSecs := float64(sourceSegmentURLs[0].DurationMillis) / 1000
+		cmafBytes, err := generateCMAF(ctx, &renditionList, transcodeProfiles, segmentDurationSecs, !audioOnly, audioErr == nil, cmafTargetURL)
+		if err != nil {
+			log.LogError(transcodeRequest.RequestID, "failed to generate CMAF output", err)
+		} else {
+			generatedCMAF = true
+		}
+		transcodeRequest.recordStage("cmaf_muxing", cmafStart, cmafBytes, 0)
+	}
 
 	var mp4OutputsPre []video.OutputVideoFile
 	// Transmux received segments from T into a single mp4
 	if transcodeRequest.GenerateMP4 {
+		mp4Start := time.Now()
+		var mp4Bytes int64
 		mp4TargetUrlBase, err := url.Parse(transcodeRequest.Mp4TargetUrl)
 		if err != nil {
 			return outputs, segmentsCount, err
@@ -197,18 +317,23 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 			// c. Verify the total bytes written for the single .ts file for a given rendition matches the total # of bytes we received from T
 			renditionIndex := getProfileIndex(transcodeProfiles, rendition)
 			var rendBytesWritten int64 = -1
-			for _, v := range transcodedStats {
+			for _, v := range transcodedStats.stats {
 				if v.Name == rendition {
 					rendBytesWritten = v.Bytes
 				}
 			}
 			if rendBytesWritten != totalBytes {
-				log.Log(transcodeRequest.RequestID, "bytes written does not match", "file", concatTsFileName, "bytes expected", transcodedStats[renditionIndex].Bytes, "bytes written", totalBytes)
+				log.Log(transcodeRequest.RequestID, "bytes written does not match", "file", concatTsFileName, "bytes expected", transcodedStats.stats[renditionIndex].Bytes, "bytes written", totalBytes)
 				break
 			}
 
 			// d. Transmux the single .ts file into an .mp4 file
-			mp4OutputFileName := concatTsFileName[:len(concatTsFileName)-len(filepath.Ext(concatTsFileName))] + ".mp4"
+			// audio-only renditions are muxed into M4A files
+			mp4Ext := "mp4"
+			if audioOnly {
+				mp4Ext = "m4a"
+			}
+			mp4OutputFileName := concatTsFileName[:len(concatTsFileName)-len(filepath.Ext(concatTsFileName))] + "." + mp4Ext
 			err = video.MuxTStoMP4(concatTsFileName, mp4OutputFileName)
 			if err != nil {
 				log.Log(transcodeRequest.RequestID, "error transmuxing", "err", err)
@@ -222,8 +347,11 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 				log.Log(transcodeRequest.RequestID, "error opening mp4", "file", mp4OutputFileName, "err", err)
 				break
 			}
+			if info, err := mp4OutputFile.Stat(); err == nil {
+				mp4Bytes += info.Size()
+			}
 
-			filename := fmt.Sprintf("%s.mp4", rendition)
+			filename := fmt.Sprintf("%s.%s", rendition, mp4Ext)
 			err = backoff.Retry(func() error {
 				return clients.UploadToOSURL(mp4TargetUrlBase.String(), filename, bufio.NewReader(mp4OutputFile), UPLOAD_TIMEOUT)
 			}, clients.UploadRetryBackoff())
@@ -233,11 +361,12 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 			}
 
 			mp4Out := video.OutputVideoFile{
-				Type:     "mp4",
+				Type:     mp4Ext,
 				Location: mp4TargetUrlBase.JoinPath(filename).String(),
 			}
 			mp4OutputsPre = append(mp4OutputsPre, mp4Out)
 		}
+		transcodeRequest.recordStage("mp4_muxing", mp4Start, mp4Bytes, 0)
 	}
 
 	hlsPlaybackBaseURL, mp4PlaybackBaseURL, err := clients.Publish(hlsTargetURL.String(), transcodeRequest.Mp4TargetUrl)
@@ -284,10 +413,17 @@ func RunTranscodeProcess(transcodeRequest TranscodeSegmentRequest, streamName st
 	}
 	output := video.OutputVideo{Type: "object_store", Manifest: manifest}
 	if transcodeRequest.HlsTargetURL != "" {
-		for _, rendition := range transcodedStats {
+		for _, rendition := range transcodedStats.stats {
 			videoManifestURL := strings.ReplaceAll(rendition.ManifestLocation, hlsTargetURL.String(), hlsPlaybackBaseURL)
 			output.Videos = append(output.Videos, video.OutputVideoFile{Location: videoManifestURL, SizeBytes: rendition.Bytes})
 		}
+		output.AudioTracks = audio.outputs(hlsTargetURL.String(), hlsPlaybackBaseURL)
+		output.Subtitles = subtitleOutputs(subtitleStats, hlsTargetURL.String(), hlsPlaybackBaseURL)
+		if generatedCMAF {
+			cmafPlaybackURL := strings.ReplaceAll(cmafTargetURL.String(), hlsTargetURL.String(), hlsPlaybackBaseURL)
+			output.CMAFManifest, _ = url.JoinPath(cmafPlaybackURL, video.CMAFHLSManifest)
+			output.DASHManifest, _ = url.JoinPath(cmafPlaybackURL, video.CMAFDASHManifest)
+		}
 	}
 	output.MP4Outputs = mp4Outputs
 	outputs = []video.OutputVideo{output}
@@ -315,45 +451,51 @@ func getHlsTargetURL(tsr TranscodeSegmentRequest) (*url.URL, error) {
 }
 
 func transcodeSegment(
+	ctx context.Context,
 	segment segmentInfo, streamName, manifestID string,
 	transcodeRequest TranscodeSegmentRequest,
 	transcodeProfiles []video.EncodedProfile,
 	targetOSURL *url.URL,
-	transcodedStats []*video.RenditionStats,
+	transcodedStats *renditionsStats,
 	renditionList *video.TRenditionList,
 ) error {
 	start := time.Now()
 
 	var tr clients.TranscodeResult
 	err := backoff.Retry(func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), clients.MaxCopyFileDuration)
+		ctx, cancel := context.WithTimeout(ctx, clients.MaxCopyFileDuration)
 		defer cancel()
-		rc, err := clients.GetFile(ctx, transcodeRequest.RequestID, segment.Input.URL.String(), nil)
+		rc, err := clients.GetSourceSegment(ctx, transcodeRequest.RequestID, segment.Input)
 		if err != nil {
 			return fmt.Errorf("failed to download source segment %q: %s", segment.Input, err)
 		}
 
 		// If an AccessToken is provided via the request for transcode, then use remote Broadcasters.
 		// Otherwise, use the local harcoded Broadcaster.
-		if transcodeRequest.AccessToken != "" {
+		if transcodeRequest.Transcoder != nil {
+			tr, err = transcodeRequest.Transcoder.TranscodeSegment(ctx, rc, int64(segment.Index), transcodeProfiles, segment.Input.DurationMillis, manifestID)
+			if err != nil {
+				return fmt.Errorf("failed to run TranscodeSegment: %s", err)
+			}
+		} else if transcodeRequest.AccessToken != "" {
 			creds := clients.Credentials{
 				AccessToken:  transcodeRequest.AccessToken,
 				CustomAPIURL: transcodeRequest.TranscodeAPIUrl,
 			}
 			broadcasterClient, _ := clients.NewRemoteBroadcasterClient(creds)
 			// TODO: failed to run TranscodeSegmentWithRemoteBroadcaster: CreateStream(): http POST(https://origin.livepeer.com/api/stream) returned 422 422 Unprocessable Entity
-			tr, err = broadcasterClient.TranscodeSegmentWithRemoteBroadcaster(rc, int64(segment.Index), transcodeProfiles, streamName, segment.Input.DurationMillis)
+			tr, err = broadcasterClient.TranscodeSegmentWithRemoteBroadcaster(ctx, rc, int64(segment.Index), transcodeProfiles, streamName, segment.Input.DurationMillis)
 			if err != nil {
 				return fmt.Errorf("failed to run TranscodeSegmentWithRemoteBroadcaster: %s", err)
 			}
 		} else {
-			tr, err = LocalBroadcasterClient.TranscodeSegment(rc, int64(segment.Index), transcodeProfiles, segment.Input.DurationMillis, manifestID)
+			tr, err = LocalBroadcasterClient.TranscodeSegment(ctx, rc, int64(segment.Index), transcodeProfiles, segment.Input.DurationMillis, manifestID)
 			if err != nil {
 				return fmt.Errorf("failed to run TranscodeSegment: %s", err)
 			}
 		}
 		return nil
-	}, TranscodeRetryBackoff())
+	}, backoff.WithContext(TranscodeRetryBackoff(), ctx))
 
 	if err != nil {
 		return err
@@ -373,30 +515,84 @@ func transcodeSegment(
 			return fmt.Errorf("error building rendition segment URL %q: %s", targetRenditionURL, err)
 		}
 
-		if transcodeRequest.GenerateMP4 {
+		if transcodeRequest.keepSegments() {
 			// get inner segments table from outer rendition table
 			segmentsList := renditionList.GetSegmentList(transcodedSegment.Name)
 			// add new entry for segment # and corresponding byte stream
-			segmentsList.AddSegmentData(segment.Index, transcodedSegment.MediaData)
+			if err := segmentsList.AddSegmentData(segment.Index, transcodedSegment.MediaData); err != nil {
+				return err
+			}
 		}
 
 		err = backoff.Retry(func() error {
 			return clients.UploadToOSURL(targetRenditionURL, fmt.Sprintf("%d.ts", segment.Index), bytes.NewReader(transcodedSegment.MediaData), UPLOAD_TIMEOUT)
-		}, clients.UploadRetryBackoff())
+		}, backoff.WithContext(clients.UploadRetryBackoff(), ctx))
 		if err != nil {
 			return fmt.Errorf("failed to upload master playlist: %s", err)
 		}
 
-		// bitrate calculation
-		transcodedStats[renditionIndex].Bytes += int64(len(transcodedSegment.MediaData))
-		transcodedStats[renditionIndex].DurationMs += float64(segment.Input.DurationMillis)
+		transcodedStats.addSegment(renditionIndex, int64(len(transcodedSegment.MediaData)), segment.Input.DurationMillis, parseSegmentInfo(transcodedSegment.MediaData))
+	}
+
+	return nil
+}
+
+// renditionsStats are the stats of the renditions of a job, updated by its
+// parallel segment workers.
+type renditionsStats struct {
+	mu    sync.Mutex
+	stats []*video.RenditionStats
+}
+
+func newRenditionsStats(stats []*video.RenditionStats) *renditionsStats {
+	return &renditionsStats{stats: stats}
+}
+
+// addSegment adds a segment to the stats of the rendition at index, and
+// updates its average bitrate.
+func (s *renditionsStats) addSegment(index int, size, durationMillis int64, info *video.SegmentInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats[index]
+	addSegmentStats(stats, size, durationMillis, info)
+	if stats.DurationMs > 0 {
+		stats.BitsPerSecond = uint32(float64(stats.Bytes) * 8.0 / (stats.DurationMs / 1000))
 	}
+}
 
-	for _, stats := range transcodedStats {
-		stats.BitsPerSecond = uint32(float64(stats.Bytes) * 8.0 / float64(stats.DurationMs/1000))
+// parseSegmentInfo reads the media information of a rendition segment. The
+// stats keep the values of the requested profile when it can't be parsed.
+func parseSegmentInfo(data []byte) *video.SegmentInfo {
+	info, err := video.ParseTSSegment(data)
+	if err != nil {
+		return nil
 	}
+	return &info
+}
 
-	return nil
+// addSegmentStats adds a segment of the rendition to its stats, along with the
+// dimensions, frame rate and codecs of the segment if it could be parsed.
+func addSegmentStats(stats *video.RenditionStats, size, durationMillis int64, info *video.SegmentInfo) {
+	stats.Bytes += size
+	stats.DurationMs += float64(durationMillis)
+	if durationMillis > 0 {
+		if bps := uint32(float64(size) * 8 / (float64(durationMillis) / 1000)); bps > stats.PeakBitsPerSecond {
+			stats.PeakBitsPerSecond = bps
+		}
+	}
+	if info == nil {
+		return
+	}
+	if info.Width > 0 && info.Height > 0 {
+		stats.Width, stats.Height = info.Width, info.Height
+	}
+	if info.FPS > 0 {
+		stats.FPS = info.FPS
+	}
+	// only H264 is parsed, keep the codecs of the profile for the other video codecs
+	if info.Codecs != "" && (info.Width > 0 || stats.Width == 0) {
+		stats.Codecs = info.Codecs
+	}
 }
 
 func getProfileIndex(transcodeProfiles []video.EncodedProfile, profile string) int {
@@ -430,10 +626,11 @@ func statsFromProfiles(profiles []video.EncodedProfile) []*video.RenditionStats
 	stats := []*video.RenditionStats{}
 	for _, profile := range profiles {
 		stats = append(stats, &video.RenditionStats{
-			Name:   profile.Name,
-			Width:  profile.Width,  // TODO: extract this from actual media retrieved from B
-			Height: profile.Height, // TODO: extract this from actual media retrieved from B
-			FPS:    profile.FPS,    // TODO: extract this from actual media retrieved from B
+			Name: profile.Name,
+			// overwritten with the values read from the transcoded segments
+			Width:  profile.Width,
+			Height: profile.Height,
+			FPS:    float64(profile.FPS),
 		})
 	}
 	return stats
@@ -1,6 +1,7 @@
 package transcode
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -39,7 +40,7 @@ type StubBroadcasterClient struct {
 	tr clients.TranscodeResult
 }
 
-func (c StubBroadcasterClient) TranscodeSegment(segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (clients.TranscodeResult, error) {
+func (c StubBroadcasterClient) TranscodeSegment(ctx context.Context, segment io.Reader, sequenceNumber int64, profiles []video.EncodedProfile, durationMillis int64, manifestID string) (clients.TranscodeResult, error) {
 	return c.tr, nil
 }
 
@@ -134,6 +135,7 @@ func TestItCanTranscode(t *testing.T) {
 	statusClient := clients.NewPeriodicCallbackClient(100*time.Minute, map[string]string{})
 	// Check we don't get an error downloading or parsing it
 	outputs, segmentsCount, err := RunTranscodeProcess(
+		context.Background(),
 		TranscodeSegmentRequest{
 			CallbackURL:       callbackServer.URL,
 			SourceManifestURL: manifestFile.Name(),
@@ -161,9 +163,9 @@ func TestItCanTranscode(t *testing.T) {
 	// Confirm the master manifest was created and that it looks like a manifest
 	var expectedMasterManifest = `#EXTM3U
 #EXT-X-VERSION:3
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=3195660,RESOLUTION=2020x2020,NAME="0-2020p0"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=4718002,AVERAGE-BANDWIDTH=3195660,CODECS="avc1.640032",RESOLUTION=2020x2020,NAME="0-2020p0"
 2020p0/index.m3u8
-#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=532610,RESOLUTION=2020x2020,NAME="1-low-bitrate"
+#EXT-X-STREAM-INF:PROGRAM-ID=0,BANDWIDTH=786333,AVERAGE-BANDWIDTH=532610,CODECS="avc1.640032",RESOLUTION=2020x2020,NAME="1-low-bitrate"
 low-bitrate/index.m3u8
 `
 
@@ -202,6 +204,32 @@ func TestItCalculatesTheTranscodeCompletionPercentageCorrectly(t *testing.T) {
 	require.Equal(t, 0.6, calculateCompletedRatio(100, 60))
 }
 
+func TestRenditionsStatsTrackThePeakAndAverageBitrates(t *testing.T) {
+	stats := newRenditionsStats(statsFromProfiles([]video.EncodedProfile{{Name: "360p0"}, {Name: "720p0"}}))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// 1 segment out of 10 is twice as big
+			size := int64(100_000)
+			if i == 0 {
+				size = 200_000
+			}
+			stats.addSegment(0, size, 2000, nil)
+			stats.addSegment(1, 3*size, 2000, nil)
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(1_100_000), stats.stats[0].Bytes)
+	require.Equal(t, float64(20_000), stats.stats[0].DurationMs)
+	require.Equal(t, uint32(440_000), stats.stats[0].BitsPerSecond)
+	require.Equal(t, uint32(800_000), stats.stats[0].PeakBitsPerSecond)
+	require.Equal(t, uint32(1_320_000), stats.stats[1].BitsPerSecond)
+	require.Equal(t, uint32(2_400_000), stats.stats[1].PeakBitsPerSecond)
+}
+
 func TestParallelJobFailureStopsNextBatch(t *testing.T) {
 	config.TranscodingParallelJobs = 3
 	config.TranscodingParallelSleep = 0
@@ -0,0 +1,143 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Segments cut less than this from their start or end are kept whole, rather
+// than re-encoded to drop a fraction of a frame
+const clipBoundaryToleranceSecs = 0.001
+
+// ClipRange is the part of an asset kept in a clip, in seconds from its start.
+type ClipRange struct {
+	StartSecs float64 `json:"start_secs"`
+	EndSecs   float64 `json:"end_secs"`
+}
+
+func (r ClipRange) Duration() float64 {
+	return r.EndSecs - r.StartSecs
+}
+
+// ClipSegment is a segment of the source covered by a clip.
+type ClipSegment struct {
+	// Index of the segment in the source playlist
+	Index int
+	// Part of the segment kept in the clip, in seconds from the start of the segment
+	StartSecs    float64
+	DurationSecs float64
+	// Only part of the segment is kept, so it has to be re-encoded
+	Trimmed bool
+}
+
+// SelectClipSegments returns the segments covering the clip, given the
+// durations of the source segments. The boundary segments are trimmed to the
+// exact start and end of the clip, and the end is clamped to the end of the
+// source.
+func SelectClipSegments(durations []float64, r ClipRange) ([]ClipSegment, error) {
+	if r.StartSecs < 0 || r.EndSecs <= r.StartSecs {
+		return nil, fmt.Errorf("invalid clip range %.3f-%.3f", r.StartSecs, r.EndSecs)
+	}
+	var segments []ClipSegment
+	var segmentStart float64
+	for i, duration := range durations {
+		segmentEnd := segmentStart + duration
+		from := math.Max(r.StartSecs, segmentStart)
+		to := math.Min(r.EndSecs, segmentEnd)
+		if to-from > clipBoundaryToleranceSecs {
+			segment := ClipSegment{Index: i, DurationSecs: duration}
+			if from-segmentStart > clipBoundaryToleranceSecs || segmentEnd-to > clipBoundaryToleranceSecs {
+				segment.StartSecs = from - segmentStart
+				segment.DurationSecs = to - from
+				segment.Trimmed = true
+			}
+			segments = append(segments, segment)
+		}
+		segmentStart = segmentEnd
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("clip range %.3f-%.3f is outside of the %.3fs source", r.StartSecs, r.EndSecs, segmentStart)
+	}
+	return segments, nil
+}
+
+// TrimSegment re-encodes the part of an MPEG-TS segment kept in a clip, so
+// that the clip starts and ends on the exact frames. The bitrate should be the
+// one of the source segment, to keep the same quality. The video is encoded
+// with the H264 profile and level of videoCodec, the RFC 6381 codec string of
+// the source segment, so that the clip rendition keeps a single CODECS value.
+func TrimSegment(ctx context.Context, inputFile, outputFile string, startSecs, durationSecs float64, bitrate int64, videoCodec string) error {
+	args := clipArgs(startSecs, durationSecs)
+	args["f"] = "mpegts"
+	if bitrate > 0 {
+		args["b:v"] = bitrate
+	}
+	if profile, level, ok := h264ProfileAndLevel(videoCodec); ok {
+		args["profile:v"] = profile
+		args["level:v"] = level
+	}
+	if err := runClip(ctx, inputFile, outputFile, args); err != nil {
+		return fmt.Errorf("failed to trim segment (%s): %w", inputFile, err)
+	}
+	return nil
+}
+
+// ClipToMP4 re-encodes the part of an MPEG-TS file kept in a clip to an MP4 file.
+func ClipToMP4(ctx context.Context, tsInputFile, mp4OutputFile string, startSecs, durationSecs float64) error {
+	args := clipArgs(startSecs, durationSecs)
+	args["movflags"] = "faststart"
+	if err := runClip(ctx, tsInputFile, mp4OutputFile, args); err != nil {
+		return fmt.Errorf("failed to clip (%s) into a mp4 file: %w", tsInputFile, err)
+	}
+	return nil
+}
+
+// h264ProfileAndLevel returns the libx264 profile and level of an avc1 codec
+// string, for the profiles that libx264 writes.
+func h264ProfileAndLevel(codec string) (string, string, bool) {
+	var profileIdc, constraints, levelIdc int
+	if _, err := fmt.Sscanf(codec, "avc1.%02X%02X%02X", &profileIdc, &constraints, &levelIdc); err != nil {
+		return "", "", false
+	}
+	profiles := map[int]string{0x42: "baseline", 0x4D: "main", 0x64: "high"}
+	profile, ok := profiles[profileIdc]
+	if !ok || levelIdc == 0 {
+		return "", "", false
+	}
+	return profile, fmt.Sprintf("%d.%d", levelIdc/10, levelIdc%10), true
+}
+
+// VideoCodecName returns the ffprobe name of the codec of the first video
+// stream of the file, or an empty string when it has no video.
+func VideoCodecName(ctx context.Context, inputFile string) (string, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=codec_name", "-of", "csv=p=0", inputFile).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to probe video codec (%s): %w", inputFile, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// clipArgs seeks after decoding, which is slower than seeking the input but
+// frame accurate
+func clipArgs(startSecs, durationSecs float64) ffmpeg.KwArgs {
+	return ffmpeg.KwArgs{
+		"ss":     fmt.Sprintf("%.3f", startSecs),
+		"t":      fmt.Sprintf("%.3f", durationSecs),
+		"c:v":    "libx264",
+		"preset": "veryfast",
+		"c:a":    "aac",
+	}
+}
+
+func runClip(ctx context.Context, inputFile, outputFile string, args ffmpeg.KwArgs) error {
+	stream := ffmpeg.Input(inputFile).Output(outputFile, args)
+	// ffmpeg gets killed if the context is cancelled
+	stream.Context = ctx
+	return stream.OverWriteOutput().ErrorToStdOut().Run()
+}
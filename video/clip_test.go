@@ -0,0 +1,95 @@
+package video
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+func TestSelectClipSegments(t *testing.T) {
+	durations := []float64{10, 10, 10, 5}
+	tests := []struct {
+		name string
+		r    ClipRange
+		want []ClipSegment
+	}{
+		{
+			name: "whole segments",
+			r:    ClipRange{StartSecs: 10, EndSecs: 30},
+			want: []ClipSegment{
+				{Index: 1, DurationSecs: 10},
+				{Index: 2, DurationSecs: 10},
+			},
+		},
+		{
+			name: "trimmed boundaries",
+			r:    ClipRange{StartSecs: 5, EndSecs: 32.5},
+			want: []ClipSegment{
+				{Index: 0, StartSecs: 5, DurationSecs: 5, Trimmed: true},
+				{Index: 1, DurationSecs: 10},
+				{Index: 2, DurationSecs: 10},
+				{Index: 3, StartSecs: 0, DurationSecs: 2.5, Trimmed: true},
+			},
+		},
+		{
+			name: "inside a single segment",
+			r:    ClipRange{StartSecs: 12, EndSecs: 15},
+			want: []ClipSegment{
+				{Index: 1, StartSecs: 2, DurationSecs: 3, Trimmed: true},
+			},
+		},
+		{
+			name: "end past the source",
+			r:    ClipRange{StartSecs: 30, EndSecs: 60},
+			want: []ClipSegment{
+				{Index: 3, DurationSecs: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectClipSegments(durations, tt.r)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSelectClipSegmentsInvalidRange(t *testing.T) {
+	durations := []float64{10, 10}
+	for _, r := range []ClipRange{
+		{StartSecs: 10, EndSecs: 10},
+		{StartSecs: 10, EndSecs: 5},
+		{StartSecs: -1, EndSecs: 5},
+		{StartSecs: 20, EndSecs: 30},
+	} {
+		_, err := SelectClipSegments(durations, r)
+		require.Error(t, err, r)
+	}
+}
+
+func TestClipArgs(t *testing.T) {
+	args := ffmpeg.Input("in.ts").Output("out.mp4", clipArgs(2.5, 10)).GetArgs()
+	require.Equal(t, []string{
+		"-i", "in.ts",
+		"-c:a", "aac", "-c:v", "libx264", "-preset", "veryfast", "-ss", "2.500", "-t", "10.000",
+		"out.mp4",
+	}, args)
+}
+
+func TestH264ProfileAndLevel(t *testing.T) {
+	for codec, expected := range map[string][2]string{
+		"avc1.64001F": {"high", "3.1"},
+		"avc1.4D4028": {"main", "4.0"},
+		"avc1.42C01E": {"baseline", "3.0"},
+	} {
+		profile, level, ok := h264ProfileAndLevel(codec)
+		require.True(t, ok, codec)
+		require.Equal(t, expected, [2]string{profile, level}, codec)
+	}
+	for _, codec := range []string{"", "mp4a.40.2", "hvc1.1.6.L120.B0", "avc1.7A0028"} {
+		_, _, ok := h264ProfileAndLevel(codec)
+		require.False(t, ok, codec)
+	}
+}
@@ -0,0 +1,65 @@
+package video
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	// CMAFHLSManifest is the HLS master playlist of the CMAF output, written
+	// next to the DASH manifest and referencing the same fMP4 segments
+	CMAFHLSManifest  = "index.m3u8"
+	CMAFDASHManifest = "index.mpd"
+)
+
+// MuxTSToCMAF remuxes the concatenated MPEG-TS renditions into fragmented MP4
+// segments with one init segment per representation, and writes both a DASH
+// manifest and an HLS master playlist (using EXT-X-MAP) to outputDir. The
+// audio is taken from the first rendition, except for audio-only renditions
+// which are all kept as audio representations.
+func MuxTSToCMAF(renditionFiles []string, outputDir string, segmentDurationSecs float64, hasVideo, hasAudio bool) error {
+	err := cmafStream(renditionFiles, outputDir, segmentDurationSecs, hasVideo, hasAudio).
+		OverWriteOutput().ErrorToStdOut().Run()
+	if err != nil {
+		return fmt.Errorf("failed to mux renditions into CMAF segments: %w", err)
+	}
+	return nil
+}
+
+func cmafStream(renditionFiles []string, outputDir string, segmentDurationSecs float64, hasVideo, hasAudio bool) *ffmpeg.Stream {
+	var inputs, streams []*ffmpeg.Stream
+	for _, file := range renditionFiles {
+		input := ffmpeg.Input(file)
+		inputs = append(inputs, input)
+		if hasVideo {
+			streams = append(streams, input.Video())
+		} else {
+			streams = append(streams, input.Audio())
+		}
+	}
+	adaptationSets := "id=0,streams=a"
+	if hasVideo {
+		adaptationSets = "id=0,streams=v"
+		if hasAudio && len(inputs) > 0 {
+			streams = append(streams, inputs[0].Audio())
+			adaptationSets += " id=1,streams=a"
+		}
+	}
+
+	return ffmpeg.Output(streams, filepath.Join(outputDir, CMAFDASHManifest), ffmpeg.KwArgs{
+		"c":               "copy",
+		"bsf:a":           "aac_adtstoasc",
+		"f":               "dash",
+		"seg_duration":    strconv.FormatFloat(segmentDurationSecs, 'f', -1, 64),
+		"use_template":    "1",
+		"use_timeline":    "1",
+		"adaptation_sets": adaptationSets,
+		"init_seg_name":   "init-$RepresentationID$.m4s",
+		"media_seg_name":  "chunk-$RepresentationID$-$Number%05d$.m4s",
+		"hls_playlist":    "1",
+		"hls_master_name": CMAFHLSManifest,
+	})
+}
@@ -0,0 +1,20 @@
+package video
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCMAFArgs(t *testing.T) {
+	args := strings.Join(cmafStream([]string{"360p0.ts", "720p0.ts"}, "out", 2, true, true).GetArgs(), " ")
+	require.Equal(t, "-i 360p0.ts -i 720p0.ts -map 0:v -map 1:v -map 0:a "+
+		"-adaptation_sets id=0,streams=v id=1,streams=a -bsf:a aac_adtstoasc -c copy -f dash -hls_master_name index.m3u8 -hls_playlist 1 "+
+		"-init_seg_name init-$RepresentationID$.m4s -media_seg_name chunk-$RepresentationID$-$Number%05d$.m4s "+
+		"-seg_duration 2 -use_template 1 -use_timeline 1 out/index.mpd", args)
+
+	args = strings.Join(cmafStream([]string{"audio-64k.ts", "audio-128k.ts"}, "out", 6.5, false, true).GetArgs(), " ")
+	require.Contains(t, args, "-map 0:a -map 1:a -adaptation_sets id=0,streams=a ")
+	require.Contains(t, args, "-seg_duration 6.5 ")
+}
@@ -0,0 +1,133 @@
+package video
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Video codecs that can be selected for the renditions with EncodedProfile.Codec
+const (
+	CodecH264 = "h264"
+	CodecHEVC = "hevc"
+)
+
+// defaultFPS is assumed to pick the codec level of renditions that keep the
+// frame rate of the source when it isn't known
+const defaultFPS = 30
+
+type videoCodec struct {
+	// the codec levels, from the lowest, with the max luma picture size and
+	// luma sample rate of each
+	levels []codecLevel
+	// builds the RFC 6381 codec string for a level of the table above
+	codecString func(profile string, level codecLevel) string
+}
+
+type codecLevel struct {
+	id             int
+	maxPictureSize int64
+	maxSampleRate  int64
+}
+
+var videoCodecs = map[string]videoCodec{
+	// H.264 levels are defined in macroblocks of 256 luma samples
+	CodecH264: {
+		levels: []codecLevel{
+			{21, 792 * 256, 19800 * 256},
+			{22, 1620 * 256, 20250 * 256},
+			{30, 1620 * 256, 40500 * 256},
+			{31, 3600 * 256, 108000 * 256},
+			{32, 5120 * 256, 216000 * 256},
+			{40, 8192 * 256, 245760 * 256},
+			{42, 8704 * 256, 522240 * 256},
+			{50, 22080 * 256, 589824 * 256},
+			{51, 36864 * 256, 983040 * 256},
+			{52, 36864 * 256, 2073600 * 256},
+		},
+		codecString: func(profile string, level codecLevel) string {
+			return fmt.Sprintf("avc1.%s%02X", h264ProfileIndication(profile), level.id)
+		},
+	},
+	// Main profile, Main tier. The level is 30 times the level number.
+	CodecHEVC: {
+		levels: []codecLevel{
+			{30, 552960, 16588800},
+			{31, 983040, 33177600},
+			{40, 2228224, 66846720},
+			{41, 2228224, 133693440},
+			{50, 8912896, 267386880},
+			{51, 8912896, 534773760},
+			{52, 8912896, 1069547520},
+			{60, 35651584, 1069547520},
+		},
+		codecString: func(_ string, level codecLevel) string {
+			return fmt.Sprintf("hvc1.1.6.L%d.B0", level.id*3)
+		},
+	},
+}
+
+// h264ProfileIndication returns the profile_idc and constraint flags of the
+// H264 profiles used by Livepeer, as written by x264. Defaults to High.
+func h264ProfileIndication(profile string) string {
+	switch strings.ToLower(profile) {
+	case "h264baseline":
+		return "42C0"
+	case "h264main":
+		return "4D40"
+	default:
+		return "6400"
+	}
+}
+
+// IsSupportedCodec returns whether the codec can be selected for the
+// renditions. Only the ones that can be carried by the MPEG-TS segments of the
+// HLS renditions are.
+func IsSupportedCodec(codec string) bool {
+	_, ok := videoCodecs[EncodedProfile{Codec: codec}.VideoCodec()]
+	return ok
+}
+
+// VideoCodec returns the codec of the rendition, H264 unless another one was selected.
+func (p EncodedProfile) VideoCodec() string {
+	if p.Codec == "" {
+		return CodecH264
+	}
+	return strings.ToLower(p.Codec)
+}
+
+// RenditionCodecs returns the value of the CODECS attribute of the rendition
+// in the HLS master playlist, with the level picked from its resolution and
+// frame rate. sourceFPS is used for profiles keeping the frame rate of the
+// source. The audio codec is added when the rendition has an audio track.
+func RenditionCodecs(profile EncodedProfile, sourceFPS float64, hasAudio bool) string {
+	codec, ok := videoCodecs[profile.VideoCodec()]
+	if !ok || len(codec.levels) == 0 {
+		return ""
+	}
+	fps := sourceFPS
+	if profile.FPS > 0 {
+		fps = float64(profile.FPS)
+		if profile.FPSDen > 0 {
+			fps /= float64(profile.FPSDen)
+		}
+	}
+	if fps <= 0 {
+		fps = defaultFPS
+	}
+	pictureSize := profile.Width * profile.Height
+	sampleRate := int64(float64(pictureSize) * fps)
+
+	// use the highest level when the rendition exceeds all of them
+	level := codec.levels[len(codec.levels)-1]
+	for _, l := range codec.levels {
+		if pictureSize <= l.maxPictureSize && sampleRate <= l.maxSampleRate {
+			level = l
+			break
+		}
+	}
+	codecs := codec.codecString(profile.Profile, level)
+	if hasAudio {
+		codecs += "," + AACCodecs
+	}
+	return codecs
+}
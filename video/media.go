@@ -1,6 +1,10 @@
 package video
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 )
@@ -24,37 +28,89 @@ import (
    renditions returned by the T. It maps the rendition name to the
    list of segments referenced by the inner map above.
 
+   The segment data is spooled to one file per segment in a local
+   directory rather than kept in memory, so that the renditions of
+   long videos can be muxed with a bounded memory footprint.
+
    Since parallel jobs are used to transcode, all r/w accesses to
    these structs are protected to allow for atomic ops.
 */
 
 type TSegmentList struct {
-	mu               sync.Mutex
-	SegmentDataTable map[int][]byte
+	mu  sync.Mutex
+	dir string
+	// size of the segments written to dir, by segment index
+	segmentSizes map[int]int64
+}
+
+// NewTSegmentList returns a list spooling the segments to files in dir, which must exist
+func NewTSegmentList(dir string) *TSegmentList {
+	return &TSegmentList{dir: dir, segmentSizes: make(map[int]int64)}
+}
+
+func (s *TSegmentList) segmentFile(segIdx int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.ts", segIdx))
 }
 
-func (s *TSegmentList) AddSegmentData(segIdx int, data []byte) {
+// AddSegmentData writes the segment to disk, replacing any previous data for the same index
+func (s *TSegmentList) AddSegmentData(segIdx int, data []byte) error {
+	// segments with different indexes can be written in parallel
+	if err := os.WriteFile(s.segmentFile(segIdx), data, 0600); err != nil {
+		return fmt.Errorf("error spooling segment %d: %w", segIdx, err)
+	}
 	s.mu.Lock()
-	s.SegmentDataTable[segIdx] = data
+	s.segmentSizes[segIdx] = int64(len(data))
 	s.mu.Unlock()
+	return nil
 }
 
-func (s *TSegmentList) GetSegment(segIdx int) []byte {
+func (s *TSegmentList) GetSegment(segIdx int) ([]byte, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.SegmentDataTable[segIdx]
+	_, ok := s.segmentSizes[segIdx]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("segment %d not found", segIdx)
+	}
+	return os.ReadFile(s.segmentFile(segIdx))
 }
 
 func (s *TSegmentList) GetSortedSegments() []int {
-	segmentsTable := s.SegmentDataTable
-	segments := make([]int, 0, len(segmentsTable))
-	for k := range segmentsTable {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	segments := make([]int, 0, len(s.segmentSizes))
+	for k := range s.segmentSizes {
 		segments = append(segments, k)
 	}
 	sort.Ints(segments)
 	return segments
 }
 
+// Len returns the number of segments in the list
+func (s *TSegmentList) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segmentSizes)
+}
+
+// WriteTo writes all the segments in ascending order, reading one segment
+// file at a time.
+func (s *TSegmentList) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, k := range s.GetSortedSegments() {
+		f, err := os.Open(s.segmentFile(k))
+		if err != nil {
+			return total, fmt.Errorf("error opening segment %d: %w", k, err)
+		}
+		n, err := io.Copy(w, f)
+		f.Close()
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("error writing segment %d: %w", k, err)
+		}
+	}
+	return total, nil
+}
+
 type TRenditionList struct {
 	mu                    sync.Mutex
 	RenditionSegmentTable map[string]*TSegmentList
@@ -76,9 +132,17 @@ type RenditionStats struct {
 	Name             string
 	Width            int64
 	Height           int64
-	FPS              int64
+	FPS              float64
 	Bytes            int64
 	DurationMs       float64
 	ManifestLocation string
-	BitsPerSecond    uint32
+	// Average bitrate over all the segments
+	BitsPerSecond uint32
+	// Bitrate of the largest segment, relative to its duration
+	PeakBitsPerSecond uint32
+	// CODECS attribute of the rendition in the master playlist, if known
+	Codecs string
+	// Only set for alternate audio renditions
+	Language string
+	Title    string
 }
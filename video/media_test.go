@@ -0,0 +1,54 @@
+package video
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentListSpoolsSegmentsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	segments := NewTSegmentList(dir)
+
+	// segments can be added out of order and replaced
+	require.NoError(t, segments.AddSegmentData(2, []byte("two")))
+	require.NoError(t, segments.AddSegmentData(0, []byte("zero")))
+	require.NoError(t, segments.AddSegmentData(1, []byte("one?")))
+	require.NoError(t, segments.AddSegmentData(1, []byte("one")))
+
+	require.Equal(t, 3, segments.Len())
+	require.Equal(t, []int{0, 1, 2}, segments.GetSortedSegments())
+
+	data, err := os.ReadFile(filepath.Join(dir, "1.ts"))
+	require.NoError(t, err)
+	require.Equal(t, "one", string(data))
+	data, err = segments.GetSegment(2)
+	require.NoError(t, err)
+	require.Equal(t, "two", string(data))
+	_, err = segments.GetSegment(3)
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	n, err := segments.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), n)
+	require.Equal(t, "zeroonetwo", buf.String())
+}
+
+func TestConcatTS(t *testing.T) {
+	dir := t.TempDir()
+	segments := NewTSegmentList(dir)
+	require.NoError(t, segments.AddSegmentData(1, []byte("world")))
+	require.NoError(t, segments.AddSegmentData(0, []byte("hello ")))
+
+	concatFile := filepath.Join(dir, "concat.ts")
+	n, err := ConcatTS(concatFile, segments)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), n)
+	data, err := os.ReadFile(concatFile)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", string(data))
+}
@@ -0,0 +1,263 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	DefaultPerTitleMinBitrate = MinVideoBitrate
+	DefaultPerTitleMaxBitrate = 12_000_000
+
+	// Constant quality of the trial encodes, the bitrate they need is the
+	// complexity of the content
+	perTitleCRF = 23
+	// Height of the trial encodes, or the source height when it is lower
+	perTitleReferenceHeight = 720
+	perTitleSamples         = 3
+	perTitleSampleSecs      = 4.0
+	// Each rung needs at least this much more bitrate than the one below it
+	perTitleMinBitrateStep = 1.5
+	// How the bitrate needed for the same quality grows with the pixel count
+	perTitleBitrateExponent = 0.75
+)
+
+// Heights of the rungs below the source resolution that the ladder can use
+var perTitleHeights = []int64{2160, 1440, 1080, 720, 480, 360, 240}
+
+// PerTitleOptions enables the content-aware ABR ladder, with the bitrate of
+// every rung kept within the bounds.
+type PerTitleOptions struct {
+	MinBitrate int64 `json:"min_bitrate,omitempty"`
+	MaxBitrate int64 `json:"max_bitrate,omitempty"`
+}
+
+func (o PerTitleOptions) WithDefaults() PerTitleOptions {
+	if o.MinBitrate <= 0 {
+		o.MinBitrate = DefaultPerTitleMinBitrate
+	}
+	if o.MaxBitrate <= 0 {
+		o.MaxBitrate = DefaultPerTitleMaxBitrate
+	}
+	if o.MaxBitrate < o.MinBitrate {
+		o.MaxBitrate = o.MinBitrate
+	}
+	return o
+}
+
+// Complexity is the bitrate a constant quality encode of the content needs
+// at the given resolution.
+type Complexity struct {
+	Width   int64
+	Height  int64
+	Bitrate int64
+}
+
+// MeasureComplexity runs fast CRF trial encodes of a few samples spread over
+// the source file, scaled to the reference resolution, and returns their
+// average bitrate.
+func MeasureComplexity(ctx context.Context, sourceFile string, video InputTrack, durationSecs float64) (Complexity, error) {
+	if video.Width <= 0 || video.Height <= 0 {
+		return Complexity{}, fmt.Errorf("unknown source resolution %dx%d", video.Width, video.Height)
+	}
+	if durationSecs <= 0 {
+		return Complexity{}, fmt.Errorf("unknown source duration")
+	}
+	var samples []complexitySample
+	for _, sample := range complexitySamples(durationSecs) {
+		samples = append(samples, complexitySample{
+			inputFile:    sourceFile,
+			inputArgs:    ffmpeg.KwArgs{"ss": sample[0], "t": sample[1]},
+			durationSecs: sample[1],
+		})
+	}
+	return measureComplexity(ctx, video, samples)
+}
+
+// MeasureSegmentsComplexity is MeasureComplexity for sources that are already
+// segmented: the samples are whole source segments, with their durations.
+func MeasureSegmentsComplexity(ctx context.Context, segmentFiles []string, durationsSecs []float64, video InputTrack) (Complexity, error) {
+	if video.Width <= 0 || video.Height <= 0 {
+		return Complexity{}, fmt.Errorf("unknown source resolution %dx%d", video.Width, video.Height)
+	}
+	var samples []complexitySample
+	for i, file := range segmentFiles {
+		if durationsSecs[i] <= 0 {
+			return Complexity{}, fmt.Errorf("unknown duration of segment %d", i)
+		}
+		samples = append(samples, complexitySample{inputFile: file, durationSecs: durationsSecs[i]})
+	}
+	if len(samples) == 0 {
+		return Complexity{}, fmt.Errorf("no segments to measure")
+	}
+	return measureComplexity(ctx, video, samples)
+}
+
+type complexitySample struct {
+	inputFile    string
+	inputArgs    ffmpeg.KwArgs
+	durationSecs float64
+}
+
+func measureComplexity(ctx context.Context, video InputTrack, samples []complexitySample) (Complexity, error) {
+	dir, err := os.MkdirTemp(os.TempDir(), "complexity-*")
+	if err != nil {
+		return Complexity{}, fmt.Errorf("failed to create temp dir for the trial encodes: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	height := nearestEven(video.Height)
+	if height > perTitleReferenceHeight {
+		height = perTitleReferenceHeight
+	}
+	width := scaledWidth(video, height)
+
+	var totalBits, totalSecs float64
+	for i, sample := range samples {
+		file := filepath.Join(dir, fmt.Sprintf("sample-%d.h264", i))
+		var inputArgs []ffmpeg.KwArgs
+		if sample.inputArgs != nil {
+			inputArgs = append(inputArgs, sample.inputArgs)
+		}
+		stream := ffmpeg.Input(sample.inputFile, inputArgs...).
+			Output(file, trialEncodeArgs(width, height))
+		// ffmpeg gets killed if the context is cancelled
+		stream.Context = ctx
+		if err := stream.OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+			return Complexity{}, fmt.Errorf("failed to run trial encode %d: %w", i, err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return Complexity{}, fmt.Errorf("failed to read trial encode %d: %w", i, err)
+		}
+		totalBits += float64(info.Size()) * 8
+		totalSecs += sample.durationSecs
+	}
+	return Complexity{
+		Width:   width,
+		Height:  height,
+		Bitrate: int64(totalBits / totalSecs),
+	}, nil
+}
+
+// ComplexitySegments returns the indexes of the source segments to use as
+// samples, the middle segment of each part of the source when there are more
+// segments than samples.
+func ComplexitySegments(numSegments int) []int {
+	if numSegments <= perTitleSamples {
+		indexes := make([]int, numSegments)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes
+	}
+	var indexes []int
+	for i := 0; i < perTitleSamples; i++ {
+		indexes = append(indexes, int(float64(numSegments)*(float64(i)+0.5)/perTitleSamples))
+	}
+	return indexes
+}
+
+// complexitySamples returns the start time and duration of the samples to
+// encode, the whole file for short ones.
+func complexitySamples(durationSecs float64) [][2]float64 {
+	if durationSecs <= perTitleSamples*perTitleSampleSecs {
+		return [][2]float64{{0, durationSecs}}
+	}
+	var samples [][2]float64
+	for i := 0; i < perTitleSamples; i++ {
+		middle := durationSecs * (float64(i) + 0.5) / perTitleSamples
+		samples = append(samples, [2]float64{middle - perTitleSampleSecs/2, perTitleSampleSecs})
+	}
+	return samples
+}
+
+func trialEncodeArgs(width, height int64) ffmpeg.KwArgs {
+	return ffmpeg.KwArgs{
+		"an":     "",
+		"sn":     "",
+		"c:v":    "libx264",
+		"preset": "veryfast",
+		"crf":    perTitleCRF,
+		"vf":     fmt.Sprintf("scale=%d:%d", width, height),
+		// the raw stream, so that the size doesn't include any muxing overhead
+		"f": "h264",
+	}
+}
+
+// PerTitleProfiles builds an ABR ladder tuned to the complexity of the
+// content. The top rung keeps the source resolution and every rung below it
+// is the highest resolution needing a bitrate at least perTitleMinBitrateStep
+// times lower, so that simple content gets fewer rungs at higher resolutions.
+func PerTitleProfiles(video InputTrack, complexity Complexity, opts PerTitleOptions) ([]EncodedProfile, error) {
+	if video.Width <= 0 || video.Height <= 0 {
+		return nil, fmt.Errorf("unknown source resolution %dx%d", video.Width, video.Height)
+	}
+	if complexity.Width <= 0 || complexity.Height <= 0 || complexity.Bitrate <= 0 {
+		return nil, fmt.Errorf("invalid complexity %+v", complexity)
+	}
+	opts = opts.WithDefaults()
+	height := nearestEven(video.Height)
+	width := nearestEven(video.Width)
+
+	needed := func(width, height int64) float64 {
+		ratio := float64(width*height) / float64(complexity.Width*complexity.Height)
+		return float64(complexity.Bitrate) * math.Pow(ratio, perTitleBitrateExponent)
+	}
+	// never spend more than the source on a rung
+	maxBitrate := opts.MaxBitrate
+	if video.Bitrate > 0 && video.Bitrate < maxBitrate {
+		maxBitrate = video.Bitrate
+	}
+	// the whole ladder is scaled down when the top rung needs more than the
+	// max, so that the rungs below it stay evenly spread
+	scale := 1.0
+	if top := needed(width, height); top > float64(maxBitrate) {
+		scale = float64(maxBitrate) / top
+	}
+	bitrate := func(width, height int64) int64 {
+		b := int64(needed(width, height) * scale)
+		if b < opts.MinBitrate {
+			b = opts.MinBitrate
+		}
+		if b > maxBitrate {
+			b = maxBitrate
+		}
+		return b
+	}
+
+	top := EncodedProfile{
+		Name:    fmt.Sprintf("%dp0", height),
+		Width:   width,
+		Height:  height,
+		Bitrate: bitrate(width, height),
+	}
+	profiles := []EncodedProfile{top}
+	for _, h := range perTitleHeights {
+		if h >= height {
+			continue
+		}
+		w := scaledWidth(video, h)
+		b := bitrate(w, h)
+		if float64(b)*perTitleMinBitrateStep > float64(profiles[0].Bitrate) {
+			continue
+		}
+		profiles = append([]EncodedProfile{{
+			Name:    fmt.Sprintf("%dp0", h),
+			Width:   w,
+			Height:  h,
+			Bitrate: b,
+		}}, profiles...)
+	}
+	return profiles, nil
+}
+
+// scaledWidth returns the width keeping the aspect ratio of the source at the given height
+func scaledWidth(video InputTrack, height int64) int64 {
+	return nearestEven(int64(math.Round(float64(height) * float64(video.Width) / float64(video.Height))))
+}
@@ -0,0 +1,106 @@
+package video
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerTitleProfiles(t *testing.T) {
+	source1080p := InputTrack{
+		Type:       "video",
+		Bitrate:    10_000_000,
+		VideoTrack: VideoTrack{Width: 1920, Height: 1080},
+	}
+	tests := []struct {
+		name       string
+		track      InputTrack
+		complexity Complexity
+		opts       PerTitleOptions
+		want       []EncodedProfile
+	}{
+		{
+			name:       "average content",
+			track:      source1080p,
+			complexity: Complexity{Width: 1280, Height: 720, Bitrate: 2_000_000},
+			want: []EncodedProfile{
+				{Name: "240p0", Width: 428, Height: 240, Bitrate: 385_801},
+				{Name: "360p0", Width: 640, Height: 360, Bitrate: 707_106},
+				{Name: "480p0", Width: 854, Height: 480, Bitrate: 1_089_299},
+				{Name: "720p0", Width: 1280, Height: 720, Bitrate: 2_000_000},
+				{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 3_674_234},
+			},
+		},
+		{
+			name:       "simple content gets fewer rungs",
+			track:      source1080p,
+			complexity: Complexity{Width: 1280, Height: 720, Bitrate: 300_000},
+			want: []EncodedProfile{
+				{Name: "360p0", Width: 640, Height: 360, Bitrate: 106_066},
+				{Name: "480p0", Width: 854, Height: 480, Bitrate: 163_394},
+				{Name: "720p0", Width: 1280, Height: 720, Bitrate: 300_000},
+				{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 551_135},
+			},
+		},
+		{
+			name:       "complex content is scaled down to the source bitrate",
+			track:      source1080p,
+			complexity: Complexity{Width: 1280, Height: 720, Bitrate: 30_000_000},
+			want: []EncodedProfile{
+				{Name: "240p0", Width: 428, Height: 240, Bitrate: 1_050_019},
+				{Name: "360p0", Width: 640, Height: 360, Bitrate: 1_924_500},
+				{Name: "480p0", Width: 854, Height: 480, Bitrate: 2_964_698},
+				{Name: "720p0", Width: 1280, Height: 720, Bitrate: 5_443_310},
+				{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 10_000_000},
+			},
+		},
+		{
+			name: "min bitrate",
+			track: InputTrack{
+				Type:       "video",
+				VideoTrack: VideoTrack{Width: 640, Height: 360},
+			},
+			complexity: Complexity{Width: 640, Height: 360, Bitrate: 500_000},
+			opts:       PerTitleOptions{MinBitrate: 250_000},
+			want: []EncodedProfile{
+				{Name: "240p0", Width: 428, Height: 240, Bitrate: 272_803},
+				{Name: "360p0", Width: 640, Height: 360, Bitrate: 500_000},
+			},
+		},
+		{
+			name:       "max bitrate",
+			track:      source1080p,
+			complexity: Complexity{Width: 1280, Height: 720, Bitrate: 2_000_000},
+			opts:       PerTitleOptions{MinBitrate: 500_000, MaxBitrate: 2_000_000},
+			want: []EncodedProfile{
+				{Name: "480p0", Width: 854, Height: 480, Bitrate: 592_939},
+				{Name: "720p0", Width: 1280, Height: 720, Bitrate: 1_088_662},
+				{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 2_000_000},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PerTitleProfiles(tt.track, tt.complexity, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPerTitleProfilesNeedsAResolution(t *testing.T) {
+	_, err := PerTitleProfiles(InputTrack{Type: "video"}, Complexity{Width: 1280, Height: 720, Bitrate: 1}, PerTitleOptions{})
+	require.Error(t, err)
+}
+
+func TestComplexitySamples(t *testing.T) {
+	require.Equal(t, [][2]float64{{0, 10}}, complexitySamples(10))
+	require.Equal(t, [][2]float64{{8, 4}, {28, 4}, {48, 4}}, complexitySamples(60))
+}
+
+func TestComplexitySegments(t *testing.T) {
+	require.Equal(t, []int{}, ComplexitySegments(0))
+	require.Equal(t, []int{0, 1}, ComplexitySegments(2))
+	require.Equal(t, []int{0, 1, 2}, ComplexitySegments(3))
+	require.Equal(t, []int{1, 5, 8}, ComplexitySegments(10))
+}
@@ -68,9 +68,12 @@ func (p Probe) runProbe(url string, ffProbeOptions ...string) (iv InputVideo, er
 
 func parseProbeOutput(probeData *ffprobe.ProbeData) (InputVideo, error) {
 	// check for a valid video stream
-	videoStream := probeData.FirstVideoStream()
+	videoStream := firstVideoStream(probeData)
 	if videoStream == nil {
-		return InputVideo{}, errors.New("error checking for video: no video stream found")
+		if probeData.FirstAudioStream() == nil {
+			return InputVideo{}, errors.New("error checking for video: no video or audio stream found")
+		}
+		return parseAudioOnlyProbeOutput(probeData)
 	}
 	// check for unsupported video stream(s)
 	for _, codec := range unsupportedVideoCodecList {
@@ -151,6 +154,7 @@ func parseProbeOutput(probeData *ffprobe.ProbeData) (InputVideo, error) {
 					Width:              int64(videoStream.Width),
 					Height:             int64(videoStream.Height),
 					FPS:                fps,
+					PixelFormat:        videoStream.PixFmt,
 					Rotation:           rotation,
 					DisplayAspectRatio: videoStream.DisplayAspectRatio,
 				},
@@ -159,30 +163,101 @@ func parseProbeOutput(probeData *ffprobe.ProbeData) (InputVideo, error) {
 		Duration:  duration,
 		SizeBytes: size,
 	}
-	iv = addAudioTrack(probeData, iv)
+	iv = addAudioTracks(probeData, iv)
+	iv = addSubtitleTracks(probeData, iv)
 
 	return iv, nil
 }
 
-func addAudioTrack(probeData *ffprobe.ProbeData, iv InputVideo) InputVideo {
-	audioTrack := probeData.FirstAudioStream()
-	if audioTrack == nil {
-		return iv
-	}
-	bitrate, _ := strconv.ParseInt(audioTrack.BitRate, 10, 64)
-	iv.Tracks = append(iv.Tracks, InputTrack{
-		Type:    TrackTypeAudio,
-		Codec:   audioTrack.CodecName,
-		Bitrate: bitrate,
-		AudioTrack: AudioTrack{
-			Channels:   audioTrack.Channels,
-			SampleBits: audioTrack.BitsPerSample,
-		},
+// firstVideoStream returns the first video stream that isn't an attached
+// picture, like the cover art of music files.
+func firstVideoStream(probeData *ffprobe.ProbeData) *ffprobe.Stream {
+	for _, stream := range probeData.StreamType(ffprobe.StreamVideo) {
+		if stream.Disposition.AttachedPic == 0 {
+			s := stream
+			return &s
+		}
+	}
+	return nil
+}
+
+// parseAudioOnlyProbeOutput handles the inputs without any video, like
+// podcasts or music uploads, which are transcoded into audio renditions only.
+func parseAudioOnlyProbeOutput(probeData *ffprobe.ProbeData) (InputVideo, error) {
+	if probeData.Format == nil {
+		return InputVideo{}, fmt.Errorf("error parsing input audio: format information missing")
+	}
+	size, err := strconv.ParseInt(probeData.Format.Size, 10, 64)
+	if err != nil {
+		return InputVideo{}, fmt.Errorf("error parsing filesize from probed data: %w", err)
+	}
+	audioStream := probeData.FirstAudioStream()
+	duration, err := strconv.ParseFloat(audioStream.Duration, 64)
+	if err != nil {
+		duration = probeData.Format.DurationSeconds
+	}
+	iv := addAudioTracks(probeData, InputVideo{
+		Format:    probeData.Format.FormatName,
+		Duration:  duration,
+		SizeBytes: size,
 	})
+	// the audio track is all there is to describe the input, so fill in what
+	// would otherwise come from the video track
+	track := &iv.Tracks[0]
+	track.DurationSec = duration
+	track.SampleRate, _ = strconv.Atoi(audioStream.SampleRate)
+	if track.Bitrate == 0 {
+		// the container bitrate is the audio bitrate when there's no other track
+		track.Bitrate, _ = strconv.ParseInt(probeData.Format.BitRate, 10, 64)
+	}
+	return iv, nil
+}
+
+// addAudioTracks adds all the audio streams of the input, with the language
+// and title tags of multilingual content.
+func addAudioTracks(probeData *ffprobe.ProbeData, iv InputVideo) InputVideo {
+	for _, audioTrack := range probeData.StreamType(ffprobe.StreamAudio) {
+		bitrate, _ := strconv.ParseInt(audioTrack.BitRate, 10, 64)
+		language, title := streamLanguage(audioTrack)
+		iv.Tracks = append(iv.Tracks, InputTrack{
+			Type:     TrackTypeAudio,
+			Codec:    audioTrack.CodecName,
+			Bitrate:  bitrate,
+			Language: language,
+			Title:    title,
+			AudioTrack: AudioTrack{
+				Channels:   audioTrack.Channels,
+				SampleBits: audioTrack.BitsPerSample,
+			},
+		})
+	}
 
 	return iv
 }
 
+// addSubtitleTracks adds the embedded subtitle streams of the input.
+func addSubtitleTracks(probeData *ffprobe.ProbeData, iv InputVideo) InputVideo {
+	for _, subtitleTrack := range probeData.StreamType(ffprobe.StreamSubtitle) {
+		language, title := streamLanguage(subtitleTrack)
+		iv.Tracks = append(iv.Tracks, InputTrack{
+			Type:     TrackTypeSubtitle,
+			Codec:    subtitleTrack.CodecName,
+			Language: language,
+			Title:    title,
+		})
+	}
+	return iv
+}
+
+func streamLanguage(stream ffprobe.Stream) (string, string) {
+	language, _ := stream.TagList.GetString("language")
+	title, _ := stream.TagList.GetString("title")
+	if language == "und" {
+		language = ""
+	}
+	return language, title
+}
+
 // function taken from task-runner task/probe.go
 func parseFps(framerate string) (float64, error) {
 	if framerate == "" {
@@ -7,15 +7,62 @@ import (
 	"gopkg.in/vansante/go-ffprobe.v2"
 )
 
-func TestItRejectsWhenNoVideoTrackPresent(t *testing.T) {
+func TestItRejectsWhenNoVideoOrAudioTrackPresent(t *testing.T) {
 	_, err := parseProbeOutput(&ffprobe.ProbeData{
 		Streams: []*ffprobe.Stream{
 			{
-				CodecType: "audio",
+				CodecType: "subtitle",
 			},
 		},
 	})
-	require.ErrorContains(t, err, "no video stream found")
+	require.ErrorContains(t, err, "no video or audio stream found")
+}
+
+func TestItKeepsAllAudioTracks(t *testing.T) {
+	iv, err := parseProbeOutput(&ffprobe.ProbeData{
+		Streams: []*ffprobe.Stream{
+			{CodecType: "video", CodecName: "h264", BitRate: "1000000"},
+			{CodecType: "audio", CodecName: "aac", TagList: ffprobe.Tags{"language": "eng", "title": "English"}},
+			{CodecType: "audio", CodecName: "ac3", TagList: ffprobe.Tags{"language": "und"}},
+		},
+		Format: &ffprobe.Format{Size: "1000"},
+	})
+	require.NoError(t, err)
+	audioTracks := iv.AudioTracks()
+	require.Len(t, audioTracks, 2)
+	require.Equal(t, "eng", audioTracks[0].Language)
+	require.Equal(t, "English", audioTracks[0].Title)
+	require.Equal(t, "ac3", audioTracks[1].Codec)
+	require.Empty(t, audioTracks[1].Language)
+}
+
+func TestItAcceptsAudioOnlyInputs(t *testing.T) {
+	iv, err := parseProbeOutput(&ffprobe.ProbeData{
+		Streams: []*ffprobe.Stream{
+			{
+				CodecType:  "audio",
+				CodecName:  "mp3",
+				Channels:   2,
+				SampleRate: "44100",
+			},
+		},
+		Format: &ffprobe.Format{
+			FormatName:      "mp3",
+			Size:            "1234567",
+			BitRate:         "192000",
+			DurationSeconds: 51.4,
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, iv.IsAudioOnly())
+	require.Equal(t, 51.4, iv.Duration)
+	require.Equal(t, []InputTrack{{
+		Type:        TrackTypeAudio,
+		Codec:       "mp3",
+		Bitrate:     192000,
+		DurationSec: 51.4,
+		AudioTrack:  AudioTrack{Channels: 2, SampleRate: 44100},
+	}}, iv.Tracks)
 }
 
 func TestItRejectsWhenMJPEGVideoTrackPresent(t *testing.T) {
@@ -84,9 +131,10 @@ func TestProbe(t *testing.T) {
 				Codec:   "h264",
 				Bitrate: 1234521,
 				VideoTrack: VideoTrack{
-					Width:  576,
-					Height: 1024,
-					FPS:    30,
+					Width:       576,
+					Height:      1024,
+					FPS:         30,
+					PixelFormat: "yuv420p",
 				},
 			},
 			{
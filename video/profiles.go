@@ -3,6 +3,7 @@ package video
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -35,6 +36,35 @@ func (i InputVideo) GetTrack(trackType string) (InputTrack, error) {
 	return InputTrack{}, fmt.Errorf("no '%s' tracks found", trackType)
 }
 
+// IsAudioOnly returns whether the input has an audio track but no video, e.g.
+// podcasts and music uploads.
+func (i InputVideo) IsAudioOnly() bool {
+	_, videoErr := i.GetTrack(TrackTypeVideo)
+	_, audioErr := i.GetTrack(TrackTypeAudio)
+	return videoErr != nil && audioErr == nil
+}
+
+// AudioTracks returns all the audio tracks of the input, in stream order.
+func (i InputVideo) AudioTracks() []InputTrack {
+	return i.tracksOfType(TrackTypeAudio)
+}
+
+// SubtitleTracks returns all the embedded subtitle tracks of the input, in
+// stream order.
+func (i InputVideo) SubtitleTracks() []InputTrack {
+	return i.tracksOfType(TrackTypeSubtitle)
+}
+
+func (i InputVideo) tracksOfType(trackType string) []InputTrack {
+	var tracks []InputTrack
+	for _, t := range i.Tracks {
+		if t.Type == trackType {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
+
 type VideoTrack struct {
 	Width              int64   `json:"width,omitempty"`
 	Height             int64   `json:"height,omitempty"`
@@ -57,6 +87,9 @@ type InputTrack struct {
 	DurationSec  float64 `json:"duration"`
 	SizeBytes    int64   `json:"size"`
 	StartTimeSec float64 `json:"start_time"`
+	// Language and title tags of audio and subtitle tracks
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
 
 	// Fields only used if this is a Video Track
 	VideoTrack
@@ -83,6 +116,89 @@ var DefaultProfile720p = EncodedProfile{
 // DefaultTranscodeProfiles defines the default set of encoding profiles to use when none are specified
 var DefaultTranscodeProfiles = []EncodedProfile{DefaultProfile360p, DefaultProfile720p}
 
+// DefaultAudioProfiles are the AAC renditions of audio-only inputs, lowest bitrate first
+var DefaultAudioProfiles = []EncodedProfile{
+	{Name: "audio-64k", Bitrate: 64_000},
+	{Name: "audio-128k", Bitrate: 128_000},
+}
+
+// CODECS attribute of the AAC-LC renditions
+const AACCodecs = "mp4a.40.2"
+
+// Bitrate of the AAC renditions of each audio track of multi-audio inputs
+const AudioRenditionBitrate = 128_000
+
+// AudioRendition is an alternate audio rendition of the output, transcoded
+// from one of the audio tracks of the input.
+type AudioRendition struct {
+	Name     string
+	Language string
+	Title    string
+	Bitrate  int64
+	// Index of the track among the audio tracks of the input
+	TrackIndex int
+}
+
+// GetAudioRenditions returns one rendition per audio track for inputs with
+// several of them, e.g. multilingual content. Inputs with a single audio track
+// keep it muxed in the video renditions only.
+func GetAudioRenditions(iv InputVideo) []AudioRendition {
+	tracks := iv.AudioTracks()
+	if len(tracks) < 2 {
+		return nil
+	}
+	var renditions []AudioRendition
+	for i, track := range tracks {
+		name := fmt.Sprintf("audio-%d", i)
+		// the language comes from the input file, and the name ends up in the output paths
+		if language := SanitizeRenditionName(track.Language); language != "" {
+			name += "-" + language
+		}
+		bitrate := int64(AudioRenditionBitrate)
+		if track.Bitrate > 0 && track.Bitrate < bitrate {
+			bitrate = track.Bitrate
+		}
+		renditions = append(renditions, AudioRendition{
+			Name:       name,
+			Language:   track.Language,
+			Title:      track.Title,
+			Bitrate:    bitrate,
+			TrackIndex: i,
+		})
+	}
+	return renditions
+}
+
+// SanitizeRenditionName keeps only the characters allowed in the names of the
+// renditions, which are used as directory names in the output: letters, digits
+// and dashes.
+func SanitizeRenditionName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// GetAudioPlaybackProfiles returns the renditions of an audio-only input. The
+// renditions with a higher bitrate than the source are skipped, apart from the
+// lowest one which is always kept.
+func GetAudioPlaybackProfiles(iv InputVideo) ([]EncodedProfile, error) {
+	audio, err := iv.GetTrack(TrackTypeAudio)
+	if err != nil {
+		return nil, fmt.Errorf("no audio track found in input: %w", err)
+	}
+	profiles := []EncodedProfile{DefaultAudioProfiles[0]}
+	for _, profile := range DefaultAudioProfiles[1:] {
+		// the bitrate isn't always known, e.g. for HLS inputs
+		if audio.Bitrate == 0 || profile.Bitrate <= audio.Bitrate {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles, nil
+}
+
 func GetPlaybackProfiles(iv InputVideo) ([]EncodedProfile, error) {
 	video, err := iv.GetTrack(TrackTypeVideo)
 	if err != nil {
@@ -150,6 +266,8 @@ type EncodedProfile struct {
 	Encoder      string `json:"encoder,omitempty"`
 	ColorDepth   int64  `json:"colorDepth,omitempty"`
 	ChromaFormat int64  `json:"chromaFormat,omitempty"`
+	// One of the Codec* constants, H264 when empty
+	Codec string `json:"codec,omitempty"`
 }
 
 type OutputVideo struct {
@@ -157,6 +275,31 @@ type OutputVideo struct {
 	Manifest   string            `json:"manifest,omitempty"`
 	Videos     []OutputVideoFile `json:"videos"`
 	MP4Outputs []OutputVideoFile `json:"mp4_outputs,omitempty"`
+	// Alternate audio renditions, one per audio track of multi-audio inputs
+	AudioTracks []OutputAudioTrack `json:"audio_tracks,omitempty"`
+	// WebVTT subtitles renditions, from sidecar files and embedded captions
+	Subtitles []OutputTextTrack `json:"subtitles,omitempty"`
+	// Sprite sheets, WebVTT thumbnails track and poster image
+	Thumbnails []OutputVideoFile `json:"thumbnails,omitempty"`
+	// HLS master playlist and DASH manifest of the CMAF fMP4 segments
+	CMAFManifest string `json:"cmaf_manifest,omitempty"`
+	DASHManifest string `json:"dash_manifest,omitempty"`
+}
+
+type OutputTextTrack struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Label    string `json:"label,omitempty"`
+	Location string `json:"location"`
+}
+
+type OutputAudioTrack struct {
+	Name      string `json:"name"`
+	Language  string `json:"language,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Location  string `json:"location"`
+	SizeBytes int64  `json:"size,omitempty"`
+	Bitrate   int64  `json:"bitrate,omitempty"`
 }
 
 type OutputVideoFile struct {
@@ -174,6 +317,11 @@ func PopulateOutput(requestID string, probe Prober, outputURL string, videoFile
 		return OutputVideoFile{}, fmt.Errorf("error probing output file from S3: %w", err)
 	}
 	videoFile.SizeBytes = outputVideoProbe.SizeBytes
+	if outputVideoProbe.IsAudioOnly() {
+		audioTrack, _ := outputVideoProbe.GetTrack(TrackTypeAudio)
+		videoFile.Bitrate = audioTrack.Bitrate
+		return videoFile, nil
+	}
 	videoTrack, err := outputVideoProbe.GetTrack(TrackTypeVideo)
 	if err != nil {
 		return OutputVideoFile{}, fmt.Errorf("no video track found in output video: %w", err)
@@ -131,6 +131,53 @@ func TestGetPlaybackProfiles(t *testing.T) {
 	}
 }
 
+func TestGetAudioPlaybackProfiles(t *testing.T) {
+	audioInput := func(bitrate int64) InputVideo {
+		return InputVideo{Tracks: []InputTrack{{Type: TrackTypeAudio, Codec: "mp3", Bitrate: bitrate}}}
+	}
+
+	got, err := GetAudioPlaybackProfiles(audioInput(320_000))
+	require.NoError(t, err)
+	require.Equal(t, DefaultAudioProfiles, got)
+
+	got, err = GetAudioPlaybackProfiles(audioInput(96_000))
+	require.NoError(t, err)
+	require.Equal(t, []EncodedProfile{{Name: "audio-64k", Bitrate: 64_000}}, got)
+
+	got, err = GetAudioPlaybackProfiles(audioInput(0))
+	require.NoError(t, err)
+	require.Equal(t, DefaultAudioProfiles, got)
+
+	_, err = GetAudioPlaybackProfiles(InputVideo{})
+	require.Error(t, err)
+}
+
+func TestGetAudioRenditions(t *testing.T) {
+	input := InputVideo{Tracks: []InputTrack{
+		{Type: TrackTypeVideo, Codec: "h264"},
+		{Type: TrackTypeAudio, Codec: "aac", Bitrate: 192_000, Language: "eng", Title: "English"},
+		{Type: TrackTypeAudio, Codec: "aac", Bitrate: 96_000},
+	}}
+	require.Equal(t, []AudioRendition{
+		{Name: "audio-0-eng", Language: "eng", Title: "English", Bitrate: 128_000, TrackIndex: 0},
+		{Name: "audio-1", Bitrate: 96_000, TrackIndex: 1},
+	}, GetAudioRenditions(input))
+
+	// a single audio track stays muxed in the video renditions
+	input.Tracks = input.Tracks[:2]
+	require.Empty(t, GetAudioRenditions(input))
+
+	// the language from the file can't escape the output directory
+	input.Tracks = append(input.Tracks, InputTrack{Type: TrackTypeAudio, Codec: "aac", Language: "../../e n"})
+	require.Equal(t, "audio-1-en", GetAudioRenditions(input)[1].Name)
+}
+
+func TestSanitizeRenditionName(t *testing.T) {
+	require.Equal(t, "pt-BR", SanitizeRenditionName("pt-BR"))
+	require.Equal(t, "etcpasswd", SanitizeRenditionName("../etc/passwd"))
+	require.Equal(t, "", SanitizeRenditionName("日本"))
+}
+
 func TestPopulateOutput(t *testing.T) {
 	out, err := PopulateOutput("requestID", Probe{}, "fixtures/parametric-stereo-error.mp4", OutputVideoFile{})
 	require.NoError(t, err)
@@ -141,3 +188,21 @@ func TestPopulateOutput(t *testing.T) {
 		Bitrate:   315733,
 	}, out)
 }
+
+func TestRenditionCodecs(t *testing.T) {
+	profile := EncodedProfile{Name: "720p0", Width: 1280, Height: 720, FPS: 30}
+	require.Equal(t, "avc1.64001F,mp4a.40.2", RenditionCodecs(profile, 0, true))
+
+	// the level follows the frame rate of the source
+	require.Equal(t, "avc1.640020", RenditionCodecs(EncodedProfile{Width: 1280, Height: 720}, 60, false))
+
+	profile = EncodedProfile{Width: 640, Height: 360, Profile: "H264Baseline"}
+	require.Equal(t, "avc1.42C01E", RenditionCodecs(profile, 30, false))
+
+	profile = EncodedProfile{Width: 1920, Height: 1080, FPS: 30, Codec: CodecHEVC}
+	require.Equal(t, "hvc1.1.6.L120.B0,mp4a.40.2", RenditionCodecs(profile, 0, true))
+
+	require.True(t, IsSupportedCodec(""))
+	require.True(t, IsSupportedCodec(CodecHEVC))
+	require.False(t, IsSupportedCodec("av1"))
+}
@@ -1,6 +1,7 @@
 package video
 
 import (
+	"context"
 	"fmt"
 
 	ffmpeg "github.com/u2takey/ffmpeg-go"
@@ -11,22 +12,33 @@ import (
 // FFMPEG can use remote files, but depending on the layout of the file can get bogged
 // down and end up making multiple range requests per segment.
 // Because of this, we download first and then clean up at the end.
-func Segment(sourceFilename string, outputManifestURL string, targetSegmentSize int64) error {
+//
+// All the audio tracks are kept, for the alternate audio renditions of
+// multi-audio inputs. The video streams of audio-only inputs, like cover art,
+// are dropped.
+func Segment(ctx context.Context, sourceFilename string, outputManifestURL string, targetSegmentSize int64, audioOnly bool) error {
+	args := ffmpeg.KwArgs{
+		"c:a":               "copy",
+		"c:v":               "copy",
+		"f":                 "hls",
+		"hls_segment_type":  "mpegts",
+		"hls_playlist_type": "vod",
+		"hls_list_size":     "0",
+		"hls_time":          targetSegmentSize,
+		"method":            "PUT",
+		// "V" skips the attached pictures
+		"map": []string{"0:V:0?", "0:a?"},
+	}
+	if audioOnly {
+		delete(args, "c:v")
+		args["vn"] = ""
+		args["map"] = "0:a"
+	}
 	// Do the segmenting, using the local file as source
-	err := ffmpeg.Input(sourceFilename).
-		Output(
-			outputManifestURL,
-			ffmpeg.KwArgs{
-				"c:a":               "copy",
-				"c:v":               "copy",
-				"f":                 "hls",
-				"hls_segment_type":  "mpegts",
-				"hls_playlist_type": "vod",
-				"hls_list_size":     "0",
-				"hls_time":          targetSegmentSize,
-				"method":            "PUT",
-			},
-		).OverWriteOutput().ErrorToStdOut().Run()
+	stream := ffmpeg.Input(sourceFilename).Output(outputManifestURL, args)
+	// ffmpeg gets killed if the context is cancelled
+	stream.Context = ctx
+	err := stream.OverWriteOutput().ErrorToStdOut().Run()
 	if err != nil {
 		return fmt.Errorf("failed to segment source file (%s): %s", sourceFilename, err)
 	}
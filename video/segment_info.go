@@ -0,0 +1,95 @@
+package video
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/livepeer/joy4/codec/aacparser"
+	"github.com/livepeer/joy4/codec/h264parser"
+	"github.com/livepeer/joy4/format/ts"
+)
+
+// SegmentInfo is the media information read from an MPEG-TS segment
+type SegmentInfo struct {
+	Width  int64
+	Height int64
+	// Average frame rate of the segment, 0 if it has less than two frames
+	FPS float64
+	// RFC 6381 codec strings of the video and audio tracks, e.g.
+	// "avc1.64001F,mp4a.40.2". Empty for codecs that can't be parsed.
+	Codecs string
+}
+
+// ParseTSSegment reads the codecs, dimensions and frame rate of an MPEG-TS
+// segment from its headers and timestamps, without decoding it. Only H264
+// video and AAC audio are parsed, other tracks are ignored.
+func ParseTSSegment(data []byte) (SegmentInfo, error) {
+	var info SegmentInfo
+	demuxer := ts.NewDemuxer(bytes.NewReader(data))
+	streams, err := demuxer.Streams()
+	if err != nil {
+		return info, fmt.Errorf("failed to read segment streams: %w", err)
+	}
+
+	videoIdx := -1
+	var videoCodec, audioCodec string
+	for i, stream := range streams {
+		switch codec := stream.(type) {
+		case h264parser.CodecData:
+			if videoIdx != -1 {
+				continue
+			}
+			videoIdx = i
+			info.Width = int64(codec.Width())
+			info.Height = int64(codec.Height())
+			record := codec.RecordInfo
+			videoCodec = fmt.Sprintf("avc1.%02X%02X%02X", record.AVCProfileIndication, record.ProfileCompatibility, record.AVCLevelIndication)
+		case aacparser.CodecData:
+			if audioCodec == "" {
+				audioCodec = fmt.Sprintf("mp4a.40.%d", codec.Config.ObjectType)
+			}
+		}
+	}
+	var codecs []string
+	for _, c := range []string{videoCodec, audioCodec} {
+		if c != "" {
+			codecs = append(codecs, c)
+		}
+	}
+	info.Codecs = strings.Join(codecs, ",")
+	if videoIdx == -1 {
+		return info, nil
+	}
+
+	var frames int
+	var first, last time.Duration
+	for {
+		pkt, err := demuxer.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return info, fmt.Errorf("failed to read segment packets: %w", err)
+		}
+		if int(pkt.Idx) != videoIdx {
+			continue
+		}
+		if frames == 0 || pkt.Time < first {
+			first = pkt.Time
+		}
+		if frames == 0 || pkt.Time > last {
+			last = pkt.Time
+		}
+		frames++
+	}
+	if frames > 1 && last > first {
+		fps := float64(frames-1) / (last - first).Seconds()
+		info.FPS = math.Round(fps*1000) / 1000
+	}
+	return info, nil
+}
@@ -0,0 +1,27 @@
+package video
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTSSegment(t *testing.T) {
+	data, err := os.ReadFile("../test/fixtures/seg-0.ts")
+	require.NoError(t, err)
+
+	info, err := ParseTSSegment(data)
+	require.NoError(t, err)
+	require.Equal(t, SegmentInfo{
+		Width:  480,
+		Height: 270,
+		FPS:    30,
+		Codecs: "avc1.42C028,mp4a.40.2",
+	}, info)
+}
+
+func TestParseTSSegmentRejectsInvalidSegments(t *testing.T) {
+	_, err := ParseTSSegment(make([]byte, 1024))
+	require.Error(t, err)
+}
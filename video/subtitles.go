@@ -0,0 +1,232 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	TrackTypeSubtitle = "subtitle"
+
+	// X-TIMESTAMP-MAP of the WebVTT segments, mapping the start of the cues to
+	// the first timestamp written by the ffmpeg mpegts muxer (1.4s)
+	webVTTTimestampMap = "X-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000"
+)
+
+// textSubtitleCodecs are the embedded subtitle codecs that can be converted to
+// WebVTT. Bitmap subtitles (DVD, PGS) would need OCR.
+var textSubtitleCodecs = []string{"mov_text", "subrip", "srt", "webvtt", "ass", "ssa", "text"}
+
+// IsTextSubtitleCodec returns whether an embedded subtitle track can be
+// converted to WebVTT.
+func IsTextSubtitleCodec(codec string) bool {
+	for _, c := range textSubtitleCodecs {
+		if strings.EqualFold(c, codec) {
+			return true
+		}
+	}
+	return false
+}
+
+// CaptionFile is a sidecar SRT or WebVTT file of a VOD request.
+type CaptionFile struct {
+	URL      string `json:"url"`
+	Language string `json:"language,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// Subtitles holds the cues of one subtitles rendition of the output.
+type Subtitles struct {
+	Name     string
+	Language string
+	Label    string
+	Cues     []SubtitleCue
+}
+
+type SubtitleCue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// ParseSubtitles parses an SRT or a WebVTT file, detected from its content.
+func ParseSubtitles(data []byte) ([]SubtitleCue, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if bytes.HasPrefix(data, []byte("WEBVTT")) {
+		return ParseWebVTT(bytes.NewReader(data))
+	}
+	return ParseSRT(bytes.NewReader(data))
+}
+
+// ParseSRT parses a SubRip file. Cue numbers are ignored.
+func ParseSRT(r io.Reader) ([]SubtitleCue, error) {
+	return parseCues(r, ",")
+}
+
+// ParseWebVTT parses a WebVTT file. Cue settings, notes and styles are dropped.
+func ParseWebVTT(r io.Reader) ([]SubtitleCue, error) {
+	return parseCues(r, ".")
+}
+
+// parseCues reads the blocks of the file, separated by blank lines, keeping the
+// ones with a timing line. The text of a cue is made of the lines that follow.
+func parseCues(r io.Reader, millisSeparator string) ([]SubtitleCue, error) {
+	var cues []SubtitleCue
+	scanner := bufio.NewScanner(r)
+	var cue *SubtitleCue
+	var text []string
+	flush := func() {
+		if cue != nil && len(text) > 0 {
+			cue.Text = strings.Join(text, "\n")
+			cues = append(cues, *cue)
+		}
+		cue, text = nil, nil
+	}
+	for line := 1; scanner.Scan(); line++ {
+		l := strings.TrimRight(scanner.Text(), "\r")
+		if l == "" {
+			flush()
+			continue
+		}
+		if cue != nil {
+			text = append(text, l)
+			continue
+		}
+		if !strings.Contains(l, "-->") {
+			// header, cue identifier, note or style
+			continue
+		}
+		start, end, err := parseCueTiming(l, millisSeparator)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cue timing on line %d: %w", line, err)
+		}
+		cue = &SubtitleCue{Start: start, End: end}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading subtitles: %w", err)
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+	return cues, nil
+}
+
+func parseCueTiming(line, millisSeparator string) (time.Duration, time.Duration, error) {
+	startStr, rest, _ := strings.Cut(line, "-->")
+	// WebVTT cue settings follow the end time
+	endStr := strings.Fields(rest)
+	if len(endStr) == 0 {
+		return 0, 0, fmt.Errorf("missing end time")
+	}
+	start, err := parseCueTimestamp(strings.TrimSpace(startStr), millisSeparator)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseCueTimestamp(endStr[0], millisSeparator)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseCueTimestamp parses [HH:]MM:SS<sep>mmm
+func parseCueTimestamp(s, millisSeparator string) (time.Duration, error) {
+	clock, millisStr, ok := strings.Cut(s, millisSeparator)
+	if !ok {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	millis, err := strconv.Atoi(millisStr)
+	if err != nil || len(millisStr) != 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var secs int
+	for _, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		secs = secs*60 + v
+	}
+	return time.Duration(secs)*time.Second + time.Duration(millis)*time.Millisecond, nil
+}
+
+// SegmentWebVTT splits the cues into one WebVTT file per segment of the
+// source, given their durations in seconds. Cues spanning several segments are
+// repeated in each of them, as required by the HLS spec.
+func SegmentWebVTT(cues []SubtitleCue, segmentDurations []float64) [][]byte {
+	var segments [][]byte
+	var segmentStart time.Duration
+	for _, duration := range segmentDurations {
+		segmentEnd := segmentStart + time.Duration(duration*float64(time.Second))
+		var sb strings.Builder
+		sb.WriteString("WEBVTT\n")
+		sb.WriteString(webVTTTimestampMap + "\n")
+		for _, cue := range cues {
+			if cue.Start < segmentEnd && cue.End > segmentStart {
+				fmt.Fprintf(&sb, "\n%s --> %s\n%s\n", FormatVTTTimestamp(cue.Start.Seconds()), FormatVTTTimestamp(cue.End.Seconds()), cue.Text)
+			}
+		}
+		segments = append(segments, []byte(sb.String()))
+		segmentStart = segmentEnd
+	}
+	return segments
+}
+
+// ExtractSubtitles converts an embedded text subtitle track of the input,
+// given its index among the subtitle tracks, to a WebVTT file.
+func ExtractSubtitles(ctx context.Context, inputFile, outputFile string, trackIndex int) error {
+	stream := ffmpeg.Input(inputFile).
+		Output(outputFile, ffmpeg.KwArgs{
+			"map": fmt.Sprintf("0:s:%d", trackIndex),
+			"c:s": "webvtt",
+			"f":   "webvtt",
+		})
+	stream.Context = ctx
+	if err := stream.OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+		return fmt.Errorf("failed to extract subtitles track %d (%s): %s", trackIndex, inputFile, err)
+	}
+	return nil
+}
+
+// ExtractClosedCaptions converts the CEA-608 captions carried in the video
+// stream (H.264 SEI) of the input to a WebVTT file. The input is read by a
+// lavfi filter, so its path must not contain any special filter characters,
+// which is the case of our local temp files.
+func ExtractClosedCaptions(ctx context.Context, inputFile, outputFile string) error {
+	stream := ffmpeg.Input(fmt.Sprintf("movie=%s[out0+subcc]", inputFile), ffmpeg.KwArgs{"f": "lavfi"}).
+		Output(outputFile, ffmpeg.KwArgs{
+			"map": "0:s",
+			"c:s": "webvtt",
+			"f":   "webvtt",
+		})
+	stream.Context = ctx
+	if err := stream.OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+		return fmt.Errorf("failed to extract closed captions (%s): %s", inputFile, err)
+	}
+	return nil
+}
+
+// HasClosedCaptions returns whether the video stream of the input carries
+// CEA-608 captions. go-ffprobe doesn't parse the closed_captions field, so
+// ffprobe is run directly.
+func HasClosedCaptions(ctx context.Context, inputFile string) (bool, error) {
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=closed_captions", "-of", "csv=p=0", inputFile).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to probe closed captions (%s): %w", inputFile, err)
+	}
+	return strings.TrimSpace(string(out)) == "1", nil
+}
@@ -0,0 +1,50 @@
+package video
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSRT(t *testing.T) {
+	srt := "1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\nWorld\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nBye\r\n"
+	cues, err := ParseSubtitles([]byte(srt))
+	require.NoError(t, err)
+	require.Equal(t, []SubtitleCue{
+		{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hello\nWorld"},
+		{Start: 3 * time.Second, End: 4 * time.Second, Text: "Bye"},
+	}, cues)
+}
+
+func TestParseWebVTT(t *testing.T) {
+	vtt := "\xef\xbb\xbfWEBVTT - Some title\n\nNOTE this is a comment\n\nintro\n00:01.000 --> 00:02.000 align:start line:0\nHello\n\n01:00:00.000 --> 01:00:01.500\nLater\n"
+	cues, err := ParseSubtitles([]byte(vtt))
+	require.NoError(t, err)
+	require.Equal(t, []SubtitleCue{
+		{Start: time.Second, End: 2 * time.Second, Text: "Hello"},
+		{Start: time.Hour, End: time.Hour + 1500*time.Millisecond, Text: "Later"},
+	}, cues)
+}
+
+func TestParseSubtitlesRejectsInvalidTimings(t *testing.T) {
+	_, err := ParseSubtitles([]byte("1\n00:00:01 --> 00:00:02,000\nHello\n"))
+	require.EqualError(t, err, `invalid cue timing on line 2: invalid timestamp "00:00:01"`)
+
+	_, err = ParseSubtitles([]byte("WEBVTT\n\n00:00:01.000 -->\nHello\n"))
+	require.EqualError(t, err, "invalid cue timing on line 3: missing end time")
+}
+
+func TestSegmentWebVTT(t *testing.T) {
+	cues := []SubtitleCue{
+		{Start: time.Second, End: 2 * time.Second, Text: "first"},
+		{Start: 9 * time.Second, End: 11 * time.Second, Text: "overlapping"},
+	}
+	segments := SegmentWebVTT(cues, []float64{10, 10, 5})
+	require.Len(t, segments, 3)
+
+	header := "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000\n"
+	require.Equal(t, header+"\n00:00:01.000 --> 00:00:02.000\nfirst\n\n00:00:09.000 --> 00:00:11.000\noverlapping\n", string(segments[0]))
+	require.Equal(t, header+"\n00:00:09.000 --> 00:00:11.000\noverlapping\n", string(segments[1]))
+	require.Equal(t, header, string(segments[2]))
+}
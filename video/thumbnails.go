@@ -0,0 +1,138 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	DefaultThumbnailIntervalSecs = 10
+	DefaultThumbnailWidth        = 160
+
+	// Thumbnails are packed in sprite sheets of this many columns and rows
+	thumbnailSpriteColumns = 5
+	thumbnailSpriteRows    = 5
+	// ThumbnailsPerSpriteSheet is the number of frames in a full sprite sheet
+	ThumbnailsPerSpriteSheet = thumbnailSpriteColumns * thumbnailSpriteRows
+)
+
+// ThumbnailOptions configures the seek-preview thumbnails of a VOD output.
+type ThumbnailOptions struct {
+	// Time between two thumbnails
+	IntervalSecs float64 `json:"interval_secs,omitempty"`
+	// Width of each thumbnail, the height keeps the aspect ratio of the source
+	Width int64 `json:"width,omitempty"`
+}
+
+func (o ThumbnailOptions) WithDefaults() ThumbnailOptions {
+	if o.IntervalSecs <= 0 {
+		o.IntervalSecs = DefaultThumbnailIntervalSecs
+	}
+	if o.Width <= 0 {
+		o.Width = DefaultThumbnailWidth
+	}
+	return o
+}
+
+// ExtractThumbnailFrames writes one JPEG frame of the input every interval to
+// the directory, and returns the paths of the frames in order.
+func ExtractThumbnailFrames(ctx context.Context, inputFile, dir string, opts ThumbnailOptions) ([]string, error) {
+	opts = opts.WithDefaults()
+	stream := ffmpeg.Input(inputFile).
+		Output(filepath.Join(dir, "frame-%05d.jpg"), ffmpeg.KwArgs{
+			// the height must be even for the jpeg encoder
+			"vf":  fmt.Sprintf("fps=1/%g,scale=%d:-2", opts.IntervalSecs, opts.Width),
+			"q:v": "5",
+		})
+	stream.Context = ctx
+	if err := stream.OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+		return nil, fmt.Errorf("failed to extract thumbnail frames (%s): %s", inputFile, err)
+	}
+	frames, err := filepath.Glob(filepath.Join(dir, "frame-*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(frames)
+	return frames, nil
+}
+
+// ExtractPoster writes the frame of the input at the given time to a JPEG file.
+func ExtractPoster(ctx context.Context, inputFile, outputFile string, atSecs float64) error {
+	stream := ffmpeg.Input(inputFile, ffmpeg.KwArgs{"ss": fmt.Sprintf("%.3f", atSecs)}).
+		Output(outputFile, ffmpeg.KwArgs{"frames:v": "1", "q:v": "2"})
+	stream.Context = ctx
+	if err := stream.OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+		return fmt.Errorf("failed to extract poster frame (%s): %s", inputFile, err)
+	}
+	if _, err := os.Stat(outputFile); err != nil {
+		return fmt.Errorf("no poster frame extracted at %.3fs: %w", atSecs, err)
+	}
+	return nil
+}
+
+// BuildSpriteSheets packs the frames in sheets of thumbnailSpriteColumns x
+// thumbnailSpriteRows tiles, left to right then top to bottom. All the tiles
+// have the size of the first frame. The last sheet only has the rows it needs.
+func BuildSpriteSheets(frames []image.Image) (sheets []*image.RGBA, tileWidth, tileHeight int) {
+	if len(frames) == 0 {
+		return nil, 0, 0
+	}
+	tileWidth, tileHeight = frames[0].Bounds().Dx(), frames[0].Bounds().Dy()
+	perSheet := thumbnailSpriteColumns * thumbnailSpriteRows
+	for first := 0; first < len(frames); first += perSheet {
+		count := len(frames) - first
+		if count > perSheet {
+			count = perSheet
+		}
+		rows := (count + thumbnailSpriteColumns - 1) / thumbnailSpriteColumns
+		columns := thumbnailSpriteColumns
+		if rows == 1 {
+			columns = count
+		}
+		sheet := image.NewRGBA(image.Rect(0, 0, columns*tileWidth, rows*tileHeight))
+		for i := 0; i < count; i++ {
+			x, y := spriteTilePosition(i, tileWidth, tileHeight)
+			frame := frames[first+i]
+			draw.Draw(sheet, image.Rect(x, y, x+tileWidth, y+tileHeight), frame, frame.Bounds().Min, draw.Src)
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, tileWidth, tileHeight
+}
+
+func spriteTilePosition(indexInSheet, tileWidth, tileHeight int) (int, int) {
+	return (indexInSheet % thumbnailSpriteColumns) * tileWidth, (indexInSheet / thumbnailSpriteColumns) * tileHeight
+}
+
+// ThumbnailsVTT builds the WebVTT track pointing each interval of the video to
+// its tile in the sprite sheets, using media fragments (#xywh=x,y,w,h).
+func ThumbnailsVTT(numFrames int, intervalSecs, durationSecs float64, tileWidth, tileHeight int, sheetNames []string) string {
+	perSheet := thumbnailSpriteColumns * thumbnailSpriteRows
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for i := 0; i < numFrames; i++ {
+		start := float64(i) * intervalSecs
+		end := start + intervalSecs
+		if durationSecs > start && end > durationSecs {
+			end = durationSecs
+		}
+		x, y := spriteTilePosition(i%perSheet, tileWidth, tileHeight)
+		fmt.Fprintf(&sb, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			FormatVTTTimestamp(start), FormatVTTTimestamp(end), sheetNames[i/perSheet], x, y, tileWidth, tileHeight)
+	}
+	return sb.String()
+}
+
+// FormatVTTTimestamp formats a time in seconds as a WebVTT timestamp (HH:MM:SS.mmm).
+func FormatVTTTimestamp(secs float64) string {
+	millis := int64(secs*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
@@ -0,0 +1,45 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildSpriteSheets(t *testing.T) {
+	var frames []image.Image
+	for i := 0; i < 27; i++ {
+		frame := image.NewRGBA(image.Rect(0, 0, 16, 9))
+		frame.Set(0, 0, color.RGBA{R: uint8(i), A: 255})
+		frames = append(frames, frame)
+	}
+
+	sheets, w, h := BuildSpriteSheets(frames)
+	require.Equal(t, 16, w)
+	require.Equal(t, 9, h)
+	require.Len(t, sheets, 2)
+	require.Equal(t, image.Rect(0, 0, 80, 45), sheets[0].Bounds())
+	require.Equal(t, image.Rect(0, 0, 32, 9), sheets[1].Bounds())
+
+	// 8th frame is on the second row, third column
+	require.Equal(t, uint8(7), sheets[0].RGBAAt(32, 9).R)
+	require.Equal(t, uint8(26), sheets[1].RGBAAt(16, 0).R)
+
+	sheets, _, _ = BuildSpriteSheets(nil)
+	require.Empty(t, sheets)
+}
+
+func TestThumbnailsVTT(t *testing.T) {
+	vtt := ThumbnailsVTT(27, 10, 265.5, 160, 90, []string{"sprite-0.jpg", "sprite-1.jpg"})
+	require.Contains(t, vtt, "WEBVTT\n\n00:00:00.000 --> 00:00:10.000\nsprite-0.jpg#xywh=0,0,160,90\n")
+	require.Contains(t, vtt, "\n00:01:10.000 --> 00:01:20.000\nsprite-0.jpg#xywh=320,90,160,90\n")
+	require.Contains(t, vtt, "\n00:04:20.000 --> 00:04:25.500\nsprite-1.jpg#xywh=160,0,160,90\n")
+}
+
+func TestFormatVTTTimestamp(t *testing.T) {
+	require.Equal(t, "00:00:00.000", FormatVTTTimestamp(0))
+	require.Equal(t, "00:01:05.250", FormatVTTTimestamp(65.25))
+	require.Equal(t, "02:00:00.001", FormatVTTTimestamp(7200.001))
+}
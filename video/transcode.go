@@ -0,0 +1,121 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// x264 names of the H264 profiles used by Livepeer
+var h264Profiles = map[string]string{
+	"h264baseline":        "baseline",
+	"h264main":            "main",
+	"h264high":            "high",
+	"h264constrainedhigh": "high",
+}
+
+// ffmpeg software encoders of the codecs that can be carried by MPEG-TS
+var softwareEncoders = map[string]string{
+	CodecH264: "libx264",
+	CodecHEVC: "libx265",
+}
+
+// TranscodeSegment transcodes a single source segment into one MPEG-TS file per
+// profile with a software encoder, in one pass over the input. Timestamps are
+// kept from the source so that the rendition segments play back to back.
+func TranscodeSegment(ctx context.Context, inputFile string, outputFiles []string, profiles []EncodedProfile) error {
+	return transcodeSegment(ctx, inputFile, outputFiles, profiles, transcodeArgs)
+}
+
+// TranscodeAudioSegment is TranscodeSegment for audio-only inputs, producing
+// AAC renditions at the bitrate of each profile.
+func TranscodeAudioSegment(ctx context.Context, inputFile string, outputFiles []string, profiles []EncodedProfile) error {
+	return transcodeSegment(ctx, inputFile, outputFiles, profiles, audioTranscodeArgs)
+}
+
+// TranscodeAudioRenditions transcodes each audio track of a source segment
+// into its own AAC MPEG-TS file, for the alternate audio renditions.
+func TranscodeAudioRenditions(ctx context.Context, inputFile string, outputFiles []string, renditions []AudioRendition) error {
+	if len(outputFiles) != len(renditions) {
+		return fmt.Errorf("expected one output file per audio rendition, got %d files for %d renditions", len(outputFiles), len(renditions))
+	}
+	input := ffmpeg.Input(inputFile)
+	var outputs []*ffmpeg.Stream
+	for i, rendition := range renditions {
+		args := audioTranscodeArgs(EncodedProfile{Bitrate: rendition.Bitrate})
+		args["map"] = fmt.Sprintf("0:a:%d", rendition.TrackIndex)
+		outputs = append(outputs, input.Output(outputFiles[i], args))
+	}
+	stream := ffmpeg.MergeOutputs(outputs...)
+	stream.Context = ctx
+	if err := stream.OverWriteOutput().ErrorToStdOut().Run(); err != nil {
+		return fmt.Errorf("failed to transcode audio renditions (%s): %s", inputFile, err)
+	}
+	return nil
+}
+
+func transcodeSegment(ctx context.Context, inputFile string, outputFiles []string, profiles []EncodedProfile, args func(EncodedProfile) ffmpeg.KwArgs) error {
+	if len(outputFiles) != len(profiles) {
+		return fmt.Errorf("expected one output file per profile, got %d files for %d profiles", len(outputFiles), len(profiles))
+	}
+	input := ffmpeg.Input(inputFile)
+	var outputs []*ffmpeg.Stream
+	for i, profile := range profiles {
+		outputs = append(outputs, input.Output(outputFiles[i], args(profile)))
+	}
+	stream := ffmpeg.MergeOutputs(outputs...)
+	// ffmpeg gets killed if the context is cancelled
+	stream.Context = ctx
+	err := stream.OverWriteOutput().ErrorToStdOut().Run()
+	if err != nil {
+		return fmt.Errorf("failed to transcode segment (%s): %s", inputFile, err)
+	}
+	return nil
+}
+
+func audioTranscodeArgs(profile EncodedProfile) ffmpeg.KwArgs {
+	return ffmpeg.KwArgs{
+		"vn":     "",
+		"c:a":    "aac",
+		"b:a":    strconv.FormatInt(profile.Bitrate, 10),
+		"f":      "mpegts",
+		"copyts": "",
+	}
+}
+
+func transcodeArgs(profile EncodedProfile) ffmpeg.KwArgs {
+	args := ffmpeg.KwArgs{
+		"c:v":     softwareEncoders[profile.VideoCodec()],
+		"preset":  "veryfast",
+		"b:v":     strconv.FormatInt(profile.Bitrate, 10),
+		"maxrate": strconv.FormatInt(profile.Bitrate, 10),
+		"bufsize": strconv.FormatInt(2*profile.Bitrate, 10),
+		"vf":      fmt.Sprintf("scale=%d:%d", profile.Width, profile.Height),
+		"c:a":     "aac",
+		"f":       "mpegts",
+		"copyts":  "",
+	}
+	if profile.FPS > 0 {
+		fps := strconv.FormatInt(profile.FPS, 10)
+		if profile.FPSDen > 0 {
+			fps += "/" + strconv.FormatInt(profile.FPSDen, 10)
+		}
+		args["r"] = fps
+	}
+	if p, ok := h264Profiles[strings.ToLower(profile.Profile)]; ok && profile.VideoCodec() == CodecH264 {
+		args["profile:v"] = p
+	}
+	switch gop := profile.GOP; {
+	case gop == "intra":
+		args["g"] = "1"
+	case gop != "":
+		// the GOP is a duration in seconds
+		if secs, err := strconv.ParseFloat(gop, 64); err == nil && secs > 0 {
+			args["force_key_frames"] = fmt.Sprintf("expr:gte(t,n_forced*%s)", gop)
+		}
+	}
+	return args
+}
@@ -0,0 +1,44 @@
+package video
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+func TestTranscodeArgs(t *testing.T) {
+	args := transcodeArgs(EncodedProfile{
+		Name:    "720p0",
+		Width:   1280,
+		Height:  720,
+		Bitrate: 4_000_000,
+		FPS:     30000,
+		FPSDen:  1001,
+		Profile: "H264High",
+		GOP:     "2.0",
+	})
+	require.Equal(t, ffmpeg.KwArgs{
+		"c:v":              "libx264",
+		"preset":           "veryfast",
+		"b:v":              "4000000",
+		"maxrate":          "4000000",
+		"bufsize":          "8000000",
+		"vf":               "scale=1280:720",
+		"c:a":              "aac",
+		"f":                "mpegts",
+		"copyts":           "",
+		"r":                "30000/1001",
+		"profile:v":        "high",
+		"force_key_frames": "expr:gte(t,n_forced*2.0)",
+	}, args)
+
+	args = transcodeArgs(EncodedProfile{Name: "360p0", Width: 640, Height: 360, Bitrate: 1_000_000, GOP: "intra"})
+	require.Equal(t, "1", args["g"])
+	require.NotContains(t, args, "r")
+	require.NotContains(t, args, "profile:v")
+
+	args = transcodeArgs(EncodedProfile{Name: "1080p0", Width: 1920, Height: 1080, Bitrate: 3_000_000, Profile: "H264High", Codec: CodecHEVC})
+	require.Equal(t, "libx265", args["c:v"])
+	require.NotContains(t, args, "profile:v")
+}
@@ -26,12 +26,9 @@ func ConcatTS(tsFileName string, segmentsList *TSegmentList) (int64, error) {
 	}
 	defer tsFile.Close()
 	// 2. for a given rendition, write all segment indices in ascending order to the single .ts file
-	for _, k := range segmentsList.GetSortedSegments() {
-		segBytes, err := tsFile.Write(segmentsList.SegmentDataTable[k])
-		if err != nil {
-			return totalBytes, fmt.Errorf("error writing segment %d err: %s", k, err)
-		}
-		totalBytes = totalBytes + int64(segBytes)
+	totalBytes, err = segmentsList.WriteTo(tsFile)
+	if err != nil {
+		return totalBytes, fmt.Errorf("error concatenating segments into (%s): %w", tsFileName, err)
 	}
 	return totalBytes, nil
 }